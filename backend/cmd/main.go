@@ -1,35 +1,138 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/alerts"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/anomaly"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/config"
 	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/database"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/filters"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/influxdb"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/metrics"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/mqtt"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/notifications"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/retention"
 	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/routes"
 	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/schedules"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/settings"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/sinks"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/speedtest"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/webhooks"
 )
 
+// shutdownTimeout bounds how long in-flight requests get to finish after a shutdown signal
+const shutdownTimeout = 15 * time.Second
+
 func main() {
-	if err := database.InitDB(); err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	if err := run(cfg); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run starts the server and blocks until it stops. It returns instead of exiting so the deferred
+// closes flush the InfluxDB and MQTT sinks and close the database pool.
+func run(cfg config.Config) error {
+	if err := database.InitDB(cfg.Database.ConnString(), cfg.Database.MaxConns); err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
 	}
 
 	if err := database.VerifyMetadata(); err != nil {
-		log.Fatalf("Failed to verify database metadata: %v", err)
+		return fmt.Errorf("failed to verify database metadata: %w", err)
 	}
 
 	if err := database.MigrateDB(); err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
+		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 	defer database.CloseDB()
 
+	speedtest.Configure(cfg.Providers)
+
+	sinks.Register(&webhooks.Dispatcher{})
+	sinks.Register(&anomaly.Detector{})
+	sinks.Register(&alerts.Evaluator{})
+	sinks.Register(&notifications.Dispatcher{})
+	sinks.Register(&metrics.ResultSink{})
+	alerts.OnTransition(webhooks.HandleAlert)
+	alerts.OnTransition(notifications.HandleAlert)
+
+	settings.OnChange(func(s models.UserSettings) {
+		schedules.SetPaused(s.Paused)
+		filters.SetDefaultTimezone(s.DefaultTimezone)
+		filters.SetDefaults(filters.Defaults{
+			Range:     s.DefaultFilters.Range,
+			Providers: s.DefaultFilters.Providers,
+			Servers:   s.DefaultFilters.Servers,
+			Schedules: s.DefaultFilters.Schedules,
+		})
+	})
+	if err := settings.Load(context.Background()); err != nil {
+		return fmt.Errorf("failed to load settings: %w", err)
+	}
+
+	if cfg.InfluxDB.Enabled() {
+		influxSink, err := influxdb.NewSink(cfg.InfluxDB)
+		if err != nil {
+			return fmt.Errorf("failed to start InfluxDB sink: %w", err)
+		}
+		defer influxSink.Close()
+		sinks.Register(influxSink)
+		log.Printf("Writing results to InfluxDB at %s", cfg.InfluxDB.URL)
+	}
+
+	if cfg.MQTT.Enabled() {
+		mqttPublisher := mqtt.NewPublisher(cfg.MQTT)
+		defer mqttPublisher.Close()
+		sinks.Register(mqttPublisher)
+		schedules.OnChange(mqttPublisher.PublishDiscovery)
+		log.Printf("Publishing results to MQTT broker %s", cfg.MQTT.Broker)
+	}
+
 	routes.SetupRoutes()
 
 	schedules.LoadCronJobs()
+	metrics.SetScheduleSource(schedules.UpcomingRuns)
+	alerts.Start()
+	retention.Start(cfg.Retention)
+
+	server := &http.Server{
+		Addr:              cfg.ListenAddr,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+	}
+	log.Printf("Starting server on %s", cfg.ListenAddr)
 
-	log.Println("Starting server on :8080")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	if err := http.ListenAndServe(":8080", nil); err != nil {
-		log.Fatal(err)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down server: %w", err)
 	}
+	return nil
 }
@@ -0,0 +1,399 @@
+package alerts
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/database"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/filters"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+)
+
+// Rule types
+const (
+	RuleTypeConsecutive = "consecutive"
+	RuleTypeWindow      = "window"
+	RuleTypeAbsence     = "absence"
+)
+
+// Rule states
+const (
+	StateOK       = "ok"
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+)
+
+var aggregates = map[string]bool{
+	"avg": true, "min": true, "max": true,
+	"p50": true, "p90": true, "p95": true, "p99": true,
+}
+
+const ruleColumns = `
+		id, name, rule_type, COALESCE(metric, ''), COALESCE(operator, ''), COALESCE(threshold, 0),
+		consecutive, window_minutes, COALESCE(aggregate, ''), schedule_id, provider_id, server_name,
+		is_active, state, COALESCE(last_value, 0), last_evaluated_at, state_changed_at, created_at, updated_at`
+
+// rulePatch holds the fields of a PATCH request. Fields left out of the request are nil and keep
+// their current value; an empty schedule_id, provider_id or server_name removes that scope.
+type rulePatch struct {
+	Name          *string  `json:"name"`
+	RuleType      *string  `json:"rule_type"`
+	Metric        *string  `json:"metric"`
+	Operator      *string  `json:"operator"`
+	Threshold     *float64 `json:"threshold"`
+	Consecutive   *int     `json:"consecutive"`
+	WindowMinutes *int     `json:"window_minutes"`
+	Aggregate     *string  `json:"aggregate"`
+	ScheduleID    *string  `json:"schedule_id"`
+	ProviderID    *string  `json:"provider_id"`
+	ServerName    *string  `json:"server_name"`
+	IsActive      *bool    `json:"is_active"`
+}
+
+func AlertsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		if r.PathValue("id") != "" {
+			getRule(w, r)
+		} else {
+			listRules(w, r)
+		}
+	case http.MethodPost:
+		createRule(w, r)
+	case http.MethodPatch:
+		updateRule(w, r)
+	case http.MethodDelete:
+		deleteRule(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HistoryHandler lists firing and resolved events, either for one rule or across all rules
+func HistoryHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := 100
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+
+	var ruleID interface{}
+	if id := r.PathValue("id"); id != "" {
+		ruleID = id
+	}
+
+	rows, err := database.DB.Query(r.Context(), `
+		SELECT e.id, e.rule_id, r.name, e.state, COALESCE(e.value, 0), e.message, e.created_at
+		FROM alert_events e
+		JOIN alert_rules r ON r.id = e.rule_id
+		WHERE ($1::uuid IS NULL OR e.rule_id = $1)
+		ORDER BY e.created_at DESC
+		LIMIT $2
+	`, ruleID, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	events := []models.AlertEvent{}
+	for rows.Next() {
+		var e models.AlertEvent
+		if err := rows.Scan(&e.ID, &e.RuleID, &e.RuleName, &e.State, &e.Value, &e.Message, &e.CreatedAt); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(events)
+}
+
+func listRules(w http.ResponseWriter, r *http.Request) {
+	var state interface{}
+	if s := r.URL.Query().Get("state"); s != "" {
+		state = s
+	}
+
+	rules, err := fetchRules(r.Context(), "WHERE ($1::text IS NULL OR state = $1) ORDER BY created_at DESC", state)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(rules)
+}
+
+func getRule(w http.ResponseWriter, r *http.Request) {
+	rules, err := fetchRules(r.Context(), "WHERE id = $1", r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(rules) == 0 {
+		http.Error(w, "Alert rule not found", http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(rules[0])
+}
+
+func fetchRules(ctx context.Context, condition string, args ...interface{}) ([]models.AlertRule, error) {
+	rows, err := database.DB.Query(ctx, "SELECT"+ruleColumns+" FROM alert_rules "+condition, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch alert rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []models.AlertRule{}
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan alert rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading alert rules: %w", err)
+	}
+	return rules, nil
+}
+
+// scanRule reads a rule selected with ruleColumns
+func scanRule(row pgx.Row) (models.AlertRule, error) {
+	var rule models.AlertRule
+	var scheduleID, providerID, serverName sql.NullString
+	if err := row.Scan(&rule.ID, &rule.Name, &rule.RuleType, &rule.Metric, &rule.Operator, &rule.Threshold,
+		&rule.Consecutive, &rule.WindowMinutes, &rule.Aggregate, &scheduleID, &providerID, &serverName,
+		&rule.IsActive, &rule.State, &rule.LastValue, &rule.LastEvaluatedAt, &rule.StateChangedAt,
+		&rule.CreatedAt, &rule.UpdatedAt); err != nil {
+		return rule, err
+	}
+	rule.ScheduleID = scheduleID.String
+	rule.ProviderID = providerID.String
+	rule.ServerName = serverName.String
+	return rule, nil
+}
+
+func createRule(w http.ResponseWriter, r *http.Request) {
+	// Rules are active unless the request says otherwise, like the column default
+	rule := models.AlertRule{IsActive: true}
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateRule(&rule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rule.State = StateOK
+	err := database.DB.QueryRow(r.Context(), `
+		INSERT INTO alert_rules (name, rule_type, metric, operator, threshold, consecutive, window_minutes, aggregate,
+		                         schedule_id, provider_id, server_name, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id, created_at, updated_at
+	`, rule.Name, rule.RuleType, nullIfEmpty(rule.Metric), nullIfEmpty(rule.Operator), rule.Threshold, rule.Consecutive,
+		rule.WindowMinutes, nullIfEmpty(rule.Aggregate), nullIfEmpty(rule.ScheduleID), nullIfEmpty(rule.ProviderID),
+		nullIfEmpty(rule.ServerName), rule.IsActive).Scan(&rule.ID, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	go EvaluateAll()
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rule)
+}
+
+func updateRule(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		http.Error(w, "ID is required", http.StatusBadRequest)
+		return
+	}
+
+	var patch rulePatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	rule, err := scanRule(tx.QueryRow(ctx, "SELECT"+ruleColumns+" FROM alert_rules WHERE id = $1 FOR UPDATE", id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Alert rule not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	patch.apply(&rule)
+	if err := validateRule(&rule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = tx.QueryRow(ctx, `
+		UPDATE alert_rules
+		SET name = $1, rule_type = $2, metric = $3, operator = $4, threshold = $5, consecutive = $6, window_minutes = $7,
+		    aggregate = $8, schedule_id = $9, provider_id = $10, server_name = $11, is_active = $12,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = $13
+		RETURNING updated_at
+	`, rule.Name, rule.RuleType, nullIfEmpty(rule.Metric), nullIfEmpty(rule.Operator), rule.Threshold, rule.Consecutive,
+		rule.WindowMinutes, nullIfEmpty(rule.Aggregate), nullIfEmpty(rule.ScheduleID), nullIfEmpty(rule.ProviderID),
+		nullIfEmpty(rule.ServerName), rule.IsActive, id).Scan(&rule.UpdatedAt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	go EvaluateAll()
+
+	json.NewEncoder(w).Encode(rule)
+}
+
+func deleteRule(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		http.Error(w, "ID is required", http.StatusBadRequest)
+		return
+	}
+
+	result, err := database.DB.Exec(r.Context(), "DELETE FROM alert_rules WHERE id = $1", id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if result.RowsAffected() == 0 {
+		http.Error(w, "Alert rule not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apply copies the fields present in the patch onto rule
+func (p rulePatch) apply(rule *models.AlertRule) {
+	if p.Name != nil {
+		rule.Name = *p.Name
+	}
+	if p.RuleType != nil {
+		rule.RuleType = *p.RuleType
+	}
+	if p.Metric != nil {
+		rule.Metric = *p.Metric
+	}
+	if p.Operator != nil {
+		rule.Operator = *p.Operator
+	}
+	if p.Threshold != nil {
+		rule.Threshold = *p.Threshold
+	}
+	if p.Consecutive != nil {
+		rule.Consecutive = *p.Consecutive
+	}
+	if p.WindowMinutes != nil {
+		rule.WindowMinutes = *p.WindowMinutes
+	}
+	if p.Aggregate != nil {
+		rule.Aggregate = *p.Aggregate
+	}
+	if p.ScheduleID != nil {
+		rule.ScheduleID = *p.ScheduleID
+	}
+	if p.ProviderID != nil {
+		rule.ProviderID = *p.ProviderID
+	}
+	if p.ServerName != nil {
+		rule.ServerName = *p.ServerName
+	}
+	if p.IsActive != nil {
+		rule.IsActive = *p.IsActive
+	}
+}
+
+// validateRule checks that the fields required by the rule type are present and fills in defaults
+func validateRule(rule *models.AlertRule) error {
+	if rule.Name == "" {
+		return errors.New("name is required")
+	}
+
+	switch rule.RuleType {
+	case RuleTypeConsecutive:
+		if rule.Consecutive <= 0 {
+			rule.Consecutive = 1
+		}
+		rule.Aggregate = ""
+	case RuleTypeWindow:
+		if rule.WindowMinutes <= 0 {
+			return errors.New("window_minutes must be positive for window rules")
+		}
+		if rule.Aggregate == "" {
+			rule.Aggregate = "avg"
+		}
+		if !aggregates[rule.Aggregate] {
+			return fmt.Errorf("unknown aggregate: %q", rule.Aggregate)
+		}
+	case RuleTypeAbsence:
+		if rule.WindowMinutes <= 0 {
+			return errors.New("window_minutes must be positive for absence rules")
+		}
+		// Absence rules only look at result timestamps
+		rule.Metric = ""
+		rule.Operator = ""
+		rule.Aggregate = ""
+		return nil
+	default:
+		return fmt.Errorf("unknown rule_type: %q", rule.RuleType)
+	}
+
+	if !filters.IsMetric(rule.Metric) {
+		return fmt.Errorf("unknown metric: %q", rule.Metric)
+	}
+	if rule.Operator != "<" && rule.Operator != ">" {
+		return fmt.Errorf("operator must be < or >")
+	}
+	return nil
+}
+
+func nullIfEmpty(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,117 @@
+package alerts
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+)
+
+func TestValidateRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    models.AlertRule
+		want    models.AlertRule
+		wantErr string
+	}{
+		{
+			name: "consecutive defaults to one result",
+			rule: models.AlertRule{Name: "slow", RuleType: RuleTypeConsecutive, Metric: "download", Operator: "<", Aggregate: "p95"},
+			want: models.AlertRule{Name: "slow", RuleType: RuleTypeConsecutive, Metric: "download", Operator: "<", Consecutive: 1},
+		},
+		{
+			name: "window defaults to the average",
+			rule: models.AlertRule{Name: "laggy", RuleType: RuleTypeWindow, Metric: "ping", Operator: ">", WindowMinutes: 60},
+			want: models.AlertRule{Name: "laggy", RuleType: RuleTypeWindow, Metric: "ping", Operator: ">", WindowMinutes: 60, Aggregate: "avg"},
+		},
+		{
+			name: "absence ignores metric fields",
+			rule: models.AlertRule{Name: "quiet", RuleType: RuleTypeAbsence, Metric: "bogus", Operator: "=", WindowMinutes: 120},
+			want: models.AlertRule{Name: "quiet", RuleType: RuleTypeAbsence, WindowMinutes: 120},
+		},
+		{name: "missing name", rule: models.AlertRule{RuleType: RuleTypeConsecutive}, wantErr: "name is required"},
+		{name: "unknown type", rule: models.AlertRule{Name: "x", RuleType: "sometimes"}, wantErr: "unknown rule_type"},
+		{name: "window without minutes", rule: models.AlertRule{Name: "x", RuleType: RuleTypeWindow}, wantErr: "window_minutes must be positive"},
+		{
+			name:    "unknown aggregate",
+			rule:    models.AlertRule{Name: "x", RuleType: RuleTypeWindow, WindowMinutes: 5, Aggregate: "p42", Metric: "ping", Operator: ">"},
+			wantErr: "unknown aggregate",
+		},
+		{name: "unknown metric", rule: models.AlertRule{Name: "x", RuleType: RuleTypeConsecutive, Metric: "share", Operator: "<"}, wantErr: "unknown metric"},
+		{name: "unknown operator", rule: models.AlertRule{Name: "x", RuleType: RuleTypeConsecutive, Metric: "ping", Operator: ">="}, wantErr: "operator must be"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := tt.rule
+			err := validateRule(&rule)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("validateRule() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateRule() error = %v", err)
+			}
+			if rule.RuleType != tt.want.RuleType || rule.Metric != tt.want.Metric || rule.Operator != tt.want.Operator ||
+				rule.Consecutive != tt.want.Consecutive || rule.Aggregate != tt.want.Aggregate {
+				t.Errorf("rule = %+v, want %+v", rule, tt.want)
+			}
+		})
+	}
+}
+
+func TestRulePatch(t *testing.T) {
+	rule := models.AlertRule{
+		Name: "slow", RuleType: RuleTypeConsecutive, Metric: "download", Operator: "<", Threshold: 100,
+		Consecutive: 3, ScheduleID: "s1", ServerName: "Frankfurt", IsActive: true,
+	}
+
+	var patch rulePatch
+	if err := json.Unmarshal([]byte(`{"threshold": 50, "server_name": "", "is_active": false}`), &patch); err != nil {
+		t.Fatal(err)
+	}
+	patch.apply(&rule)
+
+	if rule.Threshold != 50 || rule.ServerName != "" || rule.IsActive {
+		t.Errorf("rule = %+v, want the sent fields changed", rule)
+	}
+	if rule.Name != "slow" || rule.Metric != "download" || rule.Consecutive != 3 || rule.ScheduleID != "s1" {
+		t.Errorf("rule = %+v, want the other fields kept", rule)
+	}
+}
+
+func TestBreaches(t *testing.T) {
+	below := models.AlertRule{Operator: "<", Threshold: 100}
+	above := models.AlertRule{Operator: ">", Threshold: 30}
+
+	for _, tt := range []struct {
+		rule  models.AlertRule
+		value float64
+		want  bool
+	}{
+		{rule: below, value: 99.9, want: true},
+		{rule: below, value: 100, want: false},
+		{rule: above, value: 30.1, want: true},
+		{rule: above, value: 30, want: false},
+	} {
+		if got := breaches(tt.rule, tt.value); got != tt.want {
+			t.Errorf("breaches(%s %v, %v) = %v, want %v", tt.rule.Operator, tt.rule.Threshold, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestAggregateExpression(t *testing.T) {
+	for aggregate, want := range map[string]string{
+		"avg": "AVG(ping)",
+		"max": "MAX(ping)",
+		"p50": "percentile_cont(0.50) WITHIN GROUP (ORDER BY ping)",
+		"p95": "percentile_cont(0.95) WITHIN GROUP (ORDER BY ping)",
+	} {
+		if got := aggregateExpression(aggregate, "ping"); got != want {
+			t.Errorf("aggregateExpression(%q) = %q, want %q", aggregate, got, want)
+		}
+	}
+}
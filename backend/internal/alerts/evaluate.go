@@ -0,0 +1,319 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/database"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/filters"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+)
+
+// evaluationInterval is how often rules are re-evaluated without new results,
+// which is what lets absence and window rules fire and resolve on their own
+const evaluationInterval = time.Minute
+
+var (
+	evaluationMutex sync.Mutex
+
+	transitionListeners      []func(models.AlertEvent)
+	transitionListenersMutex sync.Mutex
+)
+
+// Evaluator re-evaluates alert rules whenever a result is stored
+type Evaluator struct{}
+
+func (e *Evaluator) Name() string {
+	return "alerts"
+}
+
+func (e *Evaluator) HandleResult(result models.SpeedTestResult) {
+	go EvaluateAll()
+}
+
+// OnTransition registers a function that is called whenever a rule starts firing or resolves
+func OnTransition(listener func(models.AlertEvent)) {
+	transitionListenersMutex.Lock()
+	defer transitionListenersMutex.Unlock()
+	transitionListeners = append(transitionListeners, listener)
+}
+
+// Start evaluates all active rules periodically in the background
+func Start() {
+	go func() {
+		ticker := time.NewTicker(evaluationInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			EvaluateAll()
+		}
+	}()
+}
+
+// EvaluateAll evaluates every active rule and records state transitions
+func EvaluateAll() {
+	evaluationMutex.Lock()
+	defer evaluationMutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	rules, err := fetchRules(ctx, "WHERE is_active = true")
+	if err != nil {
+		log.Printf("Error loading alert rules: %v", err)
+		return
+	}
+
+	for _, rule := range rules {
+		if err := evaluateRule(ctx, rule); err != nil {
+			log.Printf("Error evaluating alert rule %s: %v", rule.Name, err)
+		}
+	}
+}
+
+// evaluation is the outcome of checking a rule against the stored results
+type evaluation struct {
+	breached bool
+	value    float64
+	message  string
+	// evaluable is false when there is not enough data to decide, in which case the state is kept
+	evaluable bool
+}
+
+func evaluateRule(ctx context.Context, rule models.AlertRule) error {
+	var result evaluation
+	var err error
+
+	switch rule.RuleType {
+	case RuleTypeConsecutive:
+		result, err = evaluateConsecutive(ctx, rule)
+	case RuleTypeWindow:
+		result, err = evaluateWindow(ctx, rule)
+	case RuleTypeAbsence:
+		result, err = evaluateAbsence(ctx, rule)
+	default:
+		return fmt.Errorf("unknown rule type %q", rule.RuleType)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !result.evaluable {
+		_, err := database.DB.Exec(ctx, "UPDATE alert_rules SET last_evaluated_at = CURRENT_TIMESTAMP WHERE id = $1", rule.ID)
+		return err
+	}
+
+	newState := rule.State
+	if result.breached && rule.State != StateFiring {
+		newState = StateFiring
+	} else if !result.breached && rule.State == StateFiring {
+		newState = StateResolved
+	}
+
+	if newState == rule.State {
+		_, err := database.DB.Exec(ctx, `
+			UPDATE alert_rules SET last_value = $1, last_evaluated_at = CURRENT_TIMESTAMP WHERE id = $2
+		`, result.value, rule.ID)
+		return err
+	}
+
+	return recordTransition(ctx, rule, newState, result)
+}
+
+func recordTransition(ctx context.Context, rule models.AlertRule, state string, result evaluation) error {
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// A resolved rule goes back to ok so it can fire again
+	storedState := state
+	if state == StateResolved {
+		storedState = StateOK
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE alert_rules
+		SET state = $1, last_value = $2, last_evaluated_at = CURRENT_TIMESTAMP, state_changed_at = CURRENT_TIMESTAMP
+		WHERE id = $3
+	`, storedState, result.value, rule.ID); err != nil {
+		return fmt.Errorf("failed to update rule state: %w", err)
+	}
+
+	event := models.AlertEvent{
+		RuleID:   rule.ID,
+		RuleName: rule.Name,
+		State:    state,
+		Value:    result.value,
+		Message:  result.message,
+	}
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO alert_events (rule_id, state, value, message)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, rule.ID, state, result.value, result.message).Scan(&event.ID, &event.CreatedAt); err != nil {
+		return fmt.Errorf("failed to record alert event: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("Alert %s %s: %s", rule.Name, state, result.message)
+
+	transitionListenersMutex.Lock()
+	listeners := append([]func(models.AlertEvent){}, transitionListeners...)
+	transitionListenersMutex.Unlock()
+	for _, listener := range listeners {
+		listener(event)
+	}
+	return nil
+}
+
+// ruleFilter limits a rule to the results of its schedule, provider and server
+func ruleFilter(rule models.AlertRule) filters.ResultFilter {
+	var filter filters.ResultFilter
+	if rule.ScheduleID != "" {
+		filter.ScheduleIDs = []string{rule.ScheduleID}
+	}
+	if rule.ProviderID != "" {
+		filter.Providers = []string{rule.ProviderID}
+	}
+	if rule.ServerName != "" {
+		filter.ServerNames = []string{rule.ServerName}
+	}
+	return filter
+}
+
+func breaches(rule models.AlertRule, value float64) bool {
+	if rule.Operator == "<" {
+		return value < rule.Threshold
+	}
+	return value > rule.Threshold
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
+
+func evaluateConsecutive(ctx context.Context, rule models.AlertRule) (evaluation, error) {
+	where, args := ruleFilter(rule).Where(nil)
+	query := fmt.Sprintf("SELECT %s FROM speedtest_results%s ORDER BY timestamp DESC LIMIT %d",
+		rule.Metric, where, rule.Consecutive)
+
+	rows, err := database.DB.Query(ctx, query, args...)
+	if err != nil {
+		return evaluation{}, fmt.Errorf("failed to fetch recent results: %w", err)
+	}
+	defer rows.Close()
+
+	var values []float64
+	for rows.Next() {
+		var value float64
+		if err := rows.Scan(&value); err != nil {
+			return evaluation{}, fmt.Errorf("failed to scan result: %w", err)
+		}
+		values = append(values, value)
+	}
+	if err := rows.Err(); err != nil {
+		return evaluation{}, fmt.Errorf("error reading results: %w", err)
+	}
+
+	if len(values) == 0 {
+		return evaluation{}, nil
+	}
+
+	// The latest result alone is enough to resolve, but firing needs the full run
+	result := evaluation{value: values[0], evaluable: true}
+	if !breaches(rule, values[0]) {
+		result.message = fmt.Sprintf("latest %s is %s (threshold %s %s)",
+			rule.Metric, formatValue(values[0]), rule.Operator, formatValue(rule.Threshold))
+		return result, nil
+	}
+	if len(values) < rule.Consecutive {
+		return evaluation{}, nil
+	}
+
+	for _, value := range values {
+		if !breaches(rule, value) {
+			result.message = fmt.Sprintf("latest %s is %s but not all of the last %d results breach %s %s",
+				rule.Metric, formatValue(values[0]), rule.Consecutive, rule.Operator, formatValue(rule.Threshold))
+			return result, nil
+		}
+	}
+
+	result.breached = true
+	result.message = fmt.Sprintf("%s %s %s for %d consecutive results (latest %s)",
+		rule.Metric, rule.Operator, formatValue(rule.Threshold), rule.Consecutive, formatValue(values[0]))
+	return result, nil
+}
+
+// aggregateExpression renders the SQL aggregate for a window rule; metric and aggregate are validated beforehand
+func aggregateExpression(aggregate, metric string) string {
+	if strings.HasPrefix(aggregate, "p") {
+		return fmt.Sprintf("percentile_cont(0.%s) WITHIN GROUP (ORDER BY %s)", strings.TrimPrefix(aggregate, "p"), metric)
+	}
+	return fmt.Sprintf("%s(%s)", strings.ToUpper(aggregate), metric)
+}
+
+func evaluateWindow(ctx context.Context, rule models.AlertRule) (evaluation, error) {
+	filter := ruleFilter(rule)
+	filter.StartDate = time.Now().Add(-time.Duration(rule.WindowMinutes) * time.Minute).Format(time.RFC3339)
+	where, args := filter.Where(nil)
+
+	query := fmt.Sprintf("SELECT COUNT(*), COALESCE(%s, 0) FROM speedtest_results%s",
+		aggregateExpression(rule.Aggregate, rule.Metric), where)
+
+	var count int
+	var value float64
+	if err := database.DB.QueryRow(ctx, query, args...).Scan(&count, &value); err != nil {
+		return evaluation{}, fmt.Errorf("failed to aggregate results: %w", err)
+	}
+	if count == 0 {
+		return evaluation{}, nil
+	}
+
+	return evaluation{
+		breached:  breaches(rule, value),
+		value:     value,
+		evaluable: true,
+		message: fmt.Sprintf("%s %s over the last %d minutes is %s (threshold %s %s, %d results)",
+			rule.Aggregate, rule.Metric, rule.WindowMinutes, formatValue(value), rule.Operator, formatValue(rule.Threshold), count),
+	}, nil
+}
+
+func evaluateAbsence(ctx context.Context, rule models.AlertRule) (evaluation, error) {
+	where, args := ruleFilter(rule).Where(nil)
+
+	var latest *time.Time
+	if err := database.DB.QueryRow(ctx, "SELECT MAX(timestamp) FROM speedtest_results"+where, args...).Scan(&latest); err != nil {
+		return evaluation{}, fmt.Errorf("failed to fetch latest result: %w", err)
+	}
+
+	window := time.Duration(rule.WindowMinutes) * time.Minute
+	if latest == nil {
+		return evaluation{
+			breached:  true,
+			evaluable: true,
+			message:   fmt.Sprintf("no successful run has ever been recorded (expected one every %d minutes)", rule.WindowMinutes),
+		}, nil
+	}
+
+	minutesSince := time.Since(*latest).Minutes()
+	result := evaluation{
+		breached:  time.Since(*latest) > window,
+		value:     minutesSince,
+		evaluable: true,
+	}
+	if result.breached {
+		result.message = fmt.Sprintf("no successful run in %s minutes (limit %d minutes)", formatValue(minutesSince), rule.WindowMinutes)
+	} else {
+		result.message = fmt.Sprintf("last successful run %s minutes ago", formatValue(minutesSince))
+	}
+	return result, nil
+}
@@ -0,0 +1,178 @@
+package anomaly
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/database"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/filters"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+)
+
+const (
+	// baselineDays and baselineSize bound the history a result is compared with
+	baselineDays = 30
+	baselineSize = 200
+	// minBaseline is the number of earlier results needed before a result is scored
+	minBaseline = 10
+	// threshold is the robust z-score beyond which a metric is an outlier (Iglewicz and Hoaglin)
+	threshold = 3.5
+)
+
+// Detector scores every stored result against the earlier results of the same provider,
+// server and hour of day using a robust z-score based on the median absolute deviation.
+// Only degradations count: low download or upload, and high ping or jitter.
+type Detector struct{}
+
+func (d *Detector) Name() string {
+	return "anomaly"
+}
+
+func (d *Detector) HandleResult(result models.SpeedTestResult) {
+	if result.ID == "" {
+		return
+	}
+	go func() {
+		if err := Score(context.Background(), result); err != nil {
+			log.Printf("Error scoring result %s for anomalies: %v", result.ID, err)
+		}
+	}()
+}
+
+// Score compares a stored result with its baseline and records the outcome on the result row
+func Score(ctx context.Context, result models.SpeedTestResult) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	baseline, err := fetchBaseline(ctx, result)
+	if err != nil {
+		return err
+	}
+	if len(baseline[filters.Metrics[0]]) < minBaseline {
+		return nil
+	}
+
+	score, outliers := degradation(map[string]float64{
+		"download": result.Download,
+		"upload":   result.Upload,
+		"ping":     result.Ping,
+		"jitter":   result.Jitter,
+	}, baseline)
+
+	_, err = database.DB.Exec(ctx, `
+		UPDATE speedtest_results
+		SET is_anomaly = $1, anomaly_score = $2, anomaly_metrics = $3
+		WHERE id = $4
+	`, len(outliers) > 0, score, outliers, result.ID)
+	if err != nil {
+		return fmt.Errorf("failed to store anomaly score: %w", err)
+	}
+
+	if len(outliers) > 0 {
+		log.Printf("Result %s from %s is an anomaly for %v (score %.2f)", result.ID, result.ProviderName, outliers, score)
+	}
+	return nil
+}
+
+// degradation returns the largest degradation score of the metrics and the metrics that degraded
+// beyond threshold. A metric's degradation score is its robust z-score, negated for metrics where
+// higher is better, so it is positive when the metric is worse than its baseline. Improvements
+// are never outliers.
+func degradation(values map[string]float64, baseline map[string][]float64) (float64, []string) {
+	score := math.Inf(-1)
+	outliers := []string{}
+	for _, metric := range filters.Metrics {
+		z, ok := robustZScore(values[metric], baseline[metric])
+		if !ok {
+			continue
+		}
+		if !filters.LowerIsBetter(metric) {
+			z = -z
+		}
+		score = math.Max(score, z)
+		if z > threshold {
+			outliers = append(outliers, metric)
+		}
+	}
+	if math.IsInf(score, -1) {
+		score = 0
+	}
+	return score, outliers
+}
+
+// fetchBaseline loads the metrics of recent earlier results with the same provider, server and hour of day
+func fetchBaseline(ctx context.Context, result models.SpeedTestResult) (map[string][]float64, error) {
+	rows, err := database.DB.Query(ctx, `
+		SELECT download, upload, ping, jitter
+		FROM speedtest_results
+		WHERE provider_id = $1
+		  AND server_name IS NOT DISTINCT FROM $2
+		  AND id <> $3
+		  AND timestamp < $4::timestamptz
+		  AND timestamp >= $4::timestamptz - make_interval(days => $5)
+		  AND EXTRACT(HOUR FROM timestamp) = EXTRACT(HOUR FROM $4::timestamptz)
+		  AND download IS NOT NULL AND upload IS NOT NULL AND ping IS NOT NULL AND jitter IS NOT NULL
+		ORDER BY timestamp DESC
+		LIMIT $6
+	`, result.ProviderID, result.Server.Name, result.ID, result.Timestamp, baselineDays, baselineSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch baseline: %w", err)
+	}
+	defer rows.Close()
+
+	baseline := make(map[string][]float64)
+	for rows.Next() {
+		var download, upload, ping, jitter float64
+		if err := rows.Scan(&download, &upload, &ping, &jitter); err != nil {
+			return nil, fmt.Errorf("failed to scan baseline: %w", err)
+		}
+		baseline["download"] = append(baseline["download"], download)
+		baseline["upload"] = append(baseline["upload"], upload)
+		baseline["ping"] = append(baseline["ping"], ping)
+		baseline["jitter"] = append(baseline["jitter"], jitter)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading baseline: %w", err)
+	}
+	return baseline, nil
+}
+
+// robustZScore returns 0.6745 * (value - median) / MAD. When more than half of the baseline is
+// identical the MAD is zero, so the mean absolute deviation is used instead.
+func robustZScore(value float64, baseline []float64) (float64, bool) {
+	if len(baseline) == 0 {
+		return 0, false
+	}
+
+	m := median(baseline)
+	deviations := make([]float64, len(baseline))
+	var deviationSum float64
+	for i, v := range baseline {
+		deviations[i] = math.Abs(v - m)
+		deviationSum += deviations[i]
+	}
+
+	if mad := median(deviations); mad > 0 {
+		return 0.6745 * (value - m) / mad, true
+	}
+	if meanAD := deviationSum / float64(len(baseline)); meanAD > 0 {
+		return (value - m) / (1.253314 * meanAD), true
+	}
+	return 0, false
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
@@ -0,0 +1,54 @@
+package anomaly
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDegradation(t *testing.T) {
+	baseline := map[string][]float64{
+		"download": {98, 99, 100, 100, 101, 102, 100, 99, 101, 100},
+		"upload":   {19, 20, 20, 21, 20, 19, 21, 20, 20, 20},
+		"ping":     {10, 11, 10, 12, 10, 11, 10, 10, 11, 10},
+		"jitter":   {1, 2, 1, 1, 2, 1, 1, 2, 1, 1},
+	}
+	usual := map[string]float64{"download": 100, "upload": 20, "ping": 10, "jitter": 1}
+
+	tests := []struct {
+		name     string
+		change   map[string]float64
+		outliers []string
+		positive bool
+	}{
+		{name: "usual", outliers: []string{}},
+		{name: "slow download", change: map[string]float64{"download": 40}, outliers: []string{"download"}, positive: true},
+		{name: "fast download", change: map[string]float64{"download": 300}, outliers: []string{}},
+		{name: "slow upload", change: map[string]float64{"upload": 5}, outliers: []string{"upload"}, positive: true},
+		{name: "high ping and jitter", change: map[string]float64{"ping": 80, "jitter": 20}, outliers: []string{"ping", "jitter"}, positive: true},
+		{name: "low ping", change: map[string]float64{"ping": 1}, outliers: []string{}},
+		{name: "improved everywhere", change: map[string]float64{"download": 300, "upload": 60, "ping": 1, "jitter": 0}, outliers: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := make(map[string]float64)
+			for metric, value := range usual {
+				values[metric] = value
+			}
+			for metric, value := range tt.change {
+				values[metric] = value
+			}
+
+			score, outliers := degradation(values, baseline)
+			if !slices.Equal(outliers, tt.outliers) {
+				t.Errorf("outliers = %v, want %v", outliers, tt.outliers)
+			}
+			if tt.positive && score <= threshold {
+				t.Errorf("score = %.2f, want above %v", score, threshold)
+			}
+			if !tt.positive && score > threshold {
+				t.Errorf("score = %.2f, want at most %v", score, threshold)
+			}
+		})
+	}
+}
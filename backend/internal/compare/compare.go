@@ -0,0 +1,264 @@
+package compare
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/database"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/filters"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/retention"
+)
+
+const (
+	defaultWindowMinutes = 5
+	significanceLevel    = 0.05
+)
+
+// sample is one result reduced to the compared metrics. Metrics the result doesn't have are left
+// out of values.
+type sample struct {
+	timestamp time.Time
+	values    map[string]float64
+}
+
+// CompareHandler pairs results of two providers that ran within window minutes of each other and
+// compares them metric by metric. Query parameters: providers=a,b (IDs or names), from, to, window,
+// plus the other result filters such as server, schedule, range and tz. Only raw results can be paired, so
+// raw_data_truncated_before tells when part of the range was rolled up.
+func CompareHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+
+	var providerKeys []string
+	for _, value := range query["providers"] {
+		for _, key := range strings.Split(value, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				providerKeys = append(providerKeys, key)
+			}
+		}
+	}
+	if len(providerKeys) != 2 {
+		http.Error(w, "providers must name exactly two providers, e.g. providers=librespeed,cloudflare", http.StatusBadRequest)
+		return
+	}
+
+	window := defaultWindowMinutes
+	if v := query.Get("window"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 {
+			http.Error(w, fmt.Sprintf("invalid window: %q", v), http.StatusBadRequest)
+			return
+		}
+		window = parsed
+	}
+
+	// from and to stand for startDate and endDate, so they are read in the tz zone and checked
+	// against range like them
+	if from := query.Get("from"); from != "" {
+		query.Set("startDate", from)
+	}
+	if to := query.Get("to"); to != "" {
+		query.Set("endDate", to)
+	}
+	filter, err := filters.ParseResultFilter(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var providers [2]models.Provider
+	for i, key := range providerKeys {
+		provider, err := resolveProvider(r.Context(), key)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, fmt.Sprintf("Provider not found: %q", key), http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		providers[i] = provider
+	}
+	if providers[0].ID == providers[1].ID {
+		http.Error(w, "providers must be two different providers", http.StatusBadRequest)
+		return
+	}
+
+	var series [2][]sample
+	for i, provider := range providers {
+		providerFilter := filter
+		providerFilter.Providers = []string{provider.ID}
+		series[i], err = fetchSamples(r.Context(), providerFilter)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	pairs := pairSamples(series[0], series[1], time.Duration(window)*time.Minute)
+
+	// Pairs need individual results, which the rollups don't have
+	rolledUpFilter := filter
+	rolledUpFilter.Providers = []string{providers[0].ID, providers[1].ID}
+	truncatedBefore, err := retention.TruncatedBefore(r.Context(), rolledUpFilter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := models.CompareResponse{
+		ProviderA:         providers[0],
+		ProviderB:         providers[1],
+		WindowMinutes:     window,
+		Pairs:             len(pairs),
+		Metrics:           make(map[string]models.MetricComparison),
+		RawDataTruncation: models.RawDataTruncation{RawDataTruncatedBefore: truncatedBefore},
+	}
+	for _, metric := range filters.Metrics {
+		if comparison, ok := compareMetric(metric, pairs); ok {
+			response.Metrics[metric] = comparison
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": response,
+	})
+}
+
+// resolveProvider accepts a provider ID or name
+func resolveProvider(ctx context.Context, key string) (models.Provider, error) {
+	var provider models.Provider
+	err := database.DB.QueryRow(ctx, `
+		SELECT id, name FROM providers WHERE id::text = $1 OR name = $1 LIMIT 1
+	`, key).Scan(&provider.ID, &provider.Name)
+	return provider, err
+}
+
+func fetchSamples(ctx context.Context, filter filters.ResultFilter) ([]sample, error) {
+	where, args := filter.Where(nil)
+	rows, err := database.DB.Query(ctx, `
+        SELECT timestamp, download, upload, ping, jitter
+        FROM speedtest_results`+where+`
+        ORDER BY timestamp`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch results: %w", err)
+	}
+	defer rows.Close()
+
+	var samples []sample
+	for rows.Next() {
+		var s sample
+		var download, upload, ping, jitter *float64
+		if err := rows.Scan(&s.timestamp, &download, &upload, &ping, &jitter); err != nil {
+			return nil, fmt.Errorf("failed to scan result: %w", err)
+		}
+		s.values = make(map[string]float64, len(filters.Metrics))
+		for metric, value := range map[string]*float64{"download": download, "upload": upload, "ping": ping, "jitter": jitter} {
+			if value != nil {
+				s.values[metric] = *value
+			}
+		}
+		samples = append(samples, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading results: %w", err)
+	}
+	return samples, nil
+}
+
+// pairSamples matches each result of a with the closest unused result of b within window.
+// Both series must be ordered by timestamp.
+func pairSamples(a, b []sample, window time.Duration) [][2]sample {
+	var pairs [][2]sample
+	used := make([]bool, len(b))
+	start := 0
+
+	for _, left := range a {
+		// Results of b older than the window can never match a later result of a
+		for start < len(b) && b[start].timestamp.Before(left.timestamp.Add(-window)) {
+			start++
+		}
+
+		best := -1
+		var bestGap time.Duration
+		for j := start; j < len(b) && !b[j].timestamp.After(left.timestamp.Add(window)); j++ {
+			if used[j] {
+				continue
+			}
+			gap := b[j].timestamp.Sub(left.timestamp)
+			if gap < 0 {
+				gap = -gap
+			}
+			if best == -1 || gap < bestGap {
+				best, bestGap = j, gap
+			}
+		}
+
+		if best != -1 {
+			used[best] = true
+			pairs = append(pairs, [2]sample{left, b[best]})
+		}
+	}
+	return pairs
+}
+
+// compareMetric compares metric over the pairs where both results have it, or returns false when
+// there are none
+func compareMetric(metric string, pairs [][2]sample) (models.MetricComparison, bool) {
+	var a, b, differences []float64
+	var comparison models.MetricComparison
+	for _, pair := range pairs {
+		valueA, okA := pair[0].values[metric]
+		valueB, okB := pair[1].values[metric]
+		if !okA || !okB {
+			continue
+		}
+		a = append(a, valueA)
+		b = append(b, valueB)
+		differences = append(differences, valueA-valueB)
+
+		switch {
+		case valueA == valueB:
+			comparison.Ties++
+		case (valueA > valueB) != filters.LowerIsBetter(metric):
+			comparison.WinsA++
+		default:
+			comparison.WinsB++
+		}
+	}
+	if len(differences) == 0 {
+		return comparison, false
+	}
+
+	comparison.Pairs = len(differences)
+	n := float64(len(differences))
+	comparison.MeanA = mean(a)
+	comparison.MeanB = mean(b)
+	comparison.MeanDiff = mean(differences)
+	comparison.MedianDiff = median(differences)
+	comparison.WinRateA = float64(comparison.WinsA) / n
+	comparison.WinRateB = float64(comparison.WinsB) / n
+
+	if r, ok := pearson(a, b); ok {
+		comparison.Correlation = &r
+	}
+	if t, p, ok := pairedTTest(differences); ok {
+		comparison.TStatistic = &t
+		comparison.PValue = &p
+		comparison.Significant = p < significanceLevel
+	}
+	return comparison, true
+}
@@ -0,0 +1,140 @@
+package compare
+
+import (
+	"math"
+	"sort"
+)
+
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+// pearson returns the correlation coefficient, or false when either series is constant
+func pearson(a, b []float64) (float64, bool) {
+	meanA, meanB := mean(a), mean(b)
+
+	var covariance, varianceA, varianceB float64
+	for i := range a {
+		da, db := a[i]-meanA, b[i]-meanB
+		covariance += da * db
+		varianceA += da * da
+		varianceB += db * db
+	}
+	if varianceA == 0 || varianceB == 0 {
+		return 0, false
+	}
+	return covariance / math.Sqrt(varianceA*varianceB), true
+}
+
+// pairedTTest tests whether the mean of the paired differences is zero and returns the t statistic
+// and two-sided p-value. It needs at least two pairs whose differences are not all identical.
+func pairedTTest(differences []float64) (float64, float64, bool) {
+	n := float64(len(differences))
+	if n < 2 {
+		return 0, 0, false
+	}
+
+	m := mean(differences)
+	var squares float64
+	for _, d := range differences {
+		squares += (d - m) * (d - m)
+	}
+	standardError := math.Sqrt(squares/(n-1)) / math.Sqrt(n)
+	if standardError == 0 {
+		return 0, 0, false
+	}
+
+	t := m / standardError
+	return t, studentTwoSidedP(t, n-1), true
+}
+
+// studentTwoSidedP is P(|T| >= |t|) for Student's t distribution with df degrees of freedom
+func studentTwoSidedP(t, df float64) float64 {
+	return regularizedIncompleteBeta(df/2, 0.5, df/(df+t*t))
+}
+
+// regularizedIncompleteBeta evaluates I_x(a, b) with the continued fraction from Numerical Recipes
+func regularizedIncompleteBeta(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+
+	lgab, _ := math.Lgamma(a + b)
+	lga, _ := math.Lgamma(a)
+	lgb, _ := math.Lgamma(b)
+	front := math.Exp(lgab - lga - lgb + a*math.Log(x) + b*math.Log(1-x))
+
+	// The continued fraction converges quickly only below the mean of the distribution
+	if x > (a+1)/(a+b+2) {
+		return 1 - front*betaContinuedFraction(b, a, 1-x)/b
+	}
+	return front * betaContinuedFraction(a, b, x) / a
+}
+
+func betaContinuedFraction(a, b, x float64) float64 {
+	const (
+		maxIterations = 300
+		epsilon       = 1e-14
+		tiny          = 1e-300
+	)
+
+	c := 1.0
+	d := 1 - (a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+
+	for m := 1; m <= maxIterations; m++ {
+		fm := float64(m)
+
+		numerator := fm * (b - fm) * x / ((a + 2*fm - 1) * (a + 2*fm))
+		d = 1 + numerator*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + numerator/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+
+		numerator = -(a + fm) * (a + b + fm) * x / ((a + 2*fm) * (a + 2*fm + 1))
+		d = 1 + numerator*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + numerator/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+
+		if math.Abs(delta-1) < epsilon {
+			break
+		}
+	}
+	return h
+}
@@ -0,0 +1,138 @@
+package compare
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+const tolerance = 1e-9
+
+func TestRegularizedIncompleteBeta(t *testing.T) {
+	// Integer a and b reduce to binomial sums, e.g. I_x(2, 3) = P(Binomial(4, x) >= 2)
+	tests := []struct {
+		a, b, x float64
+		want    float64
+	}{
+		{a: 2, b: 3, x: 0, want: 0},
+		{a: 2, b: 3, x: 1, want: 1},
+		{a: 1, b: 1, x: 0.3, want: 0.3},
+		{a: 3, b: 1, x: 0.5, want: 0.125},
+		{a: 2, b: 2, x: 0.2, want: 0.104},
+		{a: 2, b: 3, x: 0.5, want: 0.6875},
+		{a: 2, b: 3, x: 0.9, want: 0.9963},
+		{a: 4.5, b: 4.5, x: 0.5, want: 0.5},
+	}
+
+	for _, tt := range tests {
+		if got := regularizedIncompleteBeta(tt.a, tt.b, tt.x); math.Abs(got-tt.want) > tolerance {
+			t.Errorf("regularizedIncompleteBeta(%v, %v, %v) = %v, want %v", tt.a, tt.b, tt.x, got, tt.want)
+		}
+	}
+}
+
+func TestStudentTwoSidedP(t *testing.T) {
+	tests := []struct {
+		name  string
+		t, df float64
+		want  float64
+	}{
+		{name: "zero", t: 0, df: 7, want: 1},
+		{name: "cauchy", t: 1, df: 1, want: 0.5},
+		{name: "df 2 closed form", t: 2, df: 2, want: 1 - 2/math.Sqrt(6)},
+		{name: "negative t", t: -2, df: 2, want: 1 - 2/math.Sqrt(6)},
+		{name: "5% critical value, df 10", t: 2.228138852, df: 10, want: 0.05},
+		{name: "5% critical value, df 30", t: 2.042272456, df: 30, want: 0.05},
+		{name: "1% critical value, df 5", t: 4.032142983, df: 5, want: 0.01},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Critical values from tables have nine decimals
+			if got := studentTwoSidedP(tt.t, tt.df); math.Abs(got-tt.want) > 1e-8 {
+				t.Errorf("studentTwoSidedP(%v, %v) = %v, want %v", tt.t, tt.df, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPairedTTest(t *testing.T) {
+	tests := []struct {
+		name        string
+		differences []float64
+		wantT       float64
+		wantP       float64
+		ok          bool
+	}{
+		// mean 3, standard error sqrt(2.5/5), and the df 4 closed form for the p-value
+		{name: "increasing", differences: []float64{1, 2, 3, 4, 5}, wantT: math.Sqrt(18), wantP: 0.01323559956368281, ok: true},
+		{name: "symmetric", differences: []float64{-2, -1, 1, 2}, wantT: 0, wantP: 1, ok: true},
+		{name: "single pair", differences: []float64{3}},
+		{name: "constant differences", differences: []float64{2, 2, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotT, gotP, ok := pairedTTest(tt.differences)
+			if ok != tt.ok {
+				t.Fatalf("pairedTTest() ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if math.Abs(gotT-tt.wantT) > tolerance || math.Abs(gotP-tt.wantP) > tolerance {
+				t.Errorf("pairedTTest() = (%v, %v), want (%v, %v)", gotT, gotP, tt.wantT, tt.wantP)
+			}
+		})
+	}
+}
+
+func TestPearson(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float64
+		want float64
+		ok   bool
+	}{
+		{name: "proportional", a: []float64{1, 2, 3}, b: []float64{2, 4, 6}, want: 1, ok: true},
+		{name: "reversed", a: []float64{1, 2, 3}, b: []float64{6, 4, 2}, want: -1, ok: true},
+		{name: "partial", a: []float64{1, 2, 3, 4, 5}, b: []float64{2, 1, 4, 3, 5}, want: 0.8, ok: true},
+		{name: "constant", a: []float64{1, 2, 3}, b: []float64{5, 5, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := pearson(tt.a, tt.b)
+			if ok != tt.ok || math.Abs(got-tt.want) > tolerance {
+				t.Errorf("pearson() = (%v, %v), want (%v, %v)", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestCompareMetricSkipsMissingValues(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	result := func(minutes int, values map[string]float64) sample {
+		return sample{timestamp: start.Add(time.Duration(minutes) * time.Minute), values: values}
+	}
+	pairs := [][2]sample{
+		{result(0, map[string]float64{"download": 100, "jitter": 1}), result(1, map[string]float64{"download": 90})},
+		{result(10, map[string]float64{"download": 80, "jitter": 2}), result(11, map[string]float64{"download": 95, "jitter": 3})},
+		{result(20, map[string]float64{"download": 120}), result(21, map[string]float64{"download": 100, "jitter": 1})},
+	}
+
+	download, ok := compareMetric("download", pairs)
+	if !ok || download.Pairs != 3 || download.WinsA != 2 || download.WinsB != 1 {
+		t.Errorf("download = %+v, ok = %v, want 3 pairs with 2 wins for A", download, ok)
+	}
+
+	// Only the second pair has jitter on both sides, and lower jitter wins
+	jitter, ok := compareMetric("jitter", pairs)
+	if !ok || jitter.Pairs != 1 || jitter.WinsA != 1 || jitter.MeanA != 2 || jitter.MeanB != 3 {
+		t.Errorf("jitter = %+v, ok = %v, want the second pair only", jitter, ok)
+	}
+
+	if _, ok := compareMetric("upload", pairs); ok {
+		t.Error("upload compared although no result has it")
+	}
+}
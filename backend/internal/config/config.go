@@ -0,0 +1,297 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/influxdb"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/mqtt"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/retention"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/speedtest"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the configuration of the backend. Load builds it from, in increasing order of
+// precedence: the defaults, a YAML or TOML file, environment variables and command-line flags.
+type Config struct {
+	ListenAddr string `yaml:"listen_addr" toml:"listen_addr"`
+	// ReadHeaderTimeout limits how long a client may take to send the request headers
+	ReadHeaderTimeout time.Duration            `yaml:"read_header_timeout" toml:"read_header_timeout"`
+	Database          Database                 `yaml:"database" toml:"database"`
+	Providers         speedtest.ProviderConfig `yaml:"providers" toml:"providers"`
+	InfluxDB          influxdb.Config          `yaml:"influxdb" toml:"influxdb"`
+	MQTT              mqtt.Config              `yaml:"mqtt" toml:"mqtt"`
+	Retention         retention.Config         `yaml:"retention" toml:"retention"`
+}
+
+// Database holds the connection settings. DSN, when set, replaces the fields from Host to SSLMode.
+type Database struct {
+	DSN      string `yaml:"dsn" toml:"dsn"`
+	Host     string `yaml:"host" toml:"host"`
+	Port     string `yaml:"port" toml:"port"`
+	User     string `yaml:"user" toml:"user"`
+	Password string `yaml:"password" toml:"password"`
+	Name     string `yaml:"name" toml:"name"`
+	// SSLMode is the libpq sslmode; empty uses the driver default
+	SSLMode  string `yaml:"sslmode" toml:"sslmode"`
+	MaxConns int    `yaml:"max_conns" toml:"max_conns"`
+}
+
+// sslModes lists the sslmode values accepted by the driver
+var sslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// Default returns the configuration used when nothing is set
+func Default() Config {
+	return Config{
+		ListenAddr:        ":8080",
+		ReadHeaderTimeout: 10 * time.Second,
+		Database: Database{
+			Port:     "5432",
+			MaxConns: 10,
+		},
+		Providers: speedtest.DefaultProviderConfig(),
+		InfluxDB:  influxdb.DefaultConfig(),
+		MQTT:      mqtt.DefaultConfig(),
+		Retention: retention.DefaultConfig(),
+	}
+}
+
+// ConnString returns the connection string for the database
+func (d Database) ConnString() string {
+	if d.DSN != "" {
+		return d.DSN
+	}
+	u := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(d.User, d.Password),
+		Host:   d.Host + ":" + d.Port,
+		Path:   "/" + d.Name,
+	}
+	if d.SSLMode != "" {
+		u.RawQuery = url.Values{"sslmode": {d.SSLMode}}.Encode()
+	}
+	return u.String()
+}
+
+// binding ties a setting to the environment variable and command-line flag that override it
+type binding struct {
+	env    string
+	target interface{}
+	usage  string
+}
+
+// flagName derives the flag of a binding from its environment variable, e.g. DB_HOST becomes -db-host
+func (b binding) flagName() string {
+	return strings.ToLower(strings.ReplaceAll(b.env, "_", "-"))
+}
+
+func (c *Config) bindings() []binding {
+	return []binding{
+		{"LISTEN_ADDR", &c.ListenAddr, "address the HTTP server listens on"},
+		{"READ_HEADER_TIMEOUT", &c.ReadHeaderTimeout, "time allowed to read request headers"},
+
+		{"DB_DSN", &c.Database.DSN, "database connection string, used instead of the other DB settings"},
+		{"DB_HOST", &c.Database.Host, "database host"},
+		{"DB_PORT", &c.Database.Port, "database port"},
+		{"DB_USER", &c.Database.User, "database user"},
+		{"DB_PASSWORD", &c.Database.Password, "database password"},
+		{"DB_NAME", &c.Database.Name, "database name"},
+		{"DB_SSLMODE", &c.Database.SSLMode, "database sslmode"},
+		{"DB_MAX_CONNS", &c.Database.MaxConns, "maximum number of database connections"},
+
+		{"LIBRESPEED_PATH", &c.Providers.LibrespeedPath, "path of the librespeed-cli binary"},
+		{"IPERF3_PATH", &c.Providers.Iperf3Path, "path of the iperf3 binary"},
+		{"PING_PATH", &c.Providers.PingPath, "path of the ping binary"},
+		{"NODE_BACKEND_URL", &c.Providers.NodeBackendURL, "URL of the Node.js backend running Cloudflare tests"},
+		{"RUN_TIMEOUT", &c.Providers.RunTimeout, "maximum duration of a provider run, 0 for none"},
+
+		{"INFLUXDB_URL", &c.InfluxDB.URL, "InfluxDB URL, enables the InfluxDB sink"},
+		{"INFLUXDB_TOKEN", &c.InfluxDB.Token, "InfluxDB token"},
+		{"INFLUXDB_ORG", &c.InfluxDB.Org, "InfluxDB organization"},
+		{"INFLUXDB_BUCKET", &c.InfluxDB.Bucket, "InfluxDB bucket"},
+		{"INFLUXDB_BATCH_SIZE", &c.InfluxDB.BatchSize, "number of points written per InfluxDB request"},
+		{"INFLUXDB_FLUSH_INTERVAL", &c.InfluxDB.FlushInterval, "maximum time points wait before they are written"},
+		{"INFLUXDB_MAX_RETRIES", &c.InfluxDB.MaxRetries, "retries of a failed InfluxDB write"},
+		{"INFLUXDB_TIMEOUT", &c.InfluxDB.Timeout, "timeout of an InfluxDB write"},
+
+		{"MQTT_BROKER", &c.MQTT.Broker, "MQTT broker URL, enables the MQTT publisher"},
+		{"MQTT_USERNAME", &c.MQTT.Username, "MQTT username"},
+		{"MQTT_PASSWORD", &c.MQTT.Password, "MQTT password"},
+		{"MQTT_CLIENT_ID", &c.MQTT.ClientID, "MQTT client ID"},
+		{"MQTT_TOPIC_PREFIX", &c.MQTT.TopicPrefix, "prefix of the published topics"},
+		{"MQTT_DISCOVERY_PREFIX", &c.MQTT.DiscoveryPrefix, "Home Assistant discovery prefix"},
+		{"MQTT_DISCOVERY", &c.MQTT.Discovery, "publish Home Assistant discovery messages"},
+		{"MQTT_QOS", &c.MQTT.QoS, "MQTT QoS level"},
+		{"MQTT_RETAIN", &c.MQTT.Retain, "retain published results"},
+
+		{"RETENTION_RAW_DAYS", &c.Retention.RawDays, "days raw results are kept before they are rolled up, 0 to keep them"},
+		{"RETENTION_MAX_AGE_DAYS", &c.Retention.MaxAgeDays, "days results without a schedule are kept, 0 to keep them"},
+		{"RETENTION_INTERVAL", &c.Retention.Interval, "interval of the retention job"},
+	}
+}
+
+// Load builds the configuration from the command-line arguments (without the program name), the
+// environment and the file named by -config or CONFIG_FILE, and validates it
+func Load(args []string) (Config, error) {
+	c := Default()
+	bindings := c.bindings()
+
+	// Flags are collected first and applied last, since they take precedence over the file and environment
+	flags := flag.NewFlagSet("backend", flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML configuration file")
+	flagValues := make(map[string]string)
+	for _, b := range bindings {
+		name := b.flagName()
+		flags.Func(name, fmt.Sprintf("%s (env %s)", b.usage, b.env), func(value string) error {
+			flagValues[name] = value
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return c, err
+	}
+
+	if *configFile != "" {
+		if err := c.loadFile(*configFile); err != nil {
+			return c, err
+		}
+	}
+
+	for _, b := range bindings {
+		if value := os.Getenv(b.env); value != "" {
+			if err := setValue(b.target, value); err != nil {
+				return c, fmt.Errorf("invalid %s: %w", b.env, err)
+			}
+		}
+	}
+	for _, b := range bindings {
+		if value, ok := flagValues[b.flagName()]; ok {
+			if err := setValue(b.target, value); err != nil {
+				return c, fmt.Errorf("invalid -%s: %w", b.flagName(), err)
+			}
+		}
+	}
+
+	return c, c.Validate()
+}
+
+// loadFile decodes a YAML (.yaml, .yml) or TOML (.toml) file over c. Unknown keys are rejected, so
+// misspelled settings don't go unnoticed.
+func (c *Config) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer file.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(file)
+		decoder.KnownFields(true)
+		if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	case ".toml":
+		metadata, err := toml.NewDecoder(file).Decode(c)
+		if err != nil {
+			return fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+		if undecoded := metadata.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("invalid config file %s: unknown key %s", path, undecoded[0])
+		}
+	default:
+		return fmt.Errorf("unsupported config file %s: expected .yaml, .yml or .toml", path)
+	}
+	return nil
+}
+
+// setValue parses value into the setting target points to
+func setValue(target interface{}, value string) error {
+	switch t := target.(type) {
+	case *string:
+		*t = value
+	case *int:
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		*t = i
+	case *uint8:
+		i, err := strconv.ParseUint(value, 10, 8)
+		if err != nil {
+			return fmt.Errorf("%q is not a small integer", value)
+		}
+		*t = uint8(i)
+	case *bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+		*t = b
+	case *time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration", value)
+		}
+		*t = d
+	default:
+		return fmt.Errorf("unsupported setting type %T", target)
+	}
+	return nil
+}
+
+// Validate checks every section and reports all problems at once
+func (c Config) Validate() error {
+	var errs []error
+	if c.ListenAddr == "" {
+		errs = append(errs, errors.New("listen address is required"))
+	}
+	if c.ReadHeaderTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("invalid read header timeout: %s", c.ReadHeaderTimeout))
+	}
+	if err := c.Database.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("database: %w", err))
+	}
+	if err := c.Providers.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("providers: %w", err))
+	}
+	if err := c.InfluxDB.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("influxdb: %w", err))
+	}
+	if err := c.MQTT.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("mqtt: %w", err))
+	}
+	if err := c.Retention.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("retention: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks the database settings
+func (d Database) Validate() error {
+	if d.DSN == "" && (d.Host == "" || d.Name == "") {
+		return errors.New("either a DSN or a host and name are required")
+	}
+	if d.SSLMode != "" {
+		valid := false
+		for _, mode := range sslModes {
+			valid = valid || d.SSLMode == mode
+		}
+		if !valid {
+			return fmt.Errorf("invalid sslmode %q (expected %s)", d.SSLMode, strings.Join(sslModes, ", "))
+		}
+	}
+	if d.MaxConns <= 0 {
+		return fmt.Errorf("invalid max conns: %d", d.MaxConns)
+	}
+	return nil
+}
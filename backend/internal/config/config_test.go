@@ -0,0 +1,144 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+listen_addr: ":9000"
+database:
+  host: file-host
+  name: botb
+  max_conns: 4
+influxdb:
+  batch_size: 50
+`)
+	t.Setenv("DB_HOST", "env-host")
+	t.Setenv("INFLUXDB_BATCH_SIZE", "75")
+	t.Setenv("RUN_TIMEOUT", "5m")
+
+	c, err := Load([]string{"-config", path, "-db-host", "flag-host", "-mqtt-retain=false"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if c.ListenAddr != ":9000" || c.Database.Name != "botb" || c.Database.MaxConns != 4 {
+		t.Errorf("file settings not applied: %+v", c)
+	}
+	if c.Database.Port != "5432" {
+		t.Errorf("port = %q, want the default", c.Database.Port)
+	}
+	if c.InfluxDB.BatchSize != 75 || c.Providers.RunTimeout != 5*time.Minute {
+		t.Errorf("environment did not override the file: batch size %d, run timeout %s", c.InfluxDB.BatchSize, c.Providers.RunTimeout)
+	}
+	if c.Database.Host != "flag-host" || c.MQTT.Retain {
+		t.Errorf("flags did not override the environment: host %q, retain %v", c.Database.Host, c.MQTT.Retain)
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	path := writeFile(t, "config.toml", `
+[database]
+dsn = "postgres://botb@db/botb"
+
+[retention]
+raw_days = 90
+`)
+	c, err := Load([]string{"-config", path})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if c.Database.ConnString() != "postgres://botb@db/botb" || c.Retention.RawDays != 90 {
+		t.Errorf("config = %+v", c)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		args    []string
+		env     map[string]string
+		wantErr []string
+	}{
+		{
+			name:    "unknown YAML key",
+			file:    writeFile(t, "config.yml", "databse:\n  host: db\n"),
+			wantErr: []string{"databse"},
+		},
+		{
+			name:    "unknown TOML key",
+			file:    writeFile(t, "config.toml", "[database]\nhots = \"db\"\n"),
+			wantErr: []string{"unknown key database.hots"},
+		},
+		{
+			name:    "unsupported file type",
+			file:    writeFile(t, "config.json", "{}"),
+			wantErr: []string{"expected .yaml, .yml or .toml"},
+		},
+		{
+			name:    "invalid environment value",
+			args:    []string{"-db-host", "db", "-db-name", "botb"},
+			env:     map[string]string{"DB_MAX_CONNS": "many"},
+			wantErr: []string{"invalid DB_MAX_CONNS", `"many" is not an integer`},
+		},
+		{
+			name:    "invalid flag value",
+			args:    []string{"-db-host", "db", "-db-name", "botb", "-retention-interval", "daily"},
+			wantErr: []string{"invalid -retention-interval", `"daily" is not a duration`},
+		},
+		{
+			name:    "every problem is reported",
+			args:    []string{"-listen-addr", "", "-db-sslmode", "sometimes"},
+			wantErr: []string{"listen address is required", "database: either a DSN or a host and name are required"},
+		},
+		{
+			name:    "invalid sslmode",
+			args:    []string{"-db-host", "db", "-db-name", "botb", "-db-sslmode", "sometimes"},
+			wantErr: []string{`invalid sslmode "sometimes"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", tt.file}, args...)
+			}
+
+			_, err := Load(args)
+			if err == nil {
+				t.Fatalf("Load() error = nil, want %q", tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Load() error = %v, want it to mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestConnString(t *testing.T) {
+	d := Database{Host: "db", Port: "5433", User: "botb", Password: "p@ss word", Name: "results", SSLMode: "require"}
+	if got, want := d.ConnString(), "postgres://botb:p%40ss%20word@db:5433/results?sslmode=require"; got != want {
+		t.Errorf("ConnString() = %q, want %q", got, want)
+	}
+}
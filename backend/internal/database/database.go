@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -12,23 +11,17 @@ import (
 
 var DB *pgxpool.Pool
 
-func InitDB() error {
-	dbHost := os.Getenv("DB_HOST")
-	dbPort := os.Getenv("DB_PORT")
-	dbUser := os.Getenv("DB_USER")
-	dbPassword := os.Getenv("DB_PASSWORD")
-	dbName := os.Getenv("DB_NAME")
-
-	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?pool_max_conns=10",
-		dbUser, dbPassword, dbHost, dbPort, dbName)
-
+// InitDB connects to the database at connString with a pool of at most maxConns connections,
+// retrying while the database starts up
+func InitDB(connString string, maxConns int) error {
 	var err error
 	for retries := 5; retries > 0; retries-- {
-		config, err := pgxpool.ParseConfig(connStr)
+		config, err := pgxpool.ParseConfig(connString)
 		if err != nil {
 			return fmt.Errorf("unable to parse connection string: %w", err)
 		}
 
+		config.MaxConns = int32(maxConns)
 		config.MaxConnLifetime = time.Hour
 		config.MaxConnIdleTime = 30 * time.Minute
 		config.HealthCheckPeriod = 1 * time.Minute
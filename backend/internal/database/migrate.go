@@ -126,10 +126,12 @@ func executeMigration(ctx context.Context, m migration) error {
 		return fmt.Errorf("%w: %v", ErrMigrationFailed, err)
 	}
 
+	// The version of the applied migration itself is stored; see appliedChecks for databases that
+	// recorded one more
 	if _, err = tx.Exec(ctx, `
 		UPDATE database_metadata 
 		SET version = $1
-	`, m.version+1); err != nil {
+	`, m.version); err != nil {
 		return fmt.Errorf("failed to update database version: %w", err)
 	}
 
@@ -140,6 +142,67 @@ func executeMigration(ctx context.Context, m migration) error {
 	return nil
 }
 
+// Until the bulk operations change, executeMigration stored the version of the migration it applied
+// plus one. Databases migrated by that code record a version whose migration never ran, and every
+// restart after new migrations were added skipped the first of them. appliedChecks detects this: each
+// entry reports whether the schema already has the effect of that migration. Every migration needs an
+// entry, which TestEveryMigrationHasAppliedCheck enforces.
+var appliedChecks = map[int]string{
+	1:  `SELECT to_regclass('public.database_metadata') IS NOT NULL`,
+	2:  `SELECT to_regclass('public.schedules') IS NOT NULL`,
+	3:  `SELECT EXISTS (SELECT 1 FROM providers WHERE name = 'iperf3')`,
+	4:  columnExists("schedules", "result_limit"),
+	5:  `SELECT to_regclass('public.chart_colors') IS NOT NULL`,
+	6:  columnExists("speedtest_results", "tags"),
+	7:  `SELECT to_regclass('public.webhook_deliveries') IS NOT NULL`,
+	8:  `SELECT to_regclass('public.alert_events') IS NOT NULL`,
+	9:  `SELECT to_regclass('public.isp_plans') IS NOT NULL`,
+	10: `SELECT to_regclass('public.notification_channels') IS NOT NULL`,
+	11: columnExists("speedtest_results", "anomaly_metrics"),
+	12: `SELECT to_regclass('public.rating_history') IS NOT NULL`,
+	13: `SELECT to_regclass('public.result_rollups_daily') IS NOT NULL`,
+	14: columnExists("schedules", "max_age_days"),
+	15: columnExists("schedules", "archived_at"),
+	16: columnExists("speedtest_results", "client_interface"),
+	17: `SELECT to_regclass('public.settings') IS NOT NULL`,
+	18: `SELECT COALESCE(col_description('speedtest_results'::regclass, (
+			SELECT attnum FROM pg_attribute WHERE attrelid = 'speedtest_results'::regclass AND attname = 'anomaly_score'
+		)) LIKE 'Largest degradation score%', false)`,
+	19: columnExists("result_rollups_daily", "sum_squares"),
+}
+
+func columnExists(table, column string) string {
+	return fmt.Sprintf(`
+		SELECT EXISTS (
+			SELECT FROM information_schema.columns
+			WHERE table_schema = 'public' AND table_name = '%s' AND column_name = '%s'
+		)`, table, column)
+}
+
+// repairLegacyVersion moves a version recorded one too high back to the migration that was really
+// applied last. It only steps back once, since the old code was off by exactly one.
+func repairLegacyVersion(ctx context.Context, currentVersion int) (int, error) {
+	check, ok := appliedChecks[currentVersion]
+	if !ok {
+		return currentVersion, nil
+	}
+
+	var applied bool
+	if err := DB.QueryRow(ctx, check).Scan(&applied); err != nil {
+		return currentVersion, fmt.Errorf("failed to check whether migration %d was applied: %w", currentVersion, err)
+	}
+	if applied {
+		return currentVersion, nil
+	}
+
+	repaired := currentVersion - 1
+	if _, err := DB.Exec(ctx, "UPDATE database_metadata SET version = $1", repaired); err != nil {
+		return currentVersion, fmt.Errorf("failed to repair database version: %w", err)
+	}
+	log.Printf("Schema version %d was recorded before migration %d was applied, repaired to %d", currentVersion, currentVersion, repaired)
+	return repaired, nil
+}
+
 func MigrateDB() error {
 	ctx := context.Background()
 
@@ -147,6 +210,10 @@ func MigrateDB() error {
 	if err != nil {
 		return err
 	}
+	currentVersion, err = repairLegacyVersion(ctx, currentVersion)
+	if err != nil {
+		return err
+	}
 	fmt.Println("Current schema version:", currentVersion)
 
 	migrations, err := loadMigrations()
@@ -0,0 +1,22 @@
+package database
+
+import "testing"
+
+func TestEveryMigrationHasAppliedCheck(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validateMigrations(migrations); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, m := range migrations {
+		if _, ok := appliedChecks[m.version]; !ok {
+			t.Errorf("migration %s has no entry in appliedChecks", m.name)
+		}
+	}
+	if len(appliedChecks) != len(migrations) {
+		t.Errorf("appliedChecks has %d entries for %d migrations", len(appliedChecks), len(migrations))
+	}
+}
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS notification_channels (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(255) NOT NULL,
+    channel_type VARCHAR(20) NOT NULL,
+    config JSONB NOT NULL DEFAULT '{}',
+    event_types TEXT[] NOT NULL DEFAULT '{}',
+    title_template TEXT NOT NULL DEFAULT '',
+    body_template TEXT NOT NULL DEFAULT '',
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+COMMENT ON COLUMN notification_channels.channel_type IS 'ntfy, gotify, slack, discord or smtp';
+COMMENT ON COLUMN notification_channels.title_template IS 'Go text/template for the message title. Empty uses the default for the event type.';
@@ -0,0 +1,7 @@
+ALTER TABLE speedtest_results ADD COLUMN IF NOT EXISTS is_anomaly BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE speedtest_results ADD COLUMN IF NOT EXISTS anomaly_score NUMERIC;
+ALTER TABLE speedtest_results ADD COLUMN IF NOT EXISTS anomaly_metrics TEXT[] NOT NULL DEFAULT '{}';
+COMMENT ON COLUMN speedtest_results.anomaly_score IS 'Largest robust z-score of the result metrics against the baseline for the same provider, server and hour of day. NULL when there was not enough history to score it.';
+
+CREATE INDEX IF NOT EXISTS idx_speedtest_results_anomalies ON speedtest_results (timestamp DESC) WHERE is_anomaly;
+CREATE INDEX IF NOT EXISTS idx_speedtest_results_baseline ON speedtest_results (provider_id, server_name, timestamp DESC);
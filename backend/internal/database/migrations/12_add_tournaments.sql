@@ -0,0 +1,27 @@
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS tournament_providers TEXT[] NOT NULL DEFAULT '{}';
+COMMENT ON COLUMN schedules.tournament_providers IS 'Providers run back-to-back in randomized order as one tournament round. Empty for ordinary schedules.';
+
+ALTER TABLE speedtest_results ADD COLUMN IF NOT EXISTS round_id UUID;
+CREATE INDEX IF NOT EXISTS idx_speedtest_results_round_id ON speedtest_results (round_id) WHERE round_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS ratings (
+    subject_type TEXT NOT NULL CHECK (subject_type IN ('provider', 'server')),
+    subject TEXT NOT NULL,
+    metric TEXT NOT NULL,
+    rating NUMERIC NOT NULL,
+    games INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (subject_type, subject, metric)
+);
+
+CREATE TABLE IF NOT EXISTS rating_history (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    round_id UUID NOT NULL,
+    subject_type TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    metric TEXT NOT NULL,
+    rating NUMERIC NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_rating_history_subject ON rating_history (subject_type, metric, subject, created_at);
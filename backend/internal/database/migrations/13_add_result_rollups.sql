@@ -0,0 +1,25 @@
+-- Hourly and daily summaries of raw results that were removed by the retention job.
+-- timestamp is the start of the bucket, so the result filters apply to rollups unchanged.
+CREATE TABLE IF NOT EXISTS result_rollups_hourly (
+    timestamp     TIMESTAMPTZ NOT NULL,
+    provider_id   UUID,
+    provider_name VARCHAR(255) NOT NULL DEFAULT '',
+    server_name   TEXT NOT NULL DEFAULT '',
+    schedule_id   UUID,
+    metric        TEXT NOT NULL,
+    sample_count  BIGINT NOT NULL,
+    min_value     NUMERIC NOT NULL,
+    avg_value     NUMERIC NOT NULL,
+    max_value     NUMERIC NOT NULL,
+    p50           NUMERIC NOT NULL,
+    p90           NUMERIC NOT NULL,
+    p95           NUMERIC NOT NULL,
+    p99           NUMERIC NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS result_rollups_daily (LIKE result_rollups_hourly INCLUDING ALL);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_result_rollups_hourly_key ON result_rollups_hourly
+    (timestamp, metric, (COALESCE(provider_id::text, '')), provider_name, server_name, (COALESCE(schedule_id::text, '')));
+CREATE UNIQUE INDEX IF NOT EXISTS idx_result_rollups_daily_key ON result_rollups_daily
+    (timestamp, metric, (COALESCE(provider_id::text, '')), provider_name, server_name, (COALESCE(schedule_id::text, '')));
@@ -0,0 +1,4 @@
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS max_age_days INTEGER NOT NULL DEFAULT 0;
+COMMENT ON COLUMN schedules.max_age_days IS 'Results of the schedule older than this many days are deleted by the retention job. 0 keeps them forever.';
+
+CREATE INDEX IF NOT EXISTS idx_speedtest_results_schedule_timestamp ON speedtest_results (schedule_id, timestamp);
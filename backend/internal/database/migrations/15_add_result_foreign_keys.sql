@@ -0,0 +1,33 @@
+-- Results of deleted schedules are detached before the foreign key can be added
+UPDATE speedtest_results SET schedule_id = NULL
+WHERE schedule_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM schedules WHERE schedules.id = speedtest_results.schedule_id);
+
+-- Provider names are resolved through provider_id from now on, so results that only carry a name are linked first
+UPDATE speedtest_results SET provider_id = providers.id
+FROM providers
+WHERE speedtest_results.provider_id IS NULL AND speedtest_results.provider_name = providers.name;
+
+UPDATE speedtest_results SET provider_id = NULL
+WHERE provider_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM providers WHERE providers.id = speedtest_results.provider_id);
+
+ALTER TABLE speedtest_results
+    ADD CONSTRAINT speedtest_results_schedule_id_fkey FOREIGN KEY (schedule_id) REFERENCES schedules (id) ON DELETE SET NULL;
+ALTER TABLE speedtest_results
+    ADD CONSTRAINT speedtest_results_provider_id_fkey FOREIGN KEY (provider_id) REFERENCES providers (id);
+
+ALTER TABLE speedtest_results DROP COLUMN provider_name;
+ALTER TABLE result_rollups_hourly DROP COLUMN provider_name;
+ALTER TABLE result_rollups_daily DROP COLUMN provider_name;
+
+-- Dropping provider_name also dropped the rollup keys
+CREATE UNIQUE INDEX IF NOT EXISTS idx_result_rollups_hourly_key ON result_rollups_hourly
+    (timestamp, metric, (COALESCE(provider_id::text, '')), server_name, (COALESCE(schedule_id::text, '')));
+CREATE UNIQUE INDEX IF NOT EXISTS idx_result_rollups_daily_key ON result_rollups_daily
+    (timestamp, metric, (COALESCE(provider_id::text, '')), server_name, (COALESCE(schedule_id::text, '')));
+
+CREATE INDEX IF NOT EXISTS idx_speedtest_results_timestamp ON speedtest_results (timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_speedtest_results_schedule_id ON speedtest_results (schedule_id);
+CREATE INDEX IF NOT EXISTS idx_speedtest_results_provider_id ON speedtest_results (provider_id);
+
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP WITH TIME ZONE;
+COMMENT ON COLUMN schedules.archived_at IS 'Set when the schedule was archived instead of deleted. Archived schedules no longer run, but keep their results.';
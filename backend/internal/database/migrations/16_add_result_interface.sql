@@ -0,0 +1,4 @@
+ALTER TABLE speedtest_results ADD COLUMN IF NOT EXISTS client_interface TEXT NOT NULL DEFAULT '';
+COMMENT ON COLUMN speedtest_results.client_interface IS 'Name of the network interface the test ran on, when the provider reports it.';
+
+CREATE INDEX IF NOT EXISTS idx_speedtest_results_client_ip ON speedtest_results (client_ip);
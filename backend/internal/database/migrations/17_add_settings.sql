@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS settings (
+    id                   UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    default_units        TEXT NOT NULL DEFAULT 'mbps',
+    default_filters      JSONB NOT NULL DEFAULT '{}',
+    default_timezone     TEXT NOT NULL DEFAULT '',
+    default_result_limit INTEGER NOT NULL DEFAULT 20,
+    paused               BOOLEAN NOT NULL DEFAULT false,
+    created_at           TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at           TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+COMMENT ON TABLE settings IS 'Application-wide settings. The table always holds exactly one row.';
+COMMENT ON COLUMN settings.default_timezone IS 'IANA time zone used when a request has no tz parameter. Empty uses the server time zone.';
+COMMENT ON COLUMN settings.paused IS 'Stops every scheduled run while true. Manual runs are unaffected.';
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_settings_singleton ON settings ((true));
+
+INSERT INTO settings (id) SELECT uuid_generate_v4() WHERE NOT EXISTS (SELECT 1 FROM settings);
@@ -0,0 +1 @@
+COMMENT ON COLUMN speedtest_results.anomaly_score IS 'Largest degradation score of the result metrics: the robust z-score against the baseline for the same provider, server and hour of day, negated for download and upload, so it is positive when a metric is worse than usual. NULL when there was not enough history to score it.';
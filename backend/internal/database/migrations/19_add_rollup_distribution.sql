@@ -0,0 +1,14 @@
+-- Statistics and the heatmap read the rollups as well, so they keep the percentiles and the sum of
+-- squares those need. Rollups written before this migration don't have them and stay NULL.
+ALTER TABLE result_rollups_hourly
+    ADD COLUMN IF NOT EXISTS p5 NUMERIC,
+    ADD COLUMN IF NOT EXISTS p25 NUMERIC,
+    ADD COLUMN IF NOT EXISTS p75 NUMERIC,
+    ADD COLUMN IF NOT EXISTS sum_squares NUMERIC;
+ALTER TABLE result_rollups_daily
+    ADD COLUMN IF NOT EXISTS p5 NUMERIC,
+    ADD COLUMN IF NOT EXISTS p25 NUMERIC,
+    ADD COLUMN IF NOT EXISTS p75 NUMERIC,
+    ADD COLUMN IF NOT EXISTS sum_squares NUMERIC;
+COMMENT ON COLUMN result_rollups_hourly.sum_squares IS 'Sum of the squared values, from which statistics derive the standard deviation. NULL for rollups written before it was added.';
+COMMENT ON COLUMN result_rollups_daily.sum_squares IS 'Sum of the squared values, from which statistics derive the standard deviation. NULL for rollups written before it was added.';
@@ -0,0 +1,4 @@
+ALTER TABLE speedtest_results ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+COMMENT ON COLUMN speedtest_results.tags IS 'Free-form labels applied to results, e.g. through bulk operations.';
+
+CREATE INDEX IF NOT EXISTS idx_speedtest_results_tags ON speedtest_results USING GIN (tags);
@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(255) NOT NULL,
+    rule_type VARCHAR(20) NOT NULL,
+    metric VARCHAR(20),
+    operator VARCHAR(2),
+    threshold NUMERIC,
+    consecutive INTEGER NOT NULL DEFAULT 1,
+    window_minutes INTEGER NOT NULL DEFAULT 0,
+    aggregate VARCHAR(10),
+    schedule_id UUID,
+    provider_id UUID,
+    server_name TEXT,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    state VARCHAR(20) NOT NULL DEFAULT 'ok',
+    last_value NUMERIC,
+    last_evaluated_at TIMESTAMP WITH TIME ZONE,
+    state_changed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+COMMENT ON COLUMN alert_rules.rule_type IS 'consecutive: last N results breach the threshold; window: an aggregate over the last window_minutes breaches it; absence: no result within window_minutes.';
+
+CREATE TABLE IF NOT EXISTS alert_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    rule_id UUID NOT NULL REFERENCES alert_rules (id) ON DELETE CASCADE,
+    state VARCHAR(20) NOT NULL,
+    value NUMERIC,
+    message TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_events_rule_id ON alert_events (rule_id, created_at DESC);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS isp_plans (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(255) NOT NULL,
+    download_mbps NUMERIC NOT NULL,
+    upload_mbps NUMERIC NOT NULL,
+    latency_sla_ms NUMERIC,
+    effective_from TIMESTAMP WITH TIME ZONE NOT NULL,
+    effective_to TIMESTAMP WITH TIME ZONE,
+    schedule_ids UUID[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+COMMENT ON COLUMN isp_plans.schedule_ids IS 'Schedules the plan applies to. An empty list applies the plan to every schedule that has no plan of its own.';
+COMMENT ON COLUMN isp_plans.effective_to IS 'End of the plan, exclusive. NULL means the plan is still in effect.';
+
+CREATE INDEX IF NOT EXISTS idx_isp_plans_effective_from ON isp_plans (effective_from DESC);
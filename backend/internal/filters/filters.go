@@ -0,0 +1,379 @@
+package filters
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metrics lists the numeric result columns that can be used in threshold filters
+var Metrics = []string{"download", "upload", "ping", "jitter"}
+
+// LowerIsBetter reports whether the smaller value of a metric is the better result
+func LowerIsBetter(metric string) bool {
+	return metric == "ping" || metric == "jitter"
+}
+
+// ProviderJoin resolves the provider of a result, or of a rollup, as provider. Queries that group by
+// GroupColumn must include it.
+const ProviderJoin = `
+        LEFT JOIN providers provider ON provider.id = provider_id`
+
+// groupColumns maps the groupBy parameter of the summary endpoints to the column results are grouped by
+var groupColumns = map[string]string{
+	"provider": "provider.name",
+	"server":   "server_name",
+	"schedule": "schedule_id::text",
+}
+
+// GroupColumn returns the SQL expression for a groupBy value, which is safe to interpolate
+func GroupColumn(groupBy string) (string, bool) {
+	column, ok := groupColumns[groupBy]
+	return column, ok
+}
+
+// Ranges lists the values of the range parameter, which select results from a point relative to now
+// up to now. "mtd" starts at the beginning of the current month in the requested time zone.
+var Ranges = []string{"24h", "7d", "30d", "mtd"}
+
+var (
+	defaultLocation      *time.Location
+	defaultLocationMutex sync.RWMutex
+
+	defaults      Defaults
+	defaultsMutex sync.RWMutex
+)
+
+// Defaults are the filters ParseResultFilterWithDefaults applies to requests that leave them out
+type Defaults struct {
+	Range     string
+	Providers []string
+	Servers   []string
+	Schedules []string
+}
+
+// SetDefaults changes the filters applied to requests that leave them out
+func SetDefaults(d Defaults) {
+	defaultsMutex.Lock()
+	defer defaultsMutex.Unlock()
+	defaults = d
+}
+
+// SetDefaultTimezone changes the time zone used when a request has no tz parameter. An empty or
+// unknown name restores the server time zone.
+func SetDefaultTimezone(name string) {
+	var location *time.Location
+	if name != "" {
+		location, _ = ParseLocation(name)
+	}
+	defaultLocationMutex.Lock()
+	defer defaultLocationMutex.Unlock()
+	defaultLocation = location
+}
+
+// DefaultLocation returns the time zone used when a request has no tz parameter: the one set through
+// SetDefaultTimezone, otherwise the server time zone set through TZ, or UTC
+func DefaultLocation() *time.Location {
+	defaultLocationMutex.RLock()
+	location := defaultLocation
+	defaultLocationMutex.RUnlock()
+	if location != nil {
+		return location
+	}
+
+	if location, err := time.LoadLocation(os.Getenv("TZ")); err == nil {
+		return location
+	}
+	return time.UTC
+}
+
+// ParseLocation reads an IANA time zone name, defaulting to DefaultLocation when it is empty
+func ParseLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return DefaultLocation(), nil
+	}
+	// "Local" is accepted by Go but not by Postgres, which also receives the zone name
+	if name == "Local" {
+		return nil, fmt.Errorf("invalid tz: %q", name)
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tz: %q", name)
+	}
+	return location, nil
+}
+
+// RangeStart returns the start of a relative range ending at now. Days are calendar days in the
+// location of now, so a range keeps its length across daylight saving changes.
+func RangeStart(value string, now time.Time) (time.Time, error) {
+	switch value {
+	case "24h":
+		return now.Add(-24 * time.Hour), nil
+	case "7d":
+		return now.AddDate(0, 0, -7), nil
+	case "30d":
+		return now.AddDate(0, 0, -30), nil
+	case "mtd":
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid range: %q (expected %s)", value, strings.Join(Ranges, ", "))
+}
+
+// localDateLayouts are the date formats without an offset accepted by startDate and endDate
+var localDateLayouts = []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+// inLocation adds the offset of location to a date that has none, and returns any other value unchanged
+func inLocation(value string, location *time.Location) string {
+	for _, layout := range localDateLayouts {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return t.Format(time.RFC3339)
+		}
+	}
+	return value
+}
+
+// MetricBound restricts a metric column to be strictly above or below a value
+type MetricBound struct {
+	Metric   string
+	Operator string
+	Value    float64
+}
+
+// ResultFilter holds the conditions used to select rows from speedtest_results
+type ResultFilter struct {
+	StartDate   string
+	EndDate     string
+	ServerNames []string
+	Providers   []string
+	ScheduleIDs []string
+	Bounds      []MetricBound
+	// Anomaly restricts results to flagged (true) or unflagged (false) results when set
+	Anomaly   *bool
+	ClientIPs []string
+	// ClientOrg matches results whose client organization contains it, ignoring case
+	ClientOrg string
+	// Tags restricts results to those carrying every one of the tags
+	Tags []string
+	// Family restricts results to IPv4 (4) or IPv6 (6) client addresses when set
+	Family     int
+	Interfaces []string
+	// Location is the time zone of relative ranges, time buckets and returned timestamps
+	Location *time.Location
+}
+
+// ParseResultFilter reads the filter query parameters shared by the results endpoints
+func ParseResultFilter(query url.Values) (ResultFilter, error) {
+	filter := ResultFilter{
+		StartDate:   query.Get("startDate"),
+		EndDate:     query.Get("endDate"),
+		ServerNames: query["server"],
+		Providers:   query["providers"],
+		ScheduleIDs: query["schedule"],
+		ClientIPs:   query["client_ip"],
+		ClientOrg:   query.Get("client_org"),
+		Tags:        query["tag"],
+		Interfaces:  query["interface"],
+	}
+
+	location, err := ParseLocation(query.Get("tz"))
+	if err != nil {
+		return filter, err
+	}
+	filter.Location = location
+
+	// Dates without an offset are read in the zone of the filter rather than the database one
+	filter.StartDate = inLocation(filter.StartDate, location)
+	filter.EndDate = inLocation(filter.EndDate, location)
+
+	if v := query.Get("range"); v != "" {
+		if filter.StartDate != "" {
+			return filter, fmt.Errorf("range can't be combined with startDate")
+		}
+		start, err := RangeStart(v, time.Now().In(location))
+		if err != nil {
+			return filter, err
+		}
+		filter.StartDate = start.Format(time.RFC3339)
+	}
+
+	if v := query.Get("anomaly"); v != "" {
+		anomaly, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid value for anomaly: %q", v)
+		}
+		filter.Anomaly = &anomaly
+	}
+
+	switch v := query.Get("family"); v {
+	case "":
+	case "4", "ipv4":
+		filter.Family = 4
+	case "6", "ipv6":
+		filter.Family = 6
+	default:
+		return filter, fmt.Errorf("invalid value for family: %q", v)
+	}
+
+	for _, metric := range Metrics {
+		for _, suffix := range []string{"_lt", "_gt"} {
+			valueStr := query.Get(metric + suffix)
+			if valueStr == "" {
+				continue
+			}
+			value, err := strconv.ParseFloat(valueStr, 64)
+			if err != nil {
+				return filter, fmt.Errorf("invalid value for %s%s: %q", metric, suffix, valueStr)
+			}
+			operator := "<"
+			if suffix == "_gt" {
+				operator = ">"
+			}
+			filter.Bounds = append(filter.Bounds, MetricBound{Metric: metric, Operator: operator, Value: value})
+		}
+	}
+
+	return filter, nil
+}
+
+// ParseResultFilterWithDefaults is ParseResultFilter for the endpoints the dashboard reads. Each
+// filter set through SetDefaults applies when the request has no parameter for it, and the range
+// only when the request has no dates either. defaults=false skips them.
+func ParseResultFilterWithDefaults(query url.Values) (ResultFilter, error) {
+	if v := query.Get("defaults"); v != "" {
+		apply, err := strconv.ParseBool(v)
+		if err != nil {
+			return ResultFilter{}, fmt.Errorf("invalid value for defaults: %q", v)
+		}
+		if !apply {
+			return ParseResultFilter(query)
+		}
+	}
+
+	defaultsMutex.RLock()
+	d := defaults
+	defaultsMutex.RUnlock()
+
+	query = maps.Clone(query)
+	if d.Range != "" && !query.Has("range") && !query.Has("startDate") && !query.Has("endDate") {
+		query.Set("range", d.Range)
+	}
+	for name, values := range map[string][]string{"providers": d.Providers, "server": d.Servers, "schedule": d.Schedules} {
+		if len(values) > 0 && !query.Has(name) {
+			query[name] = values
+		}
+	}
+	return ParseResultFilter(query)
+}
+
+// IsEmpty reports whether the filter would match every result
+func (f ResultFilter) IsEmpty() bool {
+	return f.StartDate == "" && f.EndDate == "" && len(f.ServerNames) == 0 &&
+		len(f.Providers) == 0 && len(f.ScheduleIDs) == 0 && !f.RawOnly()
+}
+
+// Zone returns the time zone of the filter, which is DefaultLocation unless the filter was parsed
+// from a request with a tz parameter
+func (f ResultFilter) Zone() *time.Location {
+	if f.Location == nil {
+		return DefaultLocation()
+	}
+	return f.Location
+}
+
+// RawOnly reports whether the filter has conditions on columns that only raw results have, so it
+// can't be applied to the rollup tables
+func (f ResultFilter) RawOnly() bool {
+	return len(f.Bounds) > 0 || f.Anomaly != nil || len(f.ClientIPs) > 0 || f.ClientOrg != "" ||
+		len(f.Tags) > 0 || f.Family != 0 || len(f.Interfaces) > 0
+}
+
+// Where renders the filter as a SQL WHERE clause. Placeholders are numbered
+// after the arguments already present in args, and the filter values are appended to it.
+func (f ResultFilter) Where(args []interface{}) (string, []interface{}) {
+	startDate := f.StartDate
+	if startDate == "" {
+		startDate = "1900-01-01T00:00:00.000-00"
+	}
+	endDate := f.EndDate
+	if endDate == "" {
+		endDate = "2500-01-01T00:00:00.000-00"
+	}
+
+	paramIndex := len(args) + 1
+	args = append(args, startDate, endDate)
+	clause := fmt.Sprintf(`
+        WHERE ($%d::timestamptz IS NULL OR timestamp >= $%d)
+        AND ($%d::timestamptz IS NULL OR timestamp <= $%d)`,
+		paramIndex, paramIndex, paramIndex+1, paramIndex+1)
+	paramIndex += 2
+
+	inList := func(column string, values []string) {
+		if len(values) == 0 {
+			return
+		}
+		placeholders := make([]string, len(values))
+		for i := range values {
+			placeholders[i] = fmt.Sprintf("$%d", paramIndex)
+			args = append(args, values[i])
+			paramIndex++
+		}
+		clause += fmt.Sprintf(" AND (%s IN (%s))", column, strings.Join(placeholders, ", "))
+	}
+
+	inList("server_name", f.ServerNames)
+	inList("provider_id", f.Providers)
+	inList("schedule_id", f.ScheduleIDs)
+	inList("client_ip", f.ClientIPs)
+	inList("client_interface", f.Interfaces)
+
+	if f.ClientOrg != "" {
+		clause += fmt.Sprintf(" AND (strpos(lower(client_org), lower($%d)) > 0)", paramIndex)
+		args = append(args, f.ClientOrg)
+		paramIndex++
+	}
+	if len(f.Tags) > 0 {
+		clause += fmt.Sprintf(" AND (tags @> $%d::text[])", paramIndex)
+		args = append(args, f.Tags)
+		paramIndex++
+	}
+
+	// IPv6 addresses are the only ones containing a colon
+	switch f.Family {
+	case 4:
+		clause += " AND (client_ip LIKE '%.%' AND client_ip NOT LIKE '%:%')"
+	case 6:
+		clause += " AND (client_ip LIKE '%:%')"
+	}
+
+	// Metric names are checked against the known columns so they can be interpolated safely
+	for _, bound := range f.Bounds {
+		if !IsMetric(bound.Metric) || (bound.Operator != "<" && bound.Operator != ">") {
+			continue
+		}
+		clause += fmt.Sprintf(" AND (%s %s $%d)", bound.Metric, bound.Operator, paramIndex)
+		args = append(args, bound.Value)
+		paramIndex++
+	}
+
+	if f.Anomaly != nil {
+		clause += fmt.Sprintf(" AND (is_anomaly = $%d)", paramIndex)
+		args = append(args, *f.Anomaly)
+	}
+
+	return clause, args
+}
+
+// IsMetric reports whether name is one of the numeric result columns in Metrics
+func IsMetric(name string) bool {
+	for _, metric := range Metrics {
+		if metric == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package filters
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRangeStart(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	// Two days after clocks moved forward on 2024-03-31
+	now := time.Date(2024, 4, 2, 12, 0, 0, 0, berlin)
+
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{value: "24h", want: time.Date(2024, 4, 1, 12, 0, 0, 0, berlin)},
+		{value: "7d", want: time.Date(2024, 3, 26, 12, 0, 0, 0, berlin)},
+		{value: "30d", want: time.Date(2024, 3, 3, 12, 0, 0, 0, berlin)},
+		{value: "mtd", want: time.Date(2024, 4, 1, 0, 0, 0, 0, berlin)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := RangeStart(tt.value, now)
+			if err != nil || !got.Equal(tt.want) {
+				t.Errorf("RangeStart(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+			}
+		})
+	}
+
+	// Calendar days, not multiples of 24 hours, across the change
+	if got, _ := RangeStart("7d", now); now.Sub(got) != 7*24*time.Hour-time.Hour {
+		t.Errorf("7d spans %s, want 167h", now.Sub(got))
+	}
+
+	if _, err := RangeStart("1y", now); err == nil {
+		t.Error("RangeStart(\"1y\") error = nil")
+	}
+}
+
+func TestParseResultFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		check   func(t *testing.T, f ResultFilter)
+		wantErr string
+	}{
+		{
+			name:  "dates without offset are read in tz",
+			query: "tz=America/New_York&startDate=2024-05-01&endDate=2024-05-01T18:30",
+			check: func(t *testing.T, f ResultFilter) {
+				if f.StartDate != "2024-05-01T00:00:00-04:00" || f.EndDate != "2024-05-01T18:30:00-04:00" {
+					t.Errorf("dates = %q, %q", f.StartDate, f.EndDate)
+				}
+				if f.Zone().String() != "America/New_York" {
+					t.Errorf("zone = %s", f.Zone())
+				}
+			},
+		},
+		{
+			name:  "dates with offset are kept",
+			query: "tz=America/New_York&startDate=2024-05-01T00:00:00Z",
+			check: func(t *testing.T, f ResultFilter) {
+				if f.StartDate != "2024-05-01T00:00:00Z" {
+					t.Errorf("startDate = %q", f.StartDate)
+				}
+			},
+		},
+		{
+			name:  "raw-only conditions",
+			query: "download_lt=50&ping_gt=20&family=ipv6&anomaly=true&tag=office",
+			check: func(t *testing.T, f ResultFilter) {
+				if len(f.Bounds) != 2 || f.Bounds[0] != (MetricBound{Metric: "download", Operator: "<", Value: 50}) ||
+					f.Bounds[1] != (MetricBound{Metric: "ping", Operator: ">", Value: 20}) {
+					t.Errorf("bounds = %+v", f.Bounds)
+				}
+				if f.Family != 6 || f.Anomaly == nil || !*f.Anomaly || !f.RawOnly() {
+					t.Errorf("filter = %+v, want IPv6 anomalies only from raw results", f)
+				}
+			},
+		},
+		{
+			name:  "providers and servers alone can use rollups",
+			query: "providers=p1&server=Frankfurt&schedule=s1",
+			check: func(t *testing.T, f ResultFilter) {
+				if f.RawOnly() || f.IsEmpty() {
+					t.Errorf("filter = %+v, want a non-empty filter that rollups can answer", f)
+				}
+			},
+		},
+		{name: "range with startDate", query: "range=7d&startDate=2024-05-01", wantErr: "range can't be combined"},
+		{name: "unknown range", query: "range=1y", wantErr: "invalid range"},
+		{name: "Local tz", query: "tz=Local", wantErr: "invalid tz"},
+		{name: "unknown tz", query: "tz=Mars/Olympus", wantErr: "invalid tz"},
+		{name: "bad family", query: "family=5", wantErr: "invalid value for family"},
+		{name: "bad bound", query: "jitter_gt=high", wantErr: "invalid value for jitter_gt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := ParseResultFilter(query)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ParseResultFilter() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseResultFilter() error = %v", err)
+			}
+			tt.check(t, f)
+		})
+	}
+}
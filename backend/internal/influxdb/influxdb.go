@@ -0,0 +1,285 @@
+package influxdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+)
+
+const measurement = "speedtest"
+
+// initialBackoff is the wait before the first retry of a failed write; it doubles after every retry
+var initialBackoff = time.Second
+
+// Config holds the connection and batching settings for an InfluxDB v2 write endpoint
+type Config struct {
+	URL           string        `yaml:"url" toml:"url"`
+	Token         string        `yaml:"token" toml:"token"`
+	Org           string        `yaml:"org" toml:"org"`
+	Bucket        string        `yaml:"bucket" toml:"bucket"`
+	BatchSize     int           `yaml:"batch_size" toml:"batch_size"`
+	FlushInterval time.Duration `yaml:"flush_interval" toml:"flush_interval"`
+	MaxRetries    int           `yaml:"max_retries" toml:"max_retries"`
+	Timeout       time.Duration `yaml:"timeout" toml:"timeout"`
+}
+
+// DefaultConfig returns the batching defaults. The sink stays disabled until a URL is set.
+func DefaultConfig() Config {
+	return Config{
+		BatchSize:     100,
+		FlushInterval: 10 * time.Second,
+		MaxRetries:    5,
+		Timeout:       10 * time.Second,
+	}
+}
+
+// Enabled reports whether results should be written to InfluxDB
+func (c Config) Enabled() bool {
+	return c.URL != ""
+}
+
+// Validate checks the configuration of an enabled sink
+func (c Config) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	if c.Bucket == "" {
+		return fmt.Errorf("bucket is required when url is set")
+	}
+	if c.BatchSize <= 0 {
+		return fmt.Errorf("invalid batch size: %d", c.BatchSize)
+	}
+	if c.FlushInterval <= 0 {
+		return fmt.Errorf("invalid flush interval: %s", c.FlushInterval)
+	}
+	if c.MaxRetries < 0 {
+		return fmt.Errorf("invalid max retries: %d", c.MaxRetries)
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("invalid timeout: %s", c.Timeout)
+	}
+	return nil
+}
+
+// Sink buffers stored results and writes them to InfluxDB as line protocol in batches
+type Sink struct {
+	config   Config
+	writeURL string
+	client   *http.Client
+	points   chan string
+	done     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewSink starts a sink that writes to the endpoint described by config
+func NewSink(config Config) (*Sink, error) {
+	writeURL, err := url.Parse(strings.TrimRight(config.URL, "/") + "/api/v2/write")
+	if err != nil {
+		return nil, fmt.Errorf("invalid InfluxDB URL: %w", err)
+	}
+	query := writeURL.Query()
+	query.Set("bucket", config.Bucket)
+	if config.Org != "" {
+		query.Set("org", config.Org)
+	}
+	query.Set("precision", "ns")
+	writeURL.RawQuery = query.Encode()
+
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 10 * time.Second
+	}
+
+	s := &Sink{
+		config:   config,
+		writeURL: writeURL.String(),
+		client:   &http.Client{Timeout: config.Timeout},
+		// Leave room for several batches so a slow InfluxDB does not stall result storage
+		points: make(chan string, config.BatchSize*10),
+		done:   make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.run()
+	return s, nil
+}
+
+func (s *Sink) Name() string {
+	return "influxdb"
+}
+
+// HandleResult queues the result for the next batch, dropping it if the buffer is full
+func (s *Sink) HandleResult(result models.SpeedTestResult) {
+	line, err := LineProtocol(result)
+	if err != nil {
+		log.Printf("InfluxDB sink: skipping result: %v", err)
+		return
+	}
+
+	select {
+	case s.points <- line:
+	default:
+		log.Printf("InfluxDB sink: buffer full, dropping result for %s", result.Timestamp)
+	}
+}
+
+// Close flushes any buffered points and stops the background writer
+func (s *Sink) Close() {
+	s.stopOnce.Do(func() {
+		close(s.done)
+	})
+	s.wg.Wait()
+}
+
+func (s *Sink) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]string, 0, s.config.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.write(batch); err != nil {
+			log.Printf("InfluxDB sink: dropping %d points: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case line := <-s.points:
+			batch = append(batch, line)
+			if len(batch) >= s.config.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-s.done:
+			for {
+				select {
+				case line := <-s.points:
+					batch = append(batch, line)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// write sends a batch, retrying with exponential backoff on network errors, 429 and 5xx responses
+func (s *Sink) write(batch []string) error {
+	body := []byte(strings.Join(batch, "\n") + "\n")
+	backoff := initialBackoff
+
+	var err error
+	for attempt := 0; attempt <= s.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-s.done:
+				// Shutting down: make one last attempt without waiting
+			}
+			backoff *= 2
+		}
+
+		var retry bool
+		retry, err = s.post(body)
+		if err == nil || !retry {
+			return err
+		}
+		log.Printf("InfluxDB sink: write attempt %d failed: %v", attempt+1, err)
+	}
+	return err
+}
+
+func (s *Sink) post(body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.writeURL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.config.Token != "" {
+		req.Header.Set("Authorization", "Token "+s.config.Token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("InfluxDB returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, err
+}
+
+// LineProtocol renders a result as a single InfluxDB line protocol point
+func LineProtocol(result models.SpeedTestResult) (string, error) {
+	timestamp, err := time.Parse(time.RFC3339Nano, result.Timestamp)
+	if err != nil {
+		return "", fmt.Errorf("invalid timestamp %q: %w", result.Timestamp, err)
+	}
+
+	var line strings.Builder
+	line.WriteString(measurement)
+	for _, tag := range [][2]string{
+		{"provider", result.ProviderName},
+		{"schedule", result.ScheduleID},
+		{"server", result.Server.Name},
+	} {
+		// InfluxDB rejects empty tag values, so unset tags are left out
+		if tag[1] == "" {
+			continue
+		}
+		line.WriteString(",")
+		line.WriteString(escapeTag(tag[0]))
+		line.WriteString("=")
+		line.WriteString(escapeTag(tag[1]))
+	}
+
+	formatFloat := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	line.WriteString(" download=" + formatFloat(result.Download))
+	line.WriteString(",upload=" + formatFloat(result.Upload))
+	line.WriteString(",ping=" + formatFloat(result.Ping))
+	line.WriteString(",jitter=" + formatFloat(result.Jitter))
+	line.WriteString(",bytes_sent=" + strconv.FormatInt(result.BytesSent, 10) + "i")
+	line.WriteString(",bytes_received=" + strconv.FormatInt(result.BytesReceived, 10) + "i")
+	line.WriteString(" " + strconv.FormatInt(timestamp.UnixNano(), 10))
+
+	return line.String(), nil
+}
+
+var tagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", "")
+
+func escapeTag(value string) string {
+	return tagEscaper.Replace(value)
+}
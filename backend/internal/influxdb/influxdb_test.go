@@ -0,0 +1,305 @@
+package influxdb
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+)
+
+// measuredResult is a result of provider whose point has every tag and field
+func measuredResult(provider string) models.SpeedTestResult {
+	var result models.SpeedTestResult
+	result.Timestamp = "2024-05-01T12:00:00.5Z"
+	result.ProviderName = provider
+	result.ScheduleID = "schedule-1"
+	result.Server.Name = "Example Server"
+	result.Download = 512.25
+	result.Upload = 48
+	result.Ping = 12.5
+	result.Jitter = 0.75
+	result.BytesSent = 1000
+	result.BytesReceived = 2000
+	return result
+}
+
+func TestLineProtocol(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*models.SpeedTestResult)
+		want   string
+	}{
+		{
+			name:   "all tags",
+			change: func(*models.SpeedTestResult) {},
+			want: `speedtest,provider=librespeed,schedule=schedule-1,server=Example\ Server ` +
+				`download=512.25,upload=48,ping=12.5,jitter=0.75,bytes_sent=1000i,bytes_received=2000i 1714564800500000000`,
+		},
+		{
+			name: "special characters are escaped",
+			change: func(r *models.SpeedTestResult) {
+				r.ProviderName = "a,b=c d"
+				r.Server.Name = "line\nbreak"
+			},
+			want: `speedtest,provider=a\,b\=c\ d,schedule=schedule-1,server=linebreak ` +
+				`download=512.25,upload=48,ping=12.5,jitter=0.75,bytes_sent=1000i,bytes_received=2000i 1714564800500000000`,
+		},
+		{
+			name: "empty tags are left out",
+			change: func(r *models.SpeedTestResult) {
+				r.ScheduleID = ""
+				r.Server.Name = ""
+			},
+			want: `speedtest,provider=librespeed ` +
+				`download=512.25,upload=48,ping=12.5,jitter=0.75,bytes_sent=1000i,bytes_received=2000i 1714564800500000000`,
+		},
+		{
+			name: "only metrics become fields",
+			change: func(r *models.SpeedTestResult) {
+				r.Client.IP = "192.0.2.1"
+				r.Share = "https://example.com/share"
+				r.Tags = []string{"office"}
+			},
+			want: `speedtest,provider=librespeed,schedule=schedule-1,server=Example\ Server ` +
+				`download=512.25,upload=48,ping=12.5,jitter=0.75,bytes_sent=1000i,bytes_received=2000i 1714564800500000000`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := measuredResult("librespeed")
+			tt.change(&result)
+			got, err := LineProtocol(result)
+			if err != nil {
+				t.Fatalf("LineProtocol() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("LineProtocol() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLineProtocolInvalidTimestamp(t *testing.T) {
+	result := measuredResult("librespeed")
+	result.Timestamp = "yesterday"
+	if _, err := LineProtocol(result); err == nil {
+		t.Fatal("LineProtocol() error = nil, want an error for an invalid timestamp")
+	}
+}
+
+// writeRequest is a write received by the InfluxDB stand-in
+type writeRequest struct {
+	at     time.Time
+	query  string
+	auth   string
+	points []string
+}
+
+// influxServer is a local stand-in for the InfluxDB write endpoint that answers with the given
+// status codes in turn, repeating the last one
+type influxServer struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	statuses []int
+	requests chan writeRequest
+}
+
+func newInfluxServer(t *testing.T, statuses ...int) *influxServer {
+	t.Helper()
+	s := &influxServer{statuses: statuses, requests: make(chan writeRequest, 100)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/write" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		s.requests <- writeRequest{
+			at:     time.Now(),
+			query:  r.URL.RawQuery,
+			auth:   r.Header.Get("Authorization"),
+			points: strings.Split(strings.TrimSuffix(string(body), "\n"), "\n"),
+		}
+
+		s.mutex.Lock()
+		status := s.statuses[0]
+		if len(s.statuses) > 1 {
+			s.statuses = s.statuses[1:]
+		}
+		s.mutex.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *influxServer) next(t *testing.T) writeRequest {
+	t.Helper()
+	select {
+	case req := <-s.requests:
+		return req
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a write")
+		return writeRequest{}
+	}
+}
+
+// expectNoWrite fails when another write arrives shortly
+func (s *influxServer) expectNoWrite(t *testing.T) {
+	t.Helper()
+	select {
+	case req := <-s.requests:
+		t.Fatalf("unexpected write of %d points", len(req.points))
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// config writes one result per batch to the stand-in. Flushing on the interval is left to tests that
+// set FlushInterval.
+func (s *influxServer) config() Config {
+	config := DefaultConfig()
+	config.URL = s.URL
+	config.Token = "secret"
+	config.Org = "home"
+	config.Bucket = "speedtests"
+	config.BatchSize = 1
+	config.FlushInterval = time.Hour
+	config.Timeout = time.Second
+	return config
+}
+
+func startSink(t *testing.T, config Config) *Sink {
+	t.Helper()
+	sink, err := NewSink(config)
+	if err != nil {
+		t.Fatalf("NewSink() error = %v", err)
+	}
+	t.Cleanup(sink.Close)
+	return sink
+}
+
+func setInitialBackoff(t *testing.T, backoff time.Duration) {
+	previous := initialBackoff
+	initialBackoff = backoff
+	t.Cleanup(func() { initialBackoff = previous })
+}
+
+func TestSinkWritesFullBatches(t *testing.T) {
+	server := newInfluxServer(t, http.StatusNoContent)
+	config := server.config()
+	config.BatchSize = 3
+	sink := startSink(t, config)
+
+	for _, provider := range []string{"a", "b", "c", "d"} {
+		sink.HandleResult(measuredResult(provider))
+	}
+
+	req := server.next(t)
+	if len(req.points) != 3 {
+		t.Fatalf("batch has %d points, want 3", len(req.points))
+	}
+	for i, provider := range []string{"a", "b", "c"} {
+		if !strings.HasPrefix(req.points[i], "speedtest,provider="+provider+",") {
+			t.Errorf("point %d = %q, want provider %s", i, req.points[i], provider)
+		}
+	}
+	if req.auth != "Token secret" {
+		t.Errorf("Authorization = %q, want %q", req.auth, "Token secret")
+	}
+	if req.query != "bucket=speedtests&org=home&precision=ns" {
+		t.Errorf("query = %q", req.query)
+	}
+
+	// The fourth point waits for the flush interval, which is an hour
+	server.expectNoWrite(t)
+
+	// Closing flushes the remainder
+	sink.Close()
+	if req := server.next(t); len(req.points) != 1 {
+		t.Fatalf("final batch has %d points, want 1", len(req.points))
+	}
+}
+
+func TestSinkFlushesOnInterval(t *testing.T) {
+	server := newInfluxServer(t, http.StatusNoContent)
+	config := server.config()
+	config.BatchSize = 100
+	config.FlushInterval = 50 * time.Millisecond
+	sink := startSink(t, config)
+
+	start := time.Now()
+	sink.HandleResult(measuredResult("librespeed"))
+	sink.HandleResult(measuredResult("iperf3"))
+
+	req := server.next(t)
+	if len(req.points) != 2 {
+		t.Fatalf("batch has %d points, want 2", len(req.points))
+	}
+	if waited := req.at.Sub(start); waited > 2*time.Second {
+		t.Errorf("batch written after %s, want about the flush interval", waited)
+	}
+}
+
+func TestSinkRetriesServerErrors(t *testing.T) {
+	setInitialBackoff(t, 20*time.Millisecond)
+	server := newInfluxServer(t, http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusNoContent)
+	sink := startSink(t, server.config())
+
+	sink.HandleResult(measuredResult("librespeed"))
+
+	first, second, third := server.next(t), server.next(t), server.next(t)
+	for _, req := range []writeRequest{second, third} {
+		if strings.Join(req.points, "\n") != strings.Join(first.points, "\n") {
+			t.Errorf("retry sent %q, want the original batch %q", req.points, first.points)
+		}
+	}
+	if gap := second.at.Sub(first.at); gap < 20*time.Millisecond {
+		t.Errorf("first retry after %s, want at least 20ms", gap)
+	}
+	if gap := third.at.Sub(second.at); gap < 40*time.Millisecond {
+		t.Errorf("second retry after %s, want the backoff doubled to at least 40ms", gap)
+	}
+	server.expectNoWrite(t)
+}
+
+func TestSinkStopsAfterMaxRetries(t *testing.T) {
+	setInitialBackoff(t, time.Millisecond)
+	server := newInfluxServer(t, http.StatusBadGateway)
+	config := server.config()
+	config.MaxRetries = 2
+	sink := startSink(t, config)
+
+	sink.HandleResult(measuredResult("librespeed"))
+
+	for i := 0; i < 3; i++ {
+		server.next(t)
+	}
+	server.expectNoWrite(t)
+}
+
+func TestSinkDoesNotRetryClientErrors(t *testing.T) {
+	setInitialBackoff(t, time.Millisecond)
+	server := newInfluxServer(t, http.StatusBadRequest)
+	sink := startSink(t, server.config())
+
+	sink.HandleResult(measuredResult("librespeed"))
+
+	server.next(t)
+	server.expectNoWrite(t)
+}
+
+func TestSinkRetriesTooManyRequests(t *testing.T) {
+	setInitialBackoff(t, time.Millisecond)
+	server := newInfluxServer(t, http.StatusTooManyRequests, http.StatusNoContent)
+	sink := startSink(t, server.config())
+
+	sink.HandleResult(measuredResult("librespeed"))
+
+	server.next(t)
+	server.next(t)
+	server.expectNoWrite(t)
+}
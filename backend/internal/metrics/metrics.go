@@ -0,0 +1,346 @@
+package metrics
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/database"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "botb"
+
+// Run statuses used as the status label on run metrics
+const (
+	RunStatusSuccess  = "success"
+	RunStatusFailure  = "failure"
+	RunStatusCanceled = "canceled"
+	RunStatusSkipped  = "skipped"
+)
+
+// ScheduledRun describes the next planned execution of an active schedule
+type ScheduledRun struct {
+	ScheduleID   string
+	ScheduleName string
+	Next         time.Time
+}
+
+var (
+	registry = prometheus.NewRegistry()
+
+	runsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "speedtest_runs_total",
+		Help:      "Number of speed test runs by provider and outcome.",
+	}, []string{"provider", "status"})
+
+	runDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "speedtest_run_duration_seconds",
+		Help:      "Duration of speed test runs by provider and outcome.",
+		Buckets:   []float64{1, 5, 10, 15, 20, 30, 45, 60, 90, 120, 180, 300},
+	}, []string{"provider", "status"})
+
+	scheduleSourceMutex sync.RWMutex
+	scheduleSource      func() []ScheduledRun
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		runsTotal,
+		runDuration,
+		latestResults,
+		&schedulerCollector{},
+		&poolCollector{},
+	)
+}
+
+// Handler serves the registered metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRun records the outcome and duration of a single provider run
+func ObserveRun(provider, status string, duration time.Duration) {
+	runsTotal.WithLabelValues(provider, status).Inc()
+	runDuration.WithLabelValues(provider, status).Observe(duration.Seconds())
+}
+
+// SetScheduleSource registers the function used to report scheduler state at scrape time
+func SetScheduleSource(source func() []ScheduledRun) {
+	scheduleSourceMutex.Lock()
+	defer scheduleSourceMutex.Unlock()
+	scheduleSource = source
+}
+
+var resultLabels = []string{"provider", "server", "schedule_id", "schedule"}
+
+var (
+	latestDownloadDesc  = prometheus.NewDesc(namespace+"_latest_download_mbps", "Download speed of the most recent result.", resultLabels, nil)
+	latestUploadDesc    = prometheus.NewDesc(namespace+"_latest_upload_mbps", "Upload speed of the most recent result.", resultLabels, nil)
+	latestPingDesc      = prometheus.NewDesc(namespace+"_latest_ping_ms", "Ping of the most recent result.", resultLabels, nil)
+	latestJitterDesc    = prometheus.NewDesc(namespace+"_latest_jitter_ms", "Jitter of the most recent result.", resultLabels, nil)
+	latestTimestampDesc = prometheus.NewDesc(namespace+"_latest_result_timestamp_seconds", "Unix time of the most recent result.", resultLabels, nil)
+)
+
+// latestKey identifies a series of results in the latest result metrics
+type latestKey struct {
+	provider   string
+	server     string
+	scheduleID string
+}
+
+type latestValues struct {
+	download, upload, ping, jitter float64
+	timestamp                      time.Time
+}
+
+// latestResultsCollector reports the newest result per provider, server and schedule. The results
+// are cached: they are loaded from the database on the first scrape after a reload, and new results
+// come in through ResultSink, so a scrape doesn't scan every stored result.
+type latestResultsCollector struct {
+	mutex  sync.Mutex
+	loaded bool
+	latest map[latestKey]latestValues
+}
+
+var latestResults = &latestResultsCollector{}
+
+// ResultSink keeps the latest result metrics up to date with every stored result
+type ResultSink struct{}
+
+func (s *ResultSink) Name() string {
+	return "metrics"
+}
+
+func (s *ResultSink) HandleResult(result models.SpeedTestResult) {
+	latestResults.observe(result)
+}
+
+// ReloadLatestResults makes the next scrape load the latest results from the database again. Call it
+// after results are deleted, imported or moved to another schedule without passing through ResultSink.
+func ReloadLatestResults() {
+	latestResults.mutex.Lock()
+	defer latestResults.mutex.Unlock()
+	latestResults.loaded = false
+}
+
+func (c *latestResultsCollector) observe(result models.SpeedTestResult) {
+	timestamp, err := time.Parse(time.RFC3339Nano, result.Timestamp)
+	if err != nil {
+		log.Printf("Invalid timestamp %q for latest result metrics: %v", result.Timestamp, err)
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	// Until the first load, the database has the result too
+	if !c.loaded {
+		return
+	}
+
+	key := latestKey{provider: result.ProviderName, server: result.Server.Name, scheduleID: result.ScheduleID}
+	if current, ok := c.latest[key]; ok && current.timestamp.After(timestamp) {
+		return
+	}
+	c.latest[key] = latestValues{
+		download:  result.Download,
+		upload:    result.Upload,
+		ping:      result.Ping,
+		jitter:    result.Jitter,
+		timestamp: timestamp,
+	}
+}
+
+// load reads the newest result of every series from the database
+func (c *latestResultsCollector) load(ctx context.Context) (map[latestKey]latestValues, error) {
+	rows, err := database.DB.Query(ctx, `
+		SELECT DISTINCT ON (p.name, r.server_name, r.schedule_id)
+		       COALESCE(p.name, ''), COALESCE(r.server_name, ''), COALESCE(r.schedule_id::text, ''),
+		       COALESCE(r.download, 0), COALESCE(r.upload, 0), COALESCE(r.ping, 0), COALESCE(r.jitter, 0),
+		       r.timestamp
+		FROM speedtest_results r
+		LEFT JOIN providers p ON p.id = r.provider_id
+		WHERE r.timestamp IS NOT NULL
+		ORDER BY p.name, r.server_name, r.schedule_id, r.timestamp DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	latest := make(map[latestKey]latestValues)
+	for rows.Next() {
+		var key latestKey
+		var values latestValues
+		if err := rows.Scan(&key.provider, &key.server, &key.scheduleID,
+			&values.download, &values.upload, &values.ping, &values.jitter, &values.timestamp); err != nil {
+			return nil, err
+		}
+		latest[key] = values
+	}
+	return latest, rows.Err()
+}
+
+// snapshot returns the cached results, loading them first when needed
+func (c *latestResultsCollector) snapshot(ctx context.Context) (map[latestKey]latestValues, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.loaded {
+		latest, err := c.load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.latest, c.loaded = latest, true
+	}
+
+	snapshot := make(map[latestKey]latestValues, len(c.latest))
+	for key, values := range c.latest {
+		snapshot[key] = values
+	}
+	return snapshot, nil
+}
+
+func (c *latestResultsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- latestDownloadDesc
+	ch <- latestUploadDesc
+	ch <- latestPingDesc
+	ch <- latestJitterDesc
+	ch <- latestTimestampDesc
+}
+
+func (c *latestResultsCollector) Collect(ch chan<- prometheus.Metric) {
+	if database.DB == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	latest, err := c.snapshot(ctx)
+	if err != nil {
+		log.Printf("Error collecting latest result metrics: %v", err)
+		return
+	}
+
+	// Schedule names are looked up on every scrape, so renamed schedules are reported by their new name
+	scheduleNames, err := fetchScheduleNames(ctx)
+	if err != nil {
+		log.Printf("Error collecting latest result metrics: %v", err)
+		return
+	}
+
+	for key, values := range latest {
+		labels := []string{key.provider, key.server, key.scheduleID, scheduleNames[key.scheduleID]}
+		ch <- prometheus.MustNewConstMetric(latestDownloadDesc, prometheus.GaugeValue, values.download, labels...)
+		ch <- prometheus.MustNewConstMetric(latestUploadDesc, prometheus.GaugeValue, values.upload, labels...)
+		ch <- prometheus.MustNewConstMetric(latestPingDesc, prometheus.GaugeValue, values.ping, labels...)
+		ch <- prometheus.MustNewConstMetric(latestJitterDesc, prometheus.GaugeValue, values.jitter, labels...)
+		ch <- prometheus.MustNewConstMetric(latestTimestampDesc, prometheus.GaugeValue, float64(values.timestamp.Unix()), labels...)
+	}
+}
+
+func fetchScheduleNames(ctx context.Context) (map[string]string, error) {
+	rows, err := database.DB.Query(ctx, "SELECT id::text, name FROM schedules")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make(map[string]string)
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		names[id] = name
+	}
+	return names, rows.Err()
+}
+
+var (
+	activeSchedulesDesc = prometheus.NewDesc(namespace+"_scheduler_active_schedules", "Number of schedules loaded into the cron scheduler.", nil, nil)
+	nextRunDesc         = prometheus.NewDesc(namespace+"_schedule_next_run_timestamp_seconds", "Unix time of the next planned run of a schedule.", []string{"schedule_id", "schedule"}, nil)
+)
+
+// schedulerCollector reports the cron scheduler state through the registered schedule source
+type schedulerCollector struct{}
+
+func (c *schedulerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- activeSchedulesDesc
+	ch <- nextRunDesc
+}
+
+func (c *schedulerCollector) Collect(ch chan<- prometheus.Metric) {
+	scheduleSourceMutex.RLock()
+	source := scheduleSource
+	scheduleSourceMutex.RUnlock()
+	if source == nil {
+		return
+	}
+
+	runs := source()
+	ch <- prometheus.MustNewConstMetric(activeSchedulesDesc, prometheus.GaugeValue, float64(len(runs)))
+	for _, run := range runs {
+		if run.Next.IsZero() {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(nextRunDesc, prometheus.GaugeValue, float64(run.Next.Unix()), run.ScheduleID, run.ScheduleName)
+	}
+}
+
+var (
+	poolAcquiredDesc        = prometheus.NewDesc(namespace+"_db_pool_acquired_connections", "Connections currently acquired from the pool.", nil, nil)
+	poolIdleDesc            = prometheus.NewDesc(namespace+"_db_pool_idle_connections", "Idle connections in the pool.", nil, nil)
+	poolTotalDesc           = prometheus.NewDesc(namespace+"_db_pool_total_connections", "Total connections in the pool.", nil, nil)
+	poolConstructingDesc    = prometheus.NewDesc(namespace+"_db_pool_constructing_connections", "Connections currently being established.", nil, nil)
+	poolMaxDesc             = prometheus.NewDesc(namespace+"_db_pool_max_connections", "Maximum size of the pool.", nil, nil)
+	poolAcquireCountDesc    = prometheus.NewDesc(namespace+"_db_pool_acquires_total", "Successful connection acquisitions.", nil, nil)
+	poolAcquireDurationDesc = prometheus.NewDesc(namespace+"_db_pool_acquire_duration_seconds_total", "Total time spent acquiring connections.", nil, nil)
+	poolCanceledDesc        = prometheus.NewDesc(namespace+"_db_pool_canceled_acquires_total", "Acquisitions canceled by their context.", nil, nil)
+	poolEmptyDesc           = prometheus.NewDesc(namespace+"_db_pool_empty_acquires_total", "Acquisitions that had to wait for a connection.", nil, nil)
+	poolNewConnsDesc        = prometheus.NewDesc(namespace+"_db_pool_new_connections_total", "Connections opened by the pool.", nil, nil)
+)
+
+// poolCollector exposes the pgxpool statistics of database.DB
+type poolCollector struct{}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolAcquiredDesc
+	ch <- poolIdleDesc
+	ch <- poolTotalDesc
+	ch <- poolConstructingDesc
+	ch <- poolMaxDesc
+	ch <- poolAcquireCountDesc
+	ch <- poolAcquireDurationDesc
+	ch <- poolCanceledDesc
+	ch <- poolEmptyDesc
+	ch <- poolNewConnsDesc
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	if database.DB == nil {
+		return
+	}
+
+	stat := database.DB.Stat()
+	ch <- prometheus.MustNewConstMetric(poolAcquiredDesc, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(poolIdleDesc, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(poolTotalDesc, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(poolConstructingDesc, prometheus.GaugeValue, float64(stat.ConstructingConns()))
+	ch <- prometheus.MustNewConstMetric(poolMaxDesc, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireCountDesc, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireDurationDesc, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(poolCanceledDesc, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolEmptyDesc, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolNewConnsDesc, prometheus.CounterValue, float64(stat.NewConnsCount()))
+}
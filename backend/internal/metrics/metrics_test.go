@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+)
+
+func TestLatestResultsObserve(t *testing.T) {
+	result := func(timestamp string, download float64) models.SpeedTestResult {
+		r := models.SpeedTestResult{Timestamp: timestamp, Download: download, ProviderName: "librespeed", ScheduleID: "s1"}
+		r.Server.Name = "Frankfurt"
+		return r
+	}
+	key := latestKey{provider: "librespeed", server: "Frankfurt", scheduleID: "s1"}
+
+	c := &latestResultsCollector{}
+	c.observe(result("2024-05-01T12:00:00Z", 100))
+	if len(c.latest) != 0 {
+		t.Fatalf("results observed before the first load were cached: %v", c.latest)
+	}
+
+	c.loaded, c.latest = true, map[latestKey]latestValues{}
+	c.observe(result("2024-05-01T12:00:00Z", 100))
+	c.observe(result("2024-05-01T11:00:00Z", 50))
+	if got := c.latest[key]; got.download != 100 {
+		t.Errorf("download = %v after an older result, want 100", got.download)
+	}
+
+	c.observe(result("2024-05-01T13:00:00.5Z", 120))
+	got := c.latest[key]
+	if want := time.Date(2024, 5, 1, 13, 0, 0, 5e8, time.UTC); got.download != 120 || !got.timestamp.Equal(want) {
+		t.Errorf("latest = %+v, want download 120 at %v", got, want)
+	}
+
+	c.observe(result("not a time", 10))
+	if got := c.latest[key]; got.download != 120 {
+		t.Errorf("download = %v after an invalid timestamp, want 120", got.download)
+	}
+
+	ReloadLatestResults()
+	if latestResults.loaded {
+		t.Error("ReloadLatestResults() left the cache loaded")
+	}
+}
@@ -7,6 +7,7 @@ import (
 )
 
 type SpeedTestResult struct {
+	ID        string `json:"id"`
 	Timestamp string `json:"timestamp"`
 	Server    struct {
 		Name string `json:"name"`
@@ -22,24 +23,55 @@ type SpeedTestResult struct {
 		Org      string `json:"org"`
 		Postal   string `json:"postal"`
 		Timezone string `json:"timezone"`
+		// Interface is the network interface the test ran on, when the provider reports it
+		Interface string `json:"interface"`
 	} `json:"client"`
-	BytesSent     int64   `json:"bytes_sent"`
-	BytesReceived int64   `json:"bytes_received"`
-	Ping          float64 `json:"ping"`
-	Jitter        float64 `json:"jitter"`
-	Upload        float64 `json:"upload"`
-	Download      float64 `json:"download"`
-	Share         string  `json:"share"`
-	ProviderID    string  `json:"provider_id"`
-	ProviderName  string  `json:"provider_name"`
-	ScheduleID    string  `json:"schedule_id"`
+	BytesSent     int64    `json:"bytes_sent"`
+	BytesReceived int64    `json:"bytes_received"`
+	Ping          float64  `json:"ping"`
+	Jitter        float64  `json:"jitter"`
+	Upload        float64  `json:"upload"`
+	Download      float64  `json:"download"`
+	Share         string   `json:"share"`
+	ProviderID    string   `json:"provider_id"`
+	ProviderName  string   `json:"provider_name"`
+	ScheduleID    string   `json:"schedule_id"`
+	RoundID       string   `json:"round_id,omitempty"`
+	Tags          []string `json:"tags"`
+
+	// Set by anomaly detection when the result falls outside the usual range for its provider, server and hour of day
+	IsAnomaly      bool     `json:"is_anomaly"`
+	AnomalyScore   *float64 `json:"anomaly_score,omitempty"`
+	AnomalyMetrics []string `json:"anomaly_metrics,omitempty"`
+
+	// Set when an ISP plan was in effect at the time of the result
+	PlanID          string   `json:"plan_id,omitempty"`
+	DownloadPercent *float64 `json:"download_percent,omitempty"`
+	UploadPercent   *float64 `json:"upload_percent,omitempty"`
+	LatencySLAMet   *bool    `json:"latency_sla_met,omitempty"`
 }
 
+// UserSettings holds the application-wide settings managed through /api/settings
 type UserSettings struct {
-	ID                 string    `json:"id"`
-	SpeedtestFrequency int32     `json:"speedtest_frequency"`
-	CreatedAt          time.Time `json:"created_at"`
-	UpdatedAt          time.Time `json:"updated_at"`
+	ID string `json:"id"`
+	// DefaultUnits is the unit speeds are displayed in: kbps, mbps or gbps
+	DefaultUnits   string         `json:"default_units"`
+	DefaultFilters DefaultFilters `json:"default_filters"`
+	// DefaultTimezone is the IANA time zone used when a request has no tz parameter; empty uses the server time zone
+	DefaultTimezone    string `json:"default_timezone"`
+	DefaultResultLimit int    `json:"default_result_limit"`
+	// Paused stops every scheduled run. Manual runs are unaffected.
+	Paused    bool      `json:"paused"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DefaultFilters are the result filters the dashboard starts with
+type DefaultFilters struct {
+	Range     string   `json:"range,omitempty"`
+	Providers []string `json:"providers,omitempty"`
+	Servers   []string `json:"servers,omitempty"`
+	Schedules []string `json:"schedules,omitempty"`
 }
 
 type DefaultJsonResponse struct {
@@ -59,6 +91,116 @@ type Schedule struct {
 	HostEndpoint   string    `json:"host_endpoint"`
 	HostPort       string    `json:"host_port"`
 	ResultLimit    int       `json:"result_limit"`
+	// MaxAgeDays deletes results older than this many days; 0 keeps them forever
+	MaxAgeDays int `json:"max_age_days"`
+	// TournamentProviders turns the schedule into a tournament: every run is one round
+	// of these providers in randomized order, and their ratings are updated afterwards
+	TournamentProviders []string `json:"tournament_providers"`
+	// ArchivedAt is set when the schedule was archived instead of deleted
+	ArchivedAt *time.Time `json:"archived_at"`
+}
+
+// ISPPlan is an advertised internet plan. A plan change is recorded as a new plan
+// with a later effective_from, so older results keep being judged against the old plan.
+type ISPPlan struct {
+	ID            string     `json:"id"`
+	Name          string     `json:"name"`
+	DownloadMbps  float64    `json:"download_mbps"`
+	UploadMbps    float64    `json:"upload_mbps"`
+	LatencySLAMs  *float64   `json:"latency_sla_ms"`
+	EffectiveFrom time.Time  `json:"effective_from"`
+	EffectiveTo   *time.Time `json:"effective_to"`
+	ScheduleIDs   []string   `json:"schedule_ids"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+type Webhook struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"secret,omitempty"`
+	EventTypes []string  `json:"event_types"`
+	IsActive   bool      `json:"is_active"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID             string    `json:"id"`
+	WebhookID      string    `json:"webhook_id"`
+	EventType      string    `json:"event_type"`
+	Payload        string    `json:"payload"`
+	Status         string    `json:"status"`
+	Attempts       int       `json:"attempts"`
+	ResponseStatus int       `json:"response_status"`
+	Error          string    `json:"error"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// NotificationChannel delivers alerts and run failures to a chat, push or email service
+type NotificationChannel struct {
+	ID            string             `json:"id"`
+	Name          string             `json:"name"`
+	Type          string             `json:"type"`
+	Config        NotificationConfig `json:"config"`
+	EventTypes    []string           `json:"event_types"`
+	TitleTemplate string             `json:"title_template"`
+	BodyTemplate  string             `json:"body_template"`
+	IsActive      bool               `json:"is_active"`
+	CreatedAt     time.Time          `json:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+}
+
+// NotificationConfig holds the settings of every channel type; each type only reads its own fields.
+// Token and Password are never returned by the API.
+type NotificationConfig struct {
+	// ntfy, gotify, slack and discord
+	URL      string `json:"url,omitempty"`
+	Token    string `json:"token,omitempty"`
+	Priority int    `json:"priority,omitempty"`
+
+	// smtp
+	Host     string   `json:"host,omitempty"`
+	Port     int      `json:"port,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+	StartTLS bool     `json:"starttls,omitempty"`
+}
+
+type AlertRule struct {
+	ID              string     `json:"id"`
+	Name            string     `json:"name"`
+	RuleType        string     `json:"rule_type"`
+	Metric          string     `json:"metric"`
+	Operator        string     `json:"operator"`
+	Threshold       float64    `json:"threshold"`
+	Consecutive     int        `json:"consecutive"`
+	WindowMinutes   int        `json:"window_minutes"`
+	Aggregate       string     `json:"aggregate"`
+	ScheduleID      string     `json:"schedule_id"`
+	ProviderID      string     `json:"provider_id"`
+	ServerName      string     `json:"server_name"`
+	IsActive        bool       `json:"is_active"`
+	State           string     `json:"state"`
+	LastValue       float64    `json:"last_value"`
+	LastEvaluatedAt *time.Time `json:"last_evaluated_at"`
+	StateChangedAt  *time.Time `json:"state_changed_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+type AlertEvent struct {
+	ID        string    `json:"id"`
+	RuleID    string    `json:"rule_id"`
+	RuleName  string    `json:"rule_name"`
+	State     string    `json:"state"`
+	Value     float64   `json:"value"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type Provider struct {
@@ -71,6 +213,257 @@ type SpeedTestRequest struct {
 	HostEndpoint string   `json:"hostEndpoint"`
 	HostPort     string   `json:"hostPort"`
 	ScheduleID   string   `json:"scheduleID"`
+	// RoundID groups the results of one tournament round
+	RoundID string `json:"roundID,omitempty"`
+}
+
+// RunEvent describes the outcome of a single provider run
+type RunEvent struct {
+	ScheduleID   string    `json:"schedule_id"`
+	ProviderName string    `json:"provider_name"`
+	Status       string    `json:"status"`
+	Error        string    `json:"error"`
+	StartedAt    time.Time `json:"started_at"`
+	DurationMs   int64     `json:"duration_ms"`
+}
+
+// BulkResultsRequest describes an operation applied to every result matching the request filters
+type BulkResultsRequest struct {
+	Action     string `json:"action"`
+	Tag        string `json:"tag"`
+	DryRun     bool   `json:"dryRun"`
+	ConfirmAll bool   `json:"confirmAll"`
+}
+
+// Paging describes a page of results. Next and Prev are opaque cursors for the older and newer
+// pages, and are empty when there is no such page. Total is only set when it was requested.
+type Paging struct {
+	Limit int    `json:"limit"`
+	Next  string `json:"next,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+	Total *int64 `json:"total,omitempty"`
+}
+
+// ResultsPage is the response of GET /api/speedtest
+type ResultsPage struct {
+	Data   []SpeedTestResult `json:"data"`
+	Paging Paging            `json:"paging"`
+}
+
+type BulkResultsResponse struct {
+	Action   string `json:"action"`
+	Tag      string `json:"tag,omitempty"`
+	DryRun   bool   `json:"dry_run"`
+	Matched  int64  `json:"matched"`
+	Affected int64  `json:"affected"`
+}
+
+// ImportRowError describes a row that could not be imported
+type ImportRowError struct {
+	File  string `json:"file"`
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// ImportReport summarizes the outcome of a results import
+type ImportReport struct {
+	Format     string           `json:"format"`
+	Provider   string           `json:"provider"`
+	DryRun     bool             `json:"dry_run"`
+	Total      int              `json:"total"`
+	Imported   int              `json:"imported"`
+	Duplicates int              `json:"duplicates"`
+	Invalid    int              `json:"invalid"`
+	Errors     []ImportRowError `json:"errors"`
+}
+
+// AggregateBucket holds the statistics of one metric over one time bucket and group
+type AggregateBucket struct {
+	Bucket time.Time `json:"bucket"`
+	Group  string    `json:"group,omitempty"`
+	Min    float64   `json:"min"`
+	Avg    float64   `json:"avg"`
+	Max    float64   `json:"max"`
+	Count  int64     `json:"count"`
+}
+
+type AggregateResponse struct {
+	Bucket   string            `json:"bucket"`
+	Timezone string            `json:"timezone"`
+	Metric   string            `json:"metric"`
+	GroupBy  string            `json:"group_by,omitempty"`
+	Buckets  []AggregateBucket `json:"buckets"`
+}
+
+// RetentionPolicyPreview counts the results one retention policy would delete
+type RetentionPolicyPreview struct {
+	Policy       string     `json:"policy"`
+	ScheduleID   string     `json:"schedule_id,omitempty"`
+	ScheduleName string     `json:"schedule_name,omitempty"`
+	MaxAgeDays   int        `json:"max_age_days"`
+	Cutoff       time.Time  `json:"cutoff"`
+	Results      int64      `json:"results"`
+	Oldest       *time.Time `json:"oldest"`
+}
+
+// RetentionRollupPreview counts the raw results that would be rolled up and removed
+type RetentionRollupPreview struct {
+	RawDays int       `json:"raw_days"`
+	Cutoff  time.Time `json:"cutoff"`
+	Results int64     `json:"results"`
+}
+
+// RetentionPreview describes what the next run of the retention job would do
+type RetentionPreview struct {
+	MaxAgeDays   int                      `json:"max_age_days"`
+	Policies     []RetentionPolicyPreview `json:"policies"`
+	TotalResults int64                    `json:"total_results"`
+	Rollup       *RetentionRollupPreview  `json:"rollup,omitempty"`
+}
+
+// HeatmapCell summarizes one metric over every result in one weekday and hour.
+// Median is null when the cell has no results.
+type HeatmapCell struct {
+	Median *float64 `json:"median"`
+	Count  int64    `json:"count"`
+}
+
+// HeatmapResponse is indexed by weekday (0 is Sunday) and then by hour of day in Timezone
+type HeatmapResponse struct {
+	Metric   string             `json:"metric"`
+	Timezone string             `json:"timezone"`
+	Cells    [7][24]HeatmapCell `json:"cells"`
+}
+
+// RawDataTruncation is embedded in the responses of endpoints that need individual results, which
+// the rollups of the retention job don't have
+type RawDataTruncation struct {
+	// RawDataTruncatedBefore is set when results in the requested range were rolled up by the
+	// retention job: before it, the response only covers the raw results that remain
+	RawDataTruncatedBefore *time.Time `json:"raw_data_truncated_before,omitempty"`
+}
+
+// MetricStatistics describes the distribution of one metric
+type MetricStatistics struct {
+	Count  int64   `json:"count"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	P5     float64 `json:"p5"`
+	P25    float64 `json:"p25"`
+	P50    float64 `json:"p50"`
+	P75    float64 `json:"p75"`
+	P95    float64 `json:"p95"`
+	P99    float64 `json:"p99"`
+}
+
+type StatisticsGroup struct {
+	Group   string                      `json:"group,omitempty"`
+	Count   int64                       `json:"count"`
+	Metrics map[string]MetricStatistics `json:"metrics"`
+}
+
+type StatisticsResponse struct {
+	GroupBy string            `json:"group_by,omitempty"`
+	Groups  []StatisticsGroup `json:"groups"`
+}
+
+// MetricComparison compares one metric over paired results of two providers.
+// Differences are A minus B, and a win is the better value: higher speeds, lower ping and jitter.
+// Pairs counts the pairs where both results have the metric; the others are left out.
+type MetricComparison struct {
+	Pairs       int      `json:"pairs"`
+	MeanA       float64  `json:"mean_a"`
+	MeanB       float64  `json:"mean_b"`
+	MeanDiff    float64  `json:"mean_diff"`
+	MedianDiff  float64  `json:"median_diff"`
+	WinsA       int      `json:"wins_a"`
+	WinsB       int      `json:"wins_b"`
+	Ties        int      `json:"ties"`
+	WinRateA    float64  `json:"win_rate_a"`
+	WinRateB    float64  `json:"win_rate_b"`
+	Correlation *float64 `json:"correlation"`
+	TStatistic  *float64 `json:"t_statistic"`
+	PValue      *float64 `json:"p_value"`
+	Significant bool     `json:"significant"`
+}
+
+type CompareResponse struct {
+	ProviderA     Provider                    `json:"provider_a"`
+	ProviderB     Provider                    `json:"provider_b"`
+	WindowMinutes int                         `json:"window_minutes"`
+	Pairs         int                         `json:"pairs"`
+	Metrics       map[string]MetricComparison `json:"metrics"`
+	RawDataTruncation
+}
+
+// RatingPoint is a subject's rating after one tournament round
+type RatingPoint struct {
+	RoundID   string    `json:"round_id"`
+	Rating    float64   `json:"rating"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LeaderboardEntry is the current rating of a provider or server for one metric
+type LeaderboardEntry struct {
+	Rank      int           `json:"rank"`
+	Subject   string        `json:"subject"`
+	Rating    float64       `json:"rating"`
+	Games     int           `json:"games"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	History   []RatingPoint `json:"history,omitempty"`
+}
+
+// Leaderboard ranks the providers or servers of tournament rounds by their Elo rating for one metric
+type Leaderboard struct {
+	SubjectType string             `json:"subject_type"`
+	Metric      string             `json:"metric"`
+	Entries     []LeaderboardEntry `json:"entries"`
+}
+
+// SLAReport summarizes how often results met a share of the advertised plan speeds
+type SLAReport struct {
+	From             time.Time         `json:"from"`
+	To               time.Time         `json:"to"`
+	Timezone         string            `json:"timezone"`
+	PlanID           string            `json:"plan_id,omitempty"`
+	PlanName         string            `json:"plan_name,omitempty"`
+	ThresholdPercent float64           `json:"threshold_percent"`
+	Total            int               `json:"total"`
+	Compliant        int               `json:"compliant"`
+	CompliancePct    float64           `json:"compliance_percent"`
+	AvgDownloadPct   float64           `json:"avg_download_percent"`
+	AvgUploadPct     float64           `json:"avg_upload_percent"`
+	WorstHours       []SLAHour         `json:"worst_hours"`
+	LongestDegraded  *SLADegradedRun   `json:"longest_degraded_run"`
+	Daily            []SLADailySummary `json:"daily"`
+	RawDataTruncation
+}
+
+// SLAHour is the compliance of all results that ran during one hour of the day
+type SLAHour struct {
+	Hour           int     `json:"hour"`
+	Total          int     `json:"total"`
+	Compliant      int     `json:"compliant"`
+	CompliancePct  float64 `json:"compliance_percent"`
+	AvgDownloadPct float64 `json:"avg_download_percent"`
+}
+
+// SLADegradedRun is a streak of consecutive results that missed the threshold
+type SLADegradedRun struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Count int       `json:"count"`
+}
+
+type SLADailySummary struct {
+	Date           string  `json:"date"`
+	Total          int     `json:"total"`
+	Compliant      int     `json:"compliant"`
+	CompliancePct  float64 `json:"compliance_percent"`
+	AvgDownloadPct float64 `json:"avg_download_percent"`
+	AvgUploadPct   float64 `json:"avg_upload_percent"`
 }
 
 // Iperf3Result represents the JSON output from iperf3 command
@@ -220,15 +613,16 @@ func (i *Iperf3Result) ToSpeedTestResult(providerID, providerName string) SpeedT
 			URL:  serverURL,
 		},
 		Client: struct {
-			IP       string `json:"ip"`
-			Hostname string `json:"hostname"`
-			City     string `json:"city"`
-			Region   string `json:"region"`
-			Country  string `json:"country"`
-			Loc      string `json:"loc"`
-			Org      string `json:"org"`
-			Postal   string `json:"postal"`
-			Timezone string `json:"timezone"`
+			IP        string `json:"ip"`
+			Hostname  string `json:"hostname"`
+			City      string `json:"city"`
+			Region    string `json:"region"`
+			Country   string `json:"country"`
+			Loc       string `json:"loc"`
+			Org       string `json:"org"`
+			Postal    string `json:"postal"`
+			Timezone  string `json:"timezone"`
+			Interface string `json:"interface"`
 		}{
 			IP:       clientIP,
 			Hostname: clientHostname,
@@ -0,0 +1,358 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/database"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+)
+
+const (
+	payloadOnline  = "online"
+	payloadOffline = "offline"
+
+	publishTimeout = 5 * time.Second
+)
+
+// Config holds the broker connection and topic settings for the MQTT publisher
+type Config struct {
+	Broker          string `yaml:"broker" toml:"broker"`
+	Username        string `yaml:"username" toml:"username"`
+	Password        string `yaml:"password" toml:"password"`
+	ClientID        string `yaml:"client_id" toml:"client_id"`
+	TopicPrefix     string `yaml:"topic_prefix" toml:"topic_prefix"`
+	DiscoveryPrefix string `yaml:"discovery_prefix" toml:"discovery_prefix"`
+	Discovery       bool   `yaml:"discovery" toml:"discovery"`
+	QoS             byte   `yaml:"qos" toml:"qos"`
+	Retain          bool   `yaml:"retain" toml:"retain"`
+}
+
+// DefaultConfig returns the topic and delivery defaults. The publisher stays disabled until a broker
+// is set.
+func DefaultConfig() Config {
+	return Config{
+		ClientID:        "botb-backend",
+		TopicPrefix:     "botb",
+		DiscoveryPrefix: "homeassistant",
+		Discovery:       true,
+		QoS:             1,
+		Retain:          true,
+	}
+}
+
+// Enabled reports whether results should be published to a broker
+func (c Config) Enabled() bool {
+	return c.Broker != ""
+}
+
+// Validate checks the configuration of an enabled publisher
+func (c Config) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	if c.ClientID == "" {
+		return fmt.Errorf("client ID is required")
+	}
+	if strings.TrimRight(c.TopicPrefix, "/") == "" {
+		return fmt.Errorf("topic prefix is required")
+	}
+	if c.Discovery && c.DiscoveryPrefix == "" {
+		return fmt.Errorf("discovery prefix is required when discovery is enabled")
+	}
+	if c.QoS > 2 {
+		return fmt.Errorf("invalid QoS: %d", c.QoS)
+	}
+	return nil
+}
+
+// Publisher sends stored results and run outcomes to an MQTT broker and announces
+// per-schedule sensors through Home Assistant MQTT discovery
+type Publisher struct {
+	config Config
+	client paho.Client
+
+	discoveryMutex sync.Mutex
+	discovered     map[string]bool
+}
+
+// NewPublisher connects to the broker in the background, retrying until it is reachable
+func NewPublisher(config Config) *Publisher {
+	config.TopicPrefix = strings.TrimRight(config.TopicPrefix, "/")
+	p := &Publisher{
+		config:     config,
+		discovered: make(map[string]bool),
+	}
+
+	options := paho.NewClientOptions().
+		AddBroker(config.Broker).
+		SetClientID(config.ClientID).
+		SetUsername(config.Username).
+		SetPassword(config.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(10*time.Second).
+		SetOrderMatters(false).
+		SetWill(p.AvailabilityTopic(), payloadOffline, config.QoS, true).
+		SetOnConnectHandler(func(paho.Client) {
+			log.Printf("Connected to MQTT broker %s", config.Broker)
+			p.publish(p.AvailabilityTopic(), payloadOnline, true)
+			go p.PublishDiscovery()
+		}).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			log.Printf("Lost connection to MQTT broker: %v", err)
+		})
+
+	p.client = paho.NewClient(options)
+	p.client.Connect()
+	return p
+}
+
+func (p *Publisher) Name() string {
+	return "mqtt"
+}
+
+// AvailabilityTopic is where the backend reports online, and the broker reports offline on disconnect
+func (p *Publisher) AvailabilityTopic() string {
+	return p.config.TopicPrefix + "/status"
+}
+
+// ResultsTopic receives every stored result
+func (p *Publisher) ResultsTopic() string {
+	return p.config.TopicPrefix + "/results"
+}
+
+// StateTopic holds the latest result of a schedule
+func (p *Publisher) StateTopic(scheduleID string) string {
+	return p.config.TopicPrefix + "/schedules/" + scheduleID + "/state"
+}
+
+// RunTopic holds the outcome of the latest run of a schedule
+func (p *Publisher) RunTopic(scheduleID string) string {
+	return p.config.TopicPrefix + "/schedules/" + scheduleID + "/last_run"
+}
+
+type resultState struct {
+	Timestamp    string  `json:"timestamp"`
+	Download     float64 `json:"download"`
+	Upload       float64 `json:"upload"`
+	Ping         float64 `json:"ping"`
+	Jitter       float64 `json:"jitter"`
+	ProviderName string  `json:"provider_name"`
+	ServerName   string  `json:"server_name"`
+	ScheduleID   string  `json:"schedule_id"`
+}
+
+func (p *Publisher) HandleResult(result models.SpeedTestResult) {
+	payload, err := json.Marshal(resultState{
+		Timestamp:    result.Timestamp,
+		Download:     result.Download,
+		Upload:       result.Upload,
+		Ping:         result.Ping,
+		Jitter:       result.Jitter,
+		ProviderName: result.ProviderName,
+		ServerName:   result.Server.Name,
+		ScheduleID:   result.ScheduleID,
+	})
+	if err != nil {
+		log.Printf("MQTT publisher: failed to encode result: %v", err)
+		return
+	}
+
+	p.publish(p.ResultsTopic(), string(payload), false)
+	if result.ScheduleID != "" {
+		p.publish(p.StateTopic(result.ScheduleID), string(payload), p.config.Retain)
+	}
+}
+
+func (p *Publisher) HandleRun(event models.RunEvent) {
+	if event.ScheduleID == "" {
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("MQTT publisher: failed to encode run event: %v", err)
+		return
+	}
+	p.publish(p.RunTopic(event.ScheduleID), string(payload), p.config.Retain)
+}
+
+// discoveryConfig is a Home Assistant MQTT discovery payload for a single sensor
+type discoveryConfig struct {
+	Name              string          `json:"name"`
+	UniqueID          string          `json:"unique_id"`
+	StateTopic        string          `json:"state_topic"`
+	ValueTemplate     string          `json:"value_template"`
+	UnitOfMeasurement string          `json:"unit_of_measurement,omitempty"`
+	DeviceClass       string          `json:"device_class,omitempty"`
+	StateClass        string          `json:"state_class,omitempty"`
+	Icon              string          `json:"icon,omitempty"`
+	AvailabilityTopic string          `json:"availability_topic"`
+	Device            discoveryDevice `json:"device"`
+}
+
+type discoveryDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model,omitempty"`
+}
+
+type discoverySensor struct {
+	key         string
+	name        string
+	unit        string
+	deviceClass string
+	stateClass  string
+	icon        string
+	runTopic    bool
+}
+
+var discoverySensors = []discoverySensor{
+	{key: "download", name: "Download", unit: "Mbit/s", deviceClass: "data_rate", stateClass: "measurement"},
+	{key: "upload", name: "Upload", unit: "Mbit/s", deviceClass: "data_rate", stateClass: "measurement"},
+	{key: "ping", name: "Ping", unit: "ms", deviceClass: "duration", stateClass: "measurement"},
+	{key: "jitter", name: "Jitter", unit: "ms", deviceClass: "duration", stateClass: "measurement"},
+	{key: "status", name: "Last Run Status", icon: "mdi:speedometer", runTopic: true},
+}
+
+func (p *Publisher) discoveryTopic(scheduleID, sensorKey string) string {
+	return fmt.Sprintf("%s/sensor/botb_%s/%s/config", p.config.DiscoveryPrefix, scheduleID, sensorKey)
+}
+
+// PublishDiscovery announces the sensors of every schedule and removes those of archived and deleted
+// schedules
+func (p *Publisher) PublishDiscovery() {
+	if !p.config.Discovery || !p.client.IsConnected() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := database.DB.Query(ctx, `
+		SELECT id, name, COALESCE(provider_name, ''), archived_at
+		FROM schedules
+		ORDER BY created_at
+	`)
+	if err != nil {
+		log.Printf("MQTT publisher: failed to load schedules for discovery: %v", err)
+		return
+	}
+	defer rows.Close()
+
+	var schedules []models.Schedule
+	for rows.Next() {
+		var s models.Schedule
+		if err := rows.Scan(&s.ID, &s.Name, &s.ProviderName, &s.ArchivedAt); err != nil {
+			log.Printf("MQTT publisher: failed to scan schedule: %v", err)
+			return
+		}
+		schedules = append(schedules, s)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("MQTT publisher: failed to read schedules: %v", err)
+		return
+	}
+
+	p.announce(schedules)
+}
+
+// announce publishes the discovery configs of the schedules that are not archived and clears those of
+// archived schedules and of schedules announced earlier that are no longer in the list
+func (p *Publisher) announce(schedules []models.Schedule) {
+	p.discoveryMutex.Lock()
+	defer p.discoveryMutex.Unlock()
+
+	current := make(map[string]bool)
+	var removed []string
+	for _, s := range schedules {
+		if s.ArchivedAt != nil {
+			// Archived schedules are cleared on every announcement, since they may have been
+			// announced before a restart emptied p.discovered
+			removed = append(removed, s.ID)
+			continue
+		}
+		current[s.ID] = true
+		device := discoveryDevice{
+			Identifiers:  []string{"botb_" + s.ID},
+			Name:         "BotB " + s.Name,
+			Manufacturer: "Battle of the Bandwidth",
+			Model:        s.ProviderName,
+		}
+
+		for _, sensor := range discoverySensors {
+			stateTopic := p.StateTopic(s.ID)
+			if sensor.runTopic {
+				stateTopic = p.RunTopic(s.ID)
+			}
+
+			payload, err := json.Marshal(discoveryConfig{
+				Name:              sensor.name,
+				UniqueID:          fmt.Sprintf("botb_%s_%s", s.ID, sensor.key),
+				StateTopic:        stateTopic,
+				ValueTemplate:     fmt.Sprintf("{{ value_json.%s }}", sensor.key),
+				UnitOfMeasurement: sensor.unit,
+				DeviceClass:       sensor.deviceClass,
+				StateClass:        sensor.stateClass,
+				Icon:              sensor.icon,
+				AvailabilityTopic: p.AvailabilityTopic(),
+				Device:            device,
+			})
+			if err != nil {
+				log.Printf("MQTT publisher: failed to encode discovery config: %v", err)
+				continue
+			}
+			p.publish(p.discoveryTopic(s.ID, sensor.key), string(payload), true)
+		}
+	}
+
+	for scheduleID := range p.discovered {
+		if !current[scheduleID] {
+			removed = append(removed, scheduleID)
+		}
+	}
+
+	// An empty retained config tells Home Assistant to remove the sensor
+	cleared := make(map[string]bool)
+	for _, scheduleID := range removed {
+		if cleared[scheduleID] {
+			continue
+		}
+		cleared[scheduleID] = true
+		for _, sensor := range discoverySensors {
+			p.publish(p.discoveryTopic(scheduleID, sensor.key), "", true)
+		}
+	}
+	p.discovered = current
+}
+
+// Close marks the backend as offline and disconnects from the broker
+func (p *Publisher) Close() {
+	if p.client.IsConnected() {
+		token := p.client.Publish(p.AvailabilityTopic(), p.config.QoS, true, payloadOffline)
+		token.WaitTimeout(publishTimeout)
+	}
+	p.client.Disconnect(250)
+}
+
+// publish sends a message without blocking the caller and logs delivery failures
+func (p *Publisher) publish(topic, payload string, retain bool) {
+	token := p.client.Publish(topic, p.config.QoS, retain, payload)
+	go func() {
+		if !token.WaitTimeout(publishTimeout) {
+			log.Printf("MQTT publisher: timed out publishing to %s", topic)
+			return
+		}
+		if err := token.Error(); err != nil {
+			log.Printf("MQTT publisher: failed to publish to %s: %v", topic, err)
+		}
+	}()
+}
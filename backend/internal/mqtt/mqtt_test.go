@@ -0,0 +1,428 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+)
+
+// message is a PUBLISH received by the broker stand-in
+type message struct {
+	topic   string
+	payload string
+	qos     byte
+	retain  bool
+}
+
+// testBroker is a minimal MQTT 3.1.1 broker that accepts every connection, acknowledges QoS 1 and 2
+// publishes and records them. It does not deliver messages to subscribers.
+type testBroker struct {
+	listener net.Listener
+	messages chan message
+	wills    chan message
+}
+
+func newTestBroker(t *testing.T) *testBroker {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	b := &testBroker{
+		listener: listener,
+		messages: make(chan message, 100),
+		wills:    make(chan message, 10),
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(t, conn)
+		}
+	}()
+	return b
+}
+
+func (b *testBroker) URL() string {
+	return "tcp://" + b.listener.Addr().String()
+}
+
+func (b *testBroker) serve(t *testing.T, conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	for {
+		header, err := reader.ReadByte()
+		if err != nil {
+			return
+		}
+		length, err := readRemainingLength(reader)
+		if err != nil {
+			return
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			return
+		}
+
+		switch header >> 4 {
+		case 1: // CONNECT
+			if will, ok := parseWill(body); ok {
+				b.wills <- will
+			}
+			conn.Write([]byte{0x20, 0x02, 0x00, 0x00})
+		case 3: // PUBLISH
+			m := message{qos: (header >> 1) & 0x03, retain: header&0x01 == 1}
+			topicLength := int(binary.BigEndian.Uint16(body))
+			m.topic = string(body[2 : 2+topicLength])
+			rest := body[2+topicLength:]
+			var packetID []byte
+			if m.qos > 0 {
+				packetID, rest = rest[:2], rest[2:]
+			}
+			m.payload = string(rest)
+			b.messages <- m
+
+			switch m.qos {
+			case 1:
+				conn.Write(append([]byte{0x40, 0x02}, packetID...))
+			case 2:
+				conn.Write(append([]byte{0x50, 0x02}, packetID...))
+			}
+		case 6: // PUBREL
+			conn.Write(append([]byte{0x70, 0x02}, body[:2]...))
+		case 12: // PINGREQ
+			conn.Write([]byte{0xd0, 0x00})
+		case 14: // DISCONNECT
+			return
+		default:
+			t.Errorf("broker stand-in received unsupported packet type %d", header>>4)
+			return
+		}
+	}
+}
+
+func readRemainingLength(reader *bufio.Reader) (int, error) {
+	length, multiplier := 0, 1
+	for i := 0; i < 4; i++ {
+		digit, err := reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		length += int(digit&0x7f) * multiplier
+		if digit&0x80 == 0 {
+			return length, nil
+		}
+		multiplier *= 128
+	}
+	return 0, errors.New("malformed remaining length")
+}
+
+// parseWill extracts the last will from a CONNECT packet
+func parseWill(body []byte) (message, bool) {
+	protocolLength := int(binary.BigEndian.Uint16(body))
+	flags := body[2+protocolLength+1]
+	if flags&0x04 == 0 {
+		return message{}, false
+	}
+
+	payload := body[2+protocolLength+4:]
+	readString := func() string {
+		length := int(binary.BigEndian.Uint16(payload))
+		s := string(payload[2 : 2+length])
+		payload = payload[2+length:]
+		return s
+	}
+	readString() // client ID
+	return message{
+		topic:   readString(),
+		payload: readString(),
+		qos:     (flags >> 3) & 0x03,
+		retain:  flags&0x20 != 0,
+	}, true
+}
+
+// next returns the next message published to the broker
+func (b *testBroker) next(t *testing.T) message {
+	t.Helper()
+	select {
+	case m := <-b.messages:
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a message")
+		return message{}
+	}
+}
+
+// collect returns the next n messages by topic
+func (b *testBroker) collect(t *testing.T, n int) map[string]message {
+	t.Helper()
+	messages := make(map[string]message)
+	for i := 0; i < n; i++ {
+		m := b.next(t)
+		if _, exists := messages[m.topic]; exists {
+			t.Fatalf("topic %s published twice", m.topic)
+		}
+		messages[m.topic] = m
+	}
+	return messages
+}
+
+// expectNoMessage fails when another message is published shortly
+func (b *testBroker) expectNoMessage(t *testing.T) {
+	t.Helper()
+	select {
+	case m := <-b.messages:
+		t.Fatalf("unexpected message on %s: %q", m.topic, m.payload)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// publisherConfig points a publisher at the broker. Discovery is off, since announcing on connect
+// reads the schedules from the database; tests call announce.
+func (b *testBroker) publisherConfig() Config {
+	config := DefaultConfig()
+	config.Broker = b.URL()
+	config.Discovery = false
+	return config
+}
+
+// connect starts a publisher and waits for its availability message
+func (b *testBroker) connect(t *testing.T, config Config) *Publisher {
+	t.Helper()
+	p := NewPublisher(config)
+	t.Cleanup(p.Close)
+
+	online := b.next(t)
+	if online.topic != p.AvailabilityTopic() || online.payload != payloadOnline || !online.retain {
+		t.Fatalf("first message = %+v, want retained %q on %s", online, payloadOnline, p.AvailabilityTopic())
+	}
+	return p
+}
+
+func TestPublisherAvailability(t *testing.T) {
+	broker := newTestBroker(t)
+	config := broker.publisherConfig()
+	config.TopicPrefix = "home/botb/"
+	config.QoS = 2
+	broker.connect(t, config)
+
+	select {
+	case will := <-broker.wills:
+		want := message{topic: "home/botb/status", payload: payloadOffline, qos: 2, retain: true}
+		if will != want {
+			t.Errorf("will = %+v, want %+v", will, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("publisher connected without a will")
+	}
+}
+
+func TestHandleResult(t *testing.T) {
+	tests := []struct {
+		name       string
+		qos        byte
+		retain     bool
+		scheduleID string
+		want       []message
+	}{
+		{
+			name:       "scheduled result",
+			qos:        1,
+			retain:     true,
+			scheduleID: "s1",
+			want: []message{
+				{topic: "botb/results", qos: 1, retain: false},
+				{topic: "botb/schedules/s1/state", qos: 1, retain: true},
+			},
+		},
+		{
+			name:       "retain disabled",
+			qos:        0,
+			retain:     false,
+			scheduleID: "s1",
+			want: []message{
+				{topic: "botb/results", qos: 0, retain: false},
+				{topic: "botb/schedules/s1/state", qos: 0, retain: false},
+			},
+		},
+		{
+			name:   "manual result has no state topic",
+			qos:    1,
+			retain: true,
+			want: []message{
+				{topic: "botb/results", qos: 1, retain: false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := newTestBroker(t)
+			config := broker.publisherConfig()
+			config.QoS = tt.qos
+			config.Retain = tt.retain
+			p := broker.connect(t, config)
+
+			result := models.SpeedTestResult{
+				Timestamp:    "2024-05-01T12:00:00Z",
+				Download:     512.5,
+				Upload:       48,
+				Ping:         12.5,
+				Jitter:       0.75,
+				ProviderName: "librespeed",
+				ScheduleID:   tt.scheduleID,
+			}
+			result.Server.Name = "Example Server"
+			p.HandleResult(result)
+
+			got := broker.collect(t, len(tt.want))
+			for _, want := range tt.want {
+				m, ok := got[want.topic]
+				if !ok {
+					t.Errorf("nothing published to %s", want.topic)
+					continue
+				}
+				if m.qos != want.qos || m.retain != want.retain {
+					t.Errorf("%s: qos %d retain %v, want qos %d retain %v", want.topic, m.qos, m.retain, want.qos, want.retain)
+				}
+
+				var state resultState
+				if err := json.Unmarshal([]byte(m.payload), &state); err != nil {
+					t.Fatalf("%s: invalid payload %q: %v", want.topic, m.payload, err)
+				}
+				wantState := resultState{
+					Timestamp:    "2024-05-01T12:00:00Z",
+					Download:     512.5,
+					Upload:       48,
+					Ping:         12.5,
+					Jitter:       0.75,
+					ProviderName: "librespeed",
+					ServerName:   "Example Server",
+					ScheduleID:   tt.scheduleID,
+				}
+				if state != wantState {
+					t.Errorf("%s: payload %+v, want %+v", want.topic, state, wantState)
+				}
+			}
+			broker.expectNoMessage(t)
+		})
+	}
+}
+
+func TestHandleRun(t *testing.T) {
+	broker := newTestBroker(t)
+	p := broker.connect(t, broker.publisherConfig())
+
+	// Manual runs have no schedule to report on
+	p.HandleRun(models.RunEvent{Status: "success"})
+	p.HandleRun(models.RunEvent{ScheduleID: "s1", ProviderName: "iperf3", Status: "failure", Error: "timeout"})
+
+	m := broker.next(t)
+	if m.topic != "botb/schedules/s1/last_run" || !m.retain || m.qos != 1 {
+		t.Fatalf("message = %+v, want retained QoS 1 on botb/schedules/s1/last_run", m)
+	}
+	var event models.RunEvent
+	if err := json.Unmarshal([]byte(m.payload), &event); err != nil {
+		t.Fatalf("invalid payload %q: %v", m.payload, err)
+	}
+	if event.Status != "failure" || event.Error != "timeout" || event.ProviderName != "iperf3" {
+		t.Errorf("event = %+v", event)
+	}
+	broker.expectNoMessage(t)
+}
+
+func TestAnnounce(t *testing.T) {
+	broker := newTestBroker(t)
+	p := broker.connect(t, broker.publisherConfig())
+
+	archivedAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	p.announce([]models.Schedule{
+		{ID: "s1", Name: "Office", ProviderName: "librespeed"},
+		{ID: "s2", Name: "Old", ProviderName: "iperf3", ArchivedAt: &archivedAt},
+	})
+
+	got := broker.collect(t, 2*len(discoverySensors))
+	for _, sensor := range discoverySensors {
+		topic := fmt.Sprintf("homeassistant/sensor/botb_s1/%s/config", sensor.key)
+		m, ok := got[topic]
+		if !ok {
+			t.Errorf("no discovery config on %s", topic)
+			continue
+		}
+		if !m.retain || m.qos != 1 {
+			t.Errorf("%s: qos %d retain %v, want retained QoS 1", topic, m.qos, m.retain)
+		}
+
+		var config discoveryConfig
+		if err := json.Unmarshal([]byte(m.payload), &config); err != nil {
+			t.Fatalf("%s: invalid payload %q: %v", topic, m.payload, err)
+		}
+		wantStateTopic := "botb/schedules/s1/state"
+		if sensor.runTopic {
+			wantStateTopic = "botb/schedules/s1/last_run"
+		}
+		if config.UniqueID != "botb_s1_"+sensor.key ||
+			config.StateTopic != wantStateTopic ||
+			config.ValueTemplate != "{{ value_json."+sensor.key+" }}" ||
+			config.UnitOfMeasurement != sensor.unit ||
+			config.AvailabilityTopic != "botb/status" ||
+			config.Device.Name != "BotB Office" ||
+			config.Device.Model != "librespeed" ||
+			len(config.Device.Identifiers) != 1 || config.Device.Identifiers[0] != "botb_s1" {
+			t.Errorf("%s: config %+v", topic, config)
+		}
+
+		// The archived schedule is cleared rather than announced
+		archivedTopic := fmt.Sprintf("homeassistant/sensor/botb_s2/%s/config", sensor.key)
+		if m, ok := got[archivedTopic]; !ok || m.payload != "" || !m.retain {
+			t.Errorf("%s: got %+v, want an empty retained config", archivedTopic, m)
+		}
+	}
+	broker.expectNoMessage(t)
+
+	// Once s1 is archived and s2 deleted, only s1 is cleared: s2 was never announced
+	p.announce([]models.Schedule{
+		{ID: "s1", Name: "Office", ProviderName: "librespeed", ArchivedAt: &archivedAt},
+	})
+	got = broker.collect(t, len(discoverySensors))
+	for _, sensor := range discoverySensors {
+		topic := fmt.Sprintf("homeassistant/sensor/botb_s1/%s/config", sensor.key)
+		if m, ok := got[topic]; !ok || m.payload != "" || !m.retain {
+			t.Errorf("%s: got %+v, want an empty retained config", topic, m)
+		}
+	}
+	broker.expectNoMessage(t)
+}
+
+func TestAnnounceClearsDeletedSchedules(t *testing.T) {
+	broker := newTestBroker(t)
+	config := broker.publisherConfig()
+	config.DiscoveryPrefix = "ha"
+	p := broker.connect(t, config)
+
+	p.announce([]models.Schedule{{ID: "s1", Name: "Office"}})
+	broker.collect(t, len(discoverySensors))
+
+	p.announce(nil)
+	got := broker.collect(t, len(discoverySensors))
+	for _, sensor := range discoverySensors {
+		topic := fmt.Sprintf("ha/sensor/botb_s1/%s/config", sensor.key)
+		if m, ok := got[topic]; !ok || m.payload != "" || !m.retain {
+			t.Errorf("%s: got %+v, want an empty retained config", topic, m)
+		}
+	}
+	broker.expectNoMessage(t)
+}
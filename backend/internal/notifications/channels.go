@@ -0,0 +1,254 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+)
+
+// Channel types
+const (
+	TypeNtfy    = "ntfy"
+	TypeGotify  = "gotify"
+	TypeSlack   = "slack"
+	TypeDiscord = "discord"
+	TypeSMTP    = "smtp"
+)
+
+const sendTimeout = 10 * time.Second
+
+var httpClient = &http.Client{Timeout: sendTimeout}
+
+// Message is a rendered notification
+type Message struct {
+	Title string
+	Body  string
+}
+
+// Notifier sends a message through one channel type
+type Notifier interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// newNotifier builds the notifier for a channel's type and configuration
+func newNotifier(channel models.NotificationChannel) (Notifier, error) {
+	config := channel.Config
+	switch channel.Type {
+	case TypeNtfy:
+		return &ntfyNotifier{config: config}, nil
+	case TypeGotify:
+		return &gotifyNotifier{config: config}, nil
+	case TypeSlack:
+		return &chatNotifier{url: config.URL, format: slackPayload}, nil
+	case TypeDiscord:
+		return &chatNotifier{url: config.URL, format: discordPayload}, nil
+	case TypeSMTP:
+		return &smtpNotifier{config: config}, nil
+	default:
+		return nil, fmt.Errorf("unknown channel type: %q", channel.Type)
+	}
+}
+
+// validateConfig checks the settings required by a channel type
+func validateConfig(channelType string, config models.NotificationConfig) error {
+	switch channelType {
+	case TypeNtfy, TypeGotify, TypeSlack, TypeDiscord:
+		parsed, err := url.Parse(config.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("config.url must be an absolute http or https URL")
+		}
+		if channelType == TypeGotify && config.Token == "" {
+			return errors.New("config.token is required for gotify")
+		}
+	case TypeSMTP:
+		if config.Host == "" {
+			return errors.New("config.host is required for smtp")
+		}
+		if config.From == "" || len(config.To) == 0 {
+			return errors.New("config.from and config.to are required for smtp")
+		}
+	default:
+		return fmt.Errorf("unknown channel type: %q", channelType)
+	}
+	return nil
+}
+
+// ntfyNotifier publishes to an ntfy topic URL, e.g. https://ntfy.sh/my-topic
+type ntfyNotifier struct {
+	config models.NotificationConfig
+}
+
+func (n *ntfyNotifier) Send(ctx context.Context, message Message) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.config.URL, strings.NewReader(message.Body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Title", message.Title)
+	if n.config.Priority > 0 {
+		req.Header.Set("Priority", strconv.Itoa(n.config.Priority))
+	}
+	if n.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.config.Token)
+	}
+	return doRequest(req)
+}
+
+// gotifyNotifier posts to the /message endpoint of a Gotify server with an application token
+type gotifyNotifier struct {
+	config models.NotificationConfig
+}
+
+func (g *gotifyNotifier) Send(ctx context.Context, message Message) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"title":    message.Title,
+		"message":  message.Body,
+		"priority": g.config.Priority,
+	})
+	if err != nil {
+		return err
+	}
+
+	endpoint := strings.TrimRight(g.config.URL, "/") + "/message"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gotify-Key", g.config.Token)
+	return doRequest(req)
+}
+
+// chatNotifier posts to a Slack or Discord compatible incoming webhook
+type chatNotifier struct {
+	url    string
+	format func(Message) interface{}
+}
+
+func slackPayload(message Message) interface{} {
+	return map[string]string{"text": fmt.Sprintf("*%s*\n%s", message.Title, message.Body)}
+}
+
+func discordPayload(message Message) interface{} {
+	return map[string]string{"content": fmt.Sprintf("**%s**\n%s", message.Title, message.Body)}
+}
+
+func (c *chatNotifier) Send(ctx context.Context, message Message) error {
+	payload, err := json.Marshal(c.format(message))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	return doRequest(req)
+}
+
+func doRequest(req *http.Request) error {
+	req.Header.Set("User-Agent", "battle-of-the-bandwidth-notifications")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("server returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// smtpNotifier sends a plain text email. Servers without STARTTLS, such as local test
+// servers, are used unencrypted unless starttls is set.
+type smtpNotifier struct {
+	config models.NotificationConfig
+}
+
+func (s *smtpNotifier) Send(ctx context.Context, message Message) error {
+	port := s.config.Port
+	if port == 0 {
+		port = 25
+	}
+	address := net.JoinHostPort(s.config.Host, strconv.Itoa(port))
+
+	dialer := &net.Dialer{Timeout: sendTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start smtp session: %w", err)
+	}
+	defer client.Close()
+
+	if s.config.StartTLS {
+		if err := client.StartTLS(&tls.Config{ServerName: s.config.Host}); err != nil {
+			return fmt.Errorf("starttls failed: %w", err)
+		}
+	}
+	if s.config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)); err != nil {
+			return fmt.Errorf("authentication failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(s.config.From); err != nil {
+		return fmt.Errorf("MAIL FROM rejected: %w", err)
+	}
+	for _, recipient := range s.config.To {
+		if err := client.Rcpt(recipient); err != nil {
+			return fmt.Errorf("RCPT TO %s rejected: %w", recipient, err)
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("DATA rejected: %w", err)
+	}
+	if _, err := writer.Write(buildEmail(s.config, message)); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("message rejected: %w", err)
+	}
+	return client.Quit()
+}
+
+func buildEmail(config models.NotificationConfig, message Message) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", config.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(config.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", strings.ReplaceAll(message.Title, "\n", " "))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	buf.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
@@ -0,0 +1,501 @@
+package notifications
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+)
+
+func runFailedData() TemplateData {
+	return TemplateData{
+		Type: EventRunFailed,
+		Time: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Run: &models.RunEvent{
+			ScheduleID:   "s1",
+			ProviderName: "iperf3",
+			Status:       "failure",
+			Error:        "connection refused",
+			StartedAt:    time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+			DurationMs:   1500,
+		},
+	}
+}
+
+const (
+	wantTitle = "[BotB] iperf3 run failed"
+	wantBody  = "The iperf3 speed test started at 2024-05-01 12:00:00 UTC failed after 1500 ms.\nSchedule: s1\nError: connection refused"
+)
+
+// httpRequest is a request received by the HTTP stand-in
+type httpRequest struct {
+	method string
+	path   string
+	header http.Header
+	body   string
+}
+
+// newHTTPServer starts a stand-in for an HTTP notification service that answers with status and
+// records the requests it receives
+func newHTTPServer(t *testing.T, status int, response string) (*httptest.Server, chan httpRequest) {
+	t.Helper()
+	requests := make(chan httpRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- httpRequest{method: r.Method, path: r.URL.Path, header: r.Header, body: string(body)}
+		w.WriteHeader(status)
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func decodeJSON(t *testing.T, body string) map[string]interface{} {
+	t.Helper()
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &payload); err != nil {
+		t.Fatalf("invalid JSON payload %q: %v", body, err)
+	}
+	return payload
+}
+
+func TestHTTPChannels(t *testing.T) {
+	tests := []struct {
+		name        string
+		channelType string
+		config      models.NotificationConfig
+		path        string
+		check       func(t *testing.T, req httpRequest)
+	}{
+		{
+			name:        "ntfy",
+			channelType: TypeNtfy,
+			config:      models.NotificationConfig{Token: "tk_secret", Priority: 4},
+			path:        "/botb-alerts",
+			check: func(t *testing.T, req httpRequest) {
+				if req.body != wantBody {
+					t.Errorf("body = %q, want %q", req.body, wantBody)
+				}
+				if got := req.header.Get("Title"); got != wantTitle {
+					t.Errorf("Title = %q, want %q", got, wantTitle)
+				}
+				if got := req.header.Get("Priority"); got != "4" {
+					t.Errorf("Priority = %q, want 4", got)
+				}
+				if got := req.header.Get("Authorization"); got != "Bearer tk_secret" {
+					t.Errorf("Authorization = %q", got)
+				}
+			},
+		},
+		{
+			name:        "ntfy without token or priority",
+			channelType: TypeNtfy,
+			path:        "/botb-alerts",
+			check: func(t *testing.T, req httpRequest) {
+				if _, ok := req.header["Priority"]; ok {
+					t.Error("Priority header set without a configured priority")
+				}
+				if _, ok := req.header["Authorization"]; ok {
+					t.Error("Authorization header set without a configured token")
+				}
+			},
+		},
+		{
+			name:        "gotify",
+			channelType: TypeGotify,
+			config:      models.NotificationConfig{Token: "app-token", Priority: 8},
+			path:        "/message",
+			check: func(t *testing.T, req httpRequest) {
+				if got := req.header.Get("X-Gotify-Key"); got != "app-token" {
+					t.Errorf("X-Gotify-Key = %q", got)
+				}
+				payload := decodeJSON(t, req.body)
+				if payload["title"] != wantTitle || payload["message"] != wantBody || payload["priority"] != float64(8) {
+					t.Errorf("payload = %v", payload)
+				}
+			},
+		},
+		{
+			name:        "slack",
+			channelType: TypeSlack,
+			path:        "/services/T000/B000/XXXX",
+			check: func(t *testing.T, req httpRequest) {
+				payload := decodeJSON(t, req.body)
+				if want := "*" + wantTitle + "*\n" + wantBody; payload["text"] != want || len(payload) != 1 {
+					t.Errorf("payload = %v, want text %q", payload, want)
+				}
+			},
+		},
+		{
+			name:        "discord",
+			channelType: TypeDiscord,
+			path:        "/api/webhooks/1/token",
+			check: func(t *testing.T, req httpRequest) {
+				payload := decodeJSON(t, req.body)
+				if want := "**" + wantTitle + "**\n" + wantBody; payload["content"] != want || len(payload) != 1 {
+					t.Errorf("payload = %v, want content %q", payload, want)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := newHTTPServer(t, http.StatusOK, "")
+			config := tt.config
+			config.URL = server.URL
+			if tt.channelType != TypeGotify {
+				config.URL += tt.path
+			}
+			if err := validateConfig(tt.channelType, config); err != nil {
+				t.Fatalf("validateConfig() error = %v", err)
+			}
+
+			channel := models.NotificationChannel{Name: tt.name, Type: tt.channelType, Config: config}
+			if err := send(context.Background(), channel, EventRunFailed, runFailedData()); err != nil {
+				t.Fatalf("send() error = %v", err)
+			}
+
+			req := <-requests
+			if req.method != http.MethodPost || req.path != tt.path {
+				t.Errorf("request = %s %s, want POST %s", req.method, req.path, tt.path)
+			}
+			if got := req.header.Get("User-Agent"); got != "battle-of-the-bandwidth-notifications" {
+				t.Errorf("User-Agent = %q", got)
+			}
+			if tt.channelType != TypeNtfy {
+				if got := req.header.Get("Content-Type"); got != "application/json" {
+					t.Errorf("Content-Type = %q, want application/json", got)
+				}
+			}
+			tt.check(t, req)
+		})
+	}
+}
+
+func TestHTTPChannelCustomTemplates(t *testing.T) {
+	server, requests := newHTTPServer(t, http.StatusNoContent, "")
+	channel := models.NotificationChannel{
+		Type:          TypeSlack,
+		Config:        models.NotificationConfig{URL: server.URL},
+		TitleTemplate: `{{.Run.ProviderName}} down`,
+		BodyTemplate:  `{{.Run.Error}} after {{.Run.DurationMs}} ms`,
+	}
+	if err := send(context.Background(), channel, EventRunFailed, runFailedData()); err != nil {
+		t.Fatalf("send() error = %v", err)
+	}
+
+	payload := decodeJSON(t, (<-requests).body)
+	if want := "*iperf3 down*\nconnection refused after 1500 ms"; payload["text"] != want {
+		t.Errorf("text = %q, want %q", payload["text"], want)
+	}
+}
+
+func TestCheckTemplates(t *testing.T) {
+	tests := []struct {
+		name       string
+		title      string
+		body       string
+		eventTypes []string
+		wantErr    string
+	}{
+		{name: "defaults", eventTypes: []string{EventAlertFiring, EventAlertResolved, EventRunFailed}},
+		{name: "alert fields on alert events", title: `{{.Alert.RuleName}}`, eventTypes: []string{EventAlertFiring, EventAlertResolved}},
+		{name: "shared fields on every event", body: `{{.Type}} at {{.Time.Unix}}`, eventTypes: []string{EventAlertFiring, EventRunFailed}},
+		{name: "guarded fields", body: `{{with .Alert}}{{.Message}}{{end}}{{with .Run}}{{.Error}}{{end}}`, eventTypes: []string{EventAlertFiring, EventRunFailed}},
+		{name: "alert fields on run failures", title: `{{.Alert.RuleName}}`, eventTypes: []string{EventAlertFiring, EventRunFailed}, wantErr: EventRunFailed},
+		{name: "run fields on alert events", body: `{{.Run.Error}}`, eventTypes: []string{EventRunFailed, EventAlertResolved}, wantErr: EventAlertResolved},
+		{name: "syntax error", title: `{{.Alert.RuleName`, eventTypes: []string{EventAlertFiring}, wantErr: "invalid title template"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channel := models.NotificationChannel{TitleTemplate: tt.title, BodyTemplate: tt.body, EventTypes: tt.eventTypes}
+			err := checkTemplates(channel)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkTemplates() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkTemplates() error = %v, want one mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHTTPChannelErrors(t *testing.T) {
+	for _, channelType := range []string{TypeNtfy, TypeGotify, TypeSlack, TypeDiscord} {
+		t.Run(channelType, func(t *testing.T) {
+			server, _ := newHTTPServer(t, http.StatusForbidden, "invalid token\n")
+			channel := models.NotificationChannel{
+				Type:   channelType,
+				Config: models.NotificationConfig{URL: server.URL, Token: "wrong"},
+			}
+
+			err := send(context.Background(), channel, EventTest, TemplateData{Type: EventTest})
+			if err == nil || !strings.Contains(err.Error(), "status 403") || !strings.Contains(err.Error(), "invalid token") {
+				t.Errorf("send() error = %v, want the status and response body", err)
+			}
+		})
+	}
+}
+
+func TestHTTPChannelUnreachable(t *testing.T) {
+	server, _ := newHTTPServer(t, http.StatusOK, "")
+	server.Close()
+
+	channel := models.NotificationChannel{Type: TypeDiscord, Config: models.NotificationConfig{URL: server.URL}}
+	if err := send(context.Background(), channel, EventTest, TemplateData{Type: EventTest}); err == nil {
+		t.Error("send() error = nil for an unreachable server")
+	}
+}
+
+// smtpSession is what the SMTP stand-in received in one session
+type smtpSession struct {
+	auth       string
+	from       string
+	recipients []string
+	data       string
+}
+
+// smtpServer is a local stand-in for an SMTP server without STARTTLS. rejectRcpt and dataReply
+// simulate a server that refuses a recipient or the message; dataReply defaults to accepting it.
+type smtpServer struct {
+	listener   net.Listener
+	sessions   chan smtpSession
+	advertise  []string
+	rejectRcpt string
+	dataReply  string
+}
+
+// listen starts accepting SMTP sessions on a local port
+func (s *smtpServer) listen(t *testing.T) *smtpServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s.listener = listener
+	s.sessions = make(chan smtpSession, 10)
+	if s.dataReply == "" {
+		s.dataReply = "250 queued"
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) config() models.NotificationConfig {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	return models.NotificationConfig{
+		Host: host,
+		Port: portNumber,
+		From: "botb@example.com",
+		To:   []string{"ops@example.com", "oncall@example.com"},
+	}
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) {
+		io.WriteString(conn, line+"\r\n")
+	}
+
+	var session smtpSession
+	defer func() { s.sessions <- session }()
+
+	reply("220 localhost ESMTP test")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch {
+		case command == "EHLO" || command == "HELO":
+			for _, extension := range s.advertise {
+				reply("250-" + extension)
+			}
+			reply("250 localhost")
+		case command == "AUTH":
+			session.auth = line
+			reply("235 authenticated")
+		case strings.HasPrefix(strings.ToUpper(line), "MAIL FROM:"):
+			session.from = strings.Trim(line[len("MAIL FROM:"):], "<> ")
+			reply("250 ok")
+		case strings.HasPrefix(strings.ToUpper(line), "RCPT TO:"):
+			recipient := strings.Trim(line[len("RCPT TO:"):], "<> ")
+			if recipient == s.rejectRcpt {
+				reply("550 no such user")
+				continue
+			}
+			session.recipients = append(session.recipients, recipient)
+			reply("250 ok")
+		case command == "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			session.data = data.String()
+			reply(s.dataReply)
+		case command == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func (s *smtpServer) session(t *testing.T) smtpSession {
+	t.Helper()
+	select {
+	case session := <-s.sessions:
+		return session
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the SMTP session")
+		return smtpSession{}
+	}
+}
+
+func TestSMTPChannel(t *testing.T) {
+	server := (&smtpServer{}).listen(t)
+	channel := models.NotificationChannel{Type: TypeSMTP, Config: server.config()}
+	if err := validateConfig(TypeSMTP, channel.Config); err != nil {
+		t.Fatalf("validateConfig() error = %v", err)
+	}
+
+	if err := send(context.Background(), channel, EventRunFailed, runFailedData()); err != nil {
+		t.Fatalf("send() error = %v", err)
+	}
+
+	session := server.session(t)
+	if session.auth != "" {
+		t.Errorf("authenticated without credentials: %q", session.auth)
+	}
+	if session.from != "botb@example.com" {
+		t.Errorf("MAIL FROM = %q", session.from)
+	}
+	if strings.Join(session.recipients, ",") != "ops@example.com,oncall@example.com" {
+		t.Errorf("RCPT TO = %v", session.recipients)
+	}
+
+	headers, body, found := strings.Cut(session.data, "\r\n\r\n")
+	if !found {
+		t.Fatalf("message has no header separator: %q", session.data)
+	}
+	for _, header := range []string{
+		"From: botb@example.com",
+		"To: ops@example.com, oncall@example.com",
+		"Subject: " + wantTitle,
+		"Content-Type: text/plain; charset=utf-8",
+	} {
+		if !strings.Contains(headers+"\r\n", header+"\r\n") {
+			t.Errorf("headers %q lack %q", headers, header)
+		}
+	}
+	if want := strings.ReplaceAll(wantBody, "\n", "\r\n") + "\r\n"; body != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+}
+
+func TestSMTPChannelAuth(t *testing.T) {
+	server := (&smtpServer{advertise: []string{"AUTH PLAIN"}}).listen(t)
+	config := server.config()
+	config.Username = "botb"
+	config.Password = "hunter2"
+
+	channel := models.NotificationChannel{Type: TypeSMTP, Config: config}
+	if err := send(context.Background(), channel, EventTest, TemplateData{Type: EventTest}); err != nil {
+		t.Fatalf("send() error = %v", err)
+	}
+
+	want := "AUTH PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00botb\x00hunter2"))
+	if got := server.session(t).auth; got != want {
+		t.Errorf("auth = %q, want %q", got, want)
+	}
+}
+
+func TestSMTPChannelErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		server   smtpServer
+		startTLS bool
+		wantErr  string
+	}{
+		{
+			name:    "recipient rejected",
+			server:  smtpServer{rejectRcpt: "oncall@example.com"},
+			wantErr: "RCPT TO oncall@example.com rejected",
+		},
+		{
+			name:    "message rejected",
+			server:  smtpServer{dataReply: "554 spam"},
+			wantErr: "message rejected",
+		},
+		{
+			name:     "starttls unsupported",
+			startTLS: true,
+			wantErr:  "starttls failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := tt.server.listen(t)
+			config := server.config()
+			config.StartTLS = tt.startTLS
+
+			channel := models.NotificationChannel{Type: TypeSMTP, Config: config}
+			err := send(context.Background(), channel, EventTest, TemplateData{Type: EventTest})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("send() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSMTPChannelUnreachable(t *testing.T) {
+	server := (&smtpServer{}).listen(t)
+	config := server.config()
+	server.listener.Close()
+
+	channel := models.NotificationChannel{Type: TypeSMTP, Config: config}
+	err := send(context.Background(), channel, EventTest, TemplateData{Type: EventTest})
+	if err == nil || !strings.Contains(err.Error(), "failed to connect") {
+		t.Errorf("send() error = %v, want a connection error", err)
+	}
+}
@@ -0,0 +1,227 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/database"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/metrics"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+)
+
+// Event types that channels can subscribe to
+const (
+	EventAlertFiring   = "alert.firing"
+	EventAlertResolved = "alert.resolved"
+	EventRunFailed     = "run.failed"
+	EventTest          = "test"
+)
+
+var subscribableEvents = map[string]bool{
+	EventAlertFiring:   true,
+	EventAlertResolved: true,
+	EventRunFailed:     true,
+}
+
+// TemplateData is passed to message templates. Alert is set for alert events and Run for run failures.
+type TemplateData struct {
+	Type  string
+	Time  time.Time
+	Alert *models.AlertEvent
+	Run   *models.RunEvent
+}
+
+type messageTemplates struct {
+	title string
+	body  string
+}
+
+var defaultTemplates = map[string]messageTemplates{
+	EventAlertFiring: {
+		title: `[BotB] Alert firing: {{.Alert.RuleName}}`,
+		body:  `{{.Alert.Message}}`,
+	},
+	EventAlertResolved: {
+		title: `[BotB] Alert resolved: {{.Alert.RuleName}}`,
+		body:  `{{.Alert.Message}}`,
+	},
+	EventRunFailed: {
+		title: `[BotB] {{.Run.ProviderName}} run failed`,
+		body: `The {{.Run.ProviderName}} speed test started at {{.Run.StartedAt.Format "2006-01-02 15:04:05 MST"}} failed after {{.Run.DurationMs}} ms.
+{{if .Run.ScheduleID}}Schedule: {{.Run.ScheduleID}}
+{{end}}Error: {{.Run.Error}}`,
+	},
+	EventTest: {
+		title: `[BotB] Test notification`,
+		body:  `This is a test notification from Battle of the Bandwidth.`,
+	},
+}
+
+// Dispatcher notifies channels of failed provider runs
+type Dispatcher struct{}
+
+func (d *Dispatcher) Name() string {
+	return "notifications"
+}
+
+func (d *Dispatcher) HandleResult(result models.SpeedTestResult) {}
+
+func (d *Dispatcher) HandleRun(event models.RunEvent) {
+	if event.Status != metrics.RunStatusFailure {
+		return
+	}
+	go Notify(EventRunFailed, TemplateData{Type: EventRunFailed, Time: time.Now(), Run: &event})
+}
+
+// HandleAlert notifies channels when an alert rule starts firing or resolves
+func HandleAlert(event models.AlertEvent) {
+	eventType := EventAlertResolved
+	if event.State == "firing" {
+		eventType = EventAlertFiring
+	}
+	go Notify(eventType, TemplateData{Type: eventType, Time: event.CreatedAt, Alert: &event})
+}
+
+// Notify sends an event to every active channel subscribed to its type
+func Notify(eventType string, data TemplateData) {
+	ctx := context.Background()
+
+	channels, err := fetchChannels(ctx, "WHERE is_active = true AND $1 = ANY(event_types)", eventType)
+	if err != nil {
+		log.Printf("Error loading notification channels for %s: %v", eventType, err)
+		return
+	}
+
+	for _, channel := range channels {
+		if err := send(ctx, channel, eventType, data); err != nil {
+			log.Printf("Error sending %s notification to %s: %v", eventType, channel.Name, err)
+		}
+	}
+}
+
+// send renders the channel's templates and delivers the message
+func send(ctx context.Context, channel models.NotificationChannel, eventType string, data TemplateData) error {
+	message, err := renderMessage(channel, eventType, data)
+	if err != nil {
+		return err
+	}
+
+	notifier, err := newNotifier(channel)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	return notifier.Send(ctx, message)
+}
+
+// renderMessage uses the channel's own templates when set, otherwise the defaults for the event type
+func renderMessage(channel models.NotificationChannel, eventType string, data TemplateData) (Message, error) {
+	defaults := defaultTemplates[eventType]
+
+	titleSource := defaults.title
+	if channel.TitleTemplate != "" {
+		titleSource = channel.TitleTemplate
+	}
+	bodySource := defaults.body
+	if channel.BodyTemplate != "" {
+		bodySource = channel.BodyTemplate
+	}
+
+	title, err := render("title", titleSource, data)
+	if err != nil {
+		return Message{}, err
+	}
+	body, err := render("body", bodySource, data)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{Title: strings.TrimSpace(title), Body: strings.TrimSpace(body)}, nil
+}
+
+// checkTemplates renders the channel's templates against sample data for every event type it
+// subscribes to. Alert events have no Run and run failures have no Alert, so a template written for
+// one kind fails on the other.
+func checkTemplates(channel models.NotificationChannel) error {
+	for _, eventType := range channel.EventTypes {
+		if _, err := renderMessage(channel, eventType, sampleData(eventType)); err != nil {
+			return fmt.Errorf("templates do not render for %s events: %w", eventType, err)
+		}
+	}
+	return nil
+}
+
+// sampleData returns example template data shaped like a real event of the given type
+func sampleData(eventType string) TemplateData {
+	now := time.Now()
+	data := TemplateData{Type: eventType, Time: now}
+
+	switch eventType {
+	case EventAlertFiring, EventAlertResolved:
+		state := "firing"
+		if eventType == EventAlertResolved {
+			state = "resolved"
+		}
+		data.Alert = &models.AlertEvent{
+			RuleName:  "Sample rule",
+			State:     state,
+			Value:     42,
+			Message:   "Sample alert message",
+			CreatedAt: now,
+		}
+	case EventRunFailed:
+		data.Run = &models.RunEvent{
+			ProviderName: "Sample provider",
+			Status:       metrics.RunStatusFailure,
+			Error:        "sample error",
+			StartedAt:    now,
+			DurationMs:   1000,
+		}
+	}
+	return data
+}
+
+func render(name, source string, data TemplateData) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=zero").Parse(source)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s template: %w", name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// fetchChannels is shared by the dispatcher and the API handlers
+func fetchChannels(ctx context.Context, condition string, args ...interface{}) ([]models.NotificationChannel, error) {
+	rows, err := database.DB.Query(ctx, `
+		SELECT id, name, channel_type, config, event_types, title_template, body_template, is_active, created_at, updated_at
+		FROM notification_channels `+condition, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch notification channels: %w", err)
+	}
+	defer rows.Close()
+
+	channels := []models.NotificationChannel{}
+	for rows.Next() {
+		var c models.NotificationChannel
+		if err := rows.Scan(&c.ID, &c.Name, &c.Type, &c.Config, &c.EventTypes, &c.TitleTemplate, &c.BodyTemplate,
+			&c.IsActive, &c.CreatedAt, &c.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan notification channel: %w", err)
+		}
+		channels = append(channels, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading notification channels: %w", err)
+	}
+	return channels, nil
+}
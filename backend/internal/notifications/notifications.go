@@ -0,0 +1,290 @@
+package notifications
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/database"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+)
+
+// channelPatch holds the fields of a PATCH request. Fields left out of the request are nil and keep
+// their current value.
+type channelPatch struct {
+	Name          *string                    `json:"name"`
+	Type          *string                    `json:"type"`
+	Config        *models.NotificationConfig `json:"config"`
+	EventTypes    *[]string                  `json:"event_types"`
+	TitleTemplate *string                    `json:"title_template"`
+	BodyTemplate  *string                    `json:"body_template"`
+	IsActive      *bool                      `json:"is_active"`
+}
+
+func ChannelsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		if r.PathValue("id") != "" {
+			getChannel(w, r)
+		} else {
+			listChannels(w, r)
+		}
+	case http.MethodPost:
+		createChannel(w, r)
+	case http.MethodPatch:
+		updateChannel(w, r)
+	case http.MethodDelete:
+		deleteChannel(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// TestChannelHandler sends a test message through a channel and reports whether it was accepted
+func TestChannelHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	channel, err := fetchChannel(r, r.PathValue("id"))
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Notification channel not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Templates saved before they were checked per event type may still fail for some events,
+	// so report that before sending. The test message itself always uses the default test
+	// template, so it shows whether the channel works.
+	if err := checkTemplates(channel); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.DefaultJsonResponse{Error: err.Error()})
+		return
+	}
+	channel.TitleTemplate = ""
+	channel.BodyTemplate = ""
+	if err := send(r.Context(), channel, EventTest, TemplateData{Type: EventTest, Time: time.Now()}); err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(models.DefaultJsonResponse{Error: err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(models.DefaultJsonResponse{Data: "Test notification sent"})
+}
+
+func listChannels(w http.ResponseWriter, r *http.Request) {
+	channels, err := fetchChannels(r.Context(), "ORDER BY created_at DESC")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for i := range channels {
+		hideSecrets(&channels[i])
+	}
+	json.NewEncoder(w).Encode(channels)
+}
+
+func getChannel(w http.ResponseWriter, r *http.Request) {
+	channel, err := fetchChannel(r, r.PathValue("id"))
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Notification channel not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	hideSecrets(&channel)
+	json.NewEncoder(w).Encode(channel)
+}
+
+func fetchChannel(r *http.Request, id string) (models.NotificationChannel, error) {
+	channels, err := fetchChannels(r.Context(), "WHERE id = $1", id)
+	if err != nil {
+		return models.NotificationChannel{}, err
+	}
+	if len(channels) == 0 {
+		return models.NotificationChannel{}, pgx.ErrNoRows
+	}
+	return channels[0], nil
+}
+
+func createChannel(w http.ResponseWriter, r *http.Request) {
+	// Channels are active unless the request says otherwise, like the column default
+	c := models.NotificationChannel{IsActive: true}
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateChannel(c); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := database.DB.QueryRow(r.Context(), `
+		INSERT INTO notification_channels (name, channel_type, config, event_types, title_template, body_template, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at, updated_at
+	`, c.Name, c.Type, c.Config, c.EventTypes, c.TitleTemplate, c.BodyTemplate, c.IsActive).
+		Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	hideSecrets(&c)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c)
+}
+
+func updateChannel(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		http.Error(w, "ID is required", http.StatusBadRequest)
+		return
+	}
+
+	var patch channelPatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var c models.NotificationChannel
+	err = tx.QueryRow(ctx, `
+		SELECT id, name, channel_type, config, event_types, title_template, body_template, is_active, created_at
+		FROM notification_channels
+		WHERE id = $1
+		FOR UPDATE
+	`, id).Scan(&c.ID, &c.Name, &c.Type, &c.Config, &c.EventTypes, &c.TitleTemplate, &c.BodyTemplate, &c.IsActive, &c.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Notification channel not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	patch.apply(&c)
+	if err := validateChannel(c); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = tx.QueryRow(ctx, `
+		UPDATE notification_channels
+		SET name = $1, channel_type = $2, config = $3, event_types = $4, title_template = $5, body_template = $6,
+		    is_active = $7, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $8
+		RETURNING updated_at
+	`, c.Name, c.Type, c.Config, c.EventTypes, c.TitleTemplate, c.BodyTemplate, c.IsActive, id).Scan(&c.UpdatedAt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	hideSecrets(&c)
+	json.NewEncoder(w).Encode(c)
+}
+
+func (p channelPatch) apply(c *models.NotificationChannel) {
+	if p.Name != nil {
+		c.Name = *p.Name
+	}
+	if p.Type != nil {
+		c.Type = *p.Type
+	}
+	if p.Config != nil {
+		config := *p.Config
+		// An empty token or password keeps the current one
+		if config.Token == "" {
+			config.Token = c.Config.Token
+		}
+		if config.Password == "" {
+			config.Password = c.Config.Password
+		}
+		c.Config = config
+	}
+	if p.EventTypes != nil {
+		c.EventTypes = *p.EventTypes
+	}
+	if p.TitleTemplate != nil {
+		c.TitleTemplate = *p.TitleTemplate
+	}
+	if p.BodyTemplate != nil {
+		c.BodyTemplate = *p.BodyTemplate
+	}
+	if p.IsActive != nil {
+		c.IsActive = *p.IsActive
+	}
+}
+
+func deleteChannel(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		http.Error(w, "ID is required", http.StatusBadRequest)
+		return
+	}
+
+	result, err := database.DB.Exec(r.Context(), "DELETE FROM notification_channels WHERE id = $1", id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if result.RowsAffected() == 0 {
+		http.Error(w, "Notification channel not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func validateChannel(c models.NotificationChannel) error {
+	if c.Name == "" {
+		return errors.New("name is required")
+	}
+
+	if err := validateConfig(c.Type, c.Config); err != nil {
+		return err
+	}
+
+	if len(c.EventTypes) == 0 {
+		return errors.New("at least one event type is required")
+	}
+	for _, eventType := range c.EventTypes {
+		if !subscribableEvents[eventType] {
+			return fmt.Errorf("unknown event type: %q", eventType)
+		}
+	}
+
+	return checkTemplates(c)
+}
+
+func hideSecrets(c *models.NotificationChannel) {
+	c.Config.Token = ""
+	c.Config.Password = ""
+}
@@ -0,0 +1,328 @@
+package plans
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/database"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+)
+
+const planColumns = `
+		id, name, download_mbps, upload_mbps, latency_sla_ms, effective_from, effective_to,
+		schedule_ids::text[], created_at, updated_at`
+
+// planPatch holds the fields of a PATCH request. Fields left out of the request keep their current
+// value; latency_sla_ms and effective_to can be cleared by sending null.
+type planPatch struct {
+	Name          *string             `json:"name"`
+	DownloadMbps  *float64            `json:"download_mbps"`
+	UploadMbps    *float64            `json:"upload_mbps"`
+	LatencySLAMs  nullable[float64]   `json:"latency_sla_ms"`
+	EffectiveFrom *time.Time          `json:"effective_from"`
+	EffectiveTo   nullable[time.Time] `json:"effective_to"`
+	ScheduleIDs   *[]string           `json:"schedule_ids"`
+}
+
+// nullable is a patch field that tells a null value apart from a field left out of the request
+type nullable[T any] struct {
+	set   bool
+	value *T
+}
+
+func (n *nullable[T]) UnmarshalJSON(data []byte) error {
+	n.set = true
+	return json.Unmarshal(data, &n.value)
+}
+
+// PlanJoin attaches the plan in effect at each result's timestamp to a query on speedtest_results.
+// A plan linked to the result's schedule wins over a plan without schedules, and among those the
+// most recently started plan wins.
+const PlanJoin = `
+        LEFT JOIN LATERAL (
+            SELECT p.id, p.download_mbps, p.upload_mbps, p.latency_sla_ms
+            FROM isp_plans p
+            WHERE p.effective_from <= speedtest_results.timestamp
+              AND (p.effective_to IS NULL OR speedtest_results.timestamp < p.effective_to)
+              AND (cardinality(p.schedule_ids) = 0 OR speedtest_results.schedule_id = ANY(p.schedule_ids))
+            ORDER BY cardinality(p.schedule_ids) = 0, p.effective_from DESC
+            LIMIT 1
+        ) plan ON true`
+
+// FixedPlanJoin attaches a single plan, given as the query parameter $n, to every result
+// of the schedules it covers. It makes the same columns available as PlanJoin.
+const FixedPlanJoin = `
+        JOIN isp_plans plan ON plan.id = $%d
+            AND (cardinality(plan.schedule_ids) = 0 OR speedtest_results.schedule_id = ANY(plan.schedule_ids))`
+
+// PlanColumns are the columns made available by PlanJoin and FixedPlanJoin, read by PlanValues
+const PlanColumns = `,
+            plan.id, speedtest_results.download / NULLIF(plan.download_mbps, 0) * 100,
+            speedtest_results.upload / NULLIF(plan.upload_mbps, 0) * 100, plan.latency_sla_ms`
+
+// PlanValues receives PlanColumns. Pass Targets to Scan, then Apply the values to the scanned result.
+type PlanValues struct {
+	id              *string
+	downloadPercent *float64
+	uploadPercent   *float64
+	latencySLA      *float64
+}
+
+func (v *PlanValues) Targets() []interface{} {
+	return []interface{}{&v.id, &v.downloadPercent, &v.uploadPercent, &v.latencySLA}
+}
+
+func (v *PlanValues) Apply(result *models.SpeedTestResult) {
+	if v.id == nil {
+		return
+	}
+
+	result.PlanID = *v.id
+	result.DownloadPercent = v.downloadPercent
+	result.UploadPercent = v.uploadPercent
+	if v.latencySLA != nil {
+		met := result.Ping <= *v.latencySLA
+		result.LatencySLAMet = &met
+	}
+}
+
+func PlansHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		if r.PathValue("id") != "" {
+			getPlan(w, r)
+		} else {
+			listPlans(w, r)
+		}
+	case http.MethodPost:
+		createPlan(w, r)
+	case http.MethodPatch:
+		updatePlan(w, r)
+	case http.MethodDelete:
+		deletePlan(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func listPlans(w http.ResponseWriter, r *http.Request) {
+	plans, err := fetchPlans(r.Context(), "ORDER BY effective_from DESC")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(plans)
+}
+
+func getPlan(w http.ResponseWriter, r *http.Request) {
+	plan, err := GetPlan(r.Context(), r.PathValue("id"))
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Plan not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(plan)
+}
+
+func fetchPlans(ctx context.Context, condition string, args ...interface{}) ([]models.ISPPlan, error) {
+	rows, err := database.DB.Query(ctx, "SELECT"+planColumns+" FROM isp_plans "+condition, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch plans: %w", err)
+	}
+	defer rows.Close()
+
+	plans := []models.ISPPlan{}
+	for rows.Next() {
+		p, err := scanPlan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan plan: %w", err)
+		}
+		plans = append(plans, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading plans: %w", err)
+	}
+	return plans, nil
+}
+
+// scanPlan reads a plan selected with planColumns
+func scanPlan(row pgx.Row) (models.ISPPlan, error) {
+	var p models.ISPPlan
+	err := row.Scan(&p.ID, &p.Name, &p.DownloadMbps, &p.UploadMbps, &p.LatencySLAMs, &p.EffectiveFrom,
+		&p.EffectiveTo, &p.ScheduleIDs, &p.CreatedAt, &p.UpdatedAt)
+	return p, err
+}
+
+// GetPlan returns a single plan, or pgx.ErrNoRows when it does not exist
+func GetPlan(ctx context.Context, id string) (models.ISPPlan, error) {
+	plans, err := fetchPlans(ctx, "WHERE id = $1", id)
+	if err != nil {
+		return models.ISPPlan{}, err
+	}
+	if len(plans) == 0 {
+		return models.ISPPlan{}, pgx.ErrNoRows
+	}
+	return plans[0], nil
+}
+
+func createPlan(w http.ResponseWriter, r *http.Request) {
+	var p models.ISPPlan
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := validatePlan(&p); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := database.DB.QueryRow(r.Context(), `
+		INSERT INTO isp_plans (name, download_mbps, upload_mbps, latency_sla_ms, effective_from, effective_to, schedule_ids)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at, updated_at
+	`, p.Name, p.DownloadMbps, p.UploadMbps, p.LatencySLAMs, p.EffectiveFrom, p.EffectiveTo, p.ScheduleIDs).
+		Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(p)
+}
+
+func updatePlan(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		http.Error(w, "ID is required", http.StatusBadRequest)
+		return
+	}
+
+	var patch planPatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	p, err := scanPlan(tx.QueryRow(ctx, "SELECT"+planColumns+" FROM isp_plans WHERE id = $1 FOR UPDATE", id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Plan not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	patch.apply(&p)
+	if err := validatePlan(&p); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = tx.QueryRow(ctx, `
+		UPDATE isp_plans
+		SET name = $1, download_mbps = $2, upload_mbps = $3, latency_sla_ms = $4, effective_from = $5,
+		    effective_to = $6, schedule_ids = $7, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $8
+		RETURNING updated_at
+	`, p.Name, p.DownloadMbps, p.UploadMbps, p.LatencySLAMs, p.EffectiveFrom, p.EffectiveTo, p.ScheduleIDs, id).
+		Scan(&p.UpdatedAt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(p)
+}
+
+func (patch planPatch) apply(p *models.ISPPlan) {
+	if patch.Name != nil {
+		p.Name = *patch.Name
+	}
+	if patch.DownloadMbps != nil {
+		p.DownloadMbps = *patch.DownloadMbps
+	}
+	if patch.UploadMbps != nil {
+		p.UploadMbps = *patch.UploadMbps
+	}
+	if patch.LatencySLAMs.set {
+		p.LatencySLAMs = patch.LatencySLAMs.value
+	}
+	if patch.EffectiveFrom != nil {
+		p.EffectiveFrom = *patch.EffectiveFrom
+	}
+	if patch.EffectiveTo.set {
+		p.EffectiveTo = patch.EffectiveTo.value
+	}
+	if patch.ScheduleIDs != nil {
+		p.ScheduleIDs = *patch.ScheduleIDs
+	}
+}
+
+func deletePlan(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		http.Error(w, "ID is required", http.StatusBadRequest)
+		return
+	}
+
+	result, err := database.DB.Exec(r.Context(), "DELETE FROM isp_plans WHERE id = $1", id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if result.RowsAffected() == 0 {
+		http.Error(w, "Plan not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func validatePlan(p *models.ISPPlan) error {
+	if p.Name == "" {
+		return errors.New("name is required")
+	}
+	if p.DownloadMbps <= 0 || p.UploadMbps <= 0 {
+		return errors.New("download_mbps and upload_mbps must be positive")
+	}
+	if p.LatencySLAMs != nil && *p.LatencySLAMs <= 0 {
+		return errors.New("latency_sla_ms must be positive when set")
+	}
+	if p.EffectiveFrom.IsZero() {
+		return errors.New("effective_from is required")
+	}
+	if p.EffectiveTo != nil && !p.EffectiveTo.After(p.EffectiveFrom) {
+		return errors.New("effective_to must be after effective_from")
+	}
+	if p.ScheduleIDs == nil {
+		p.ScheduleIDs = []string{}
+	}
+	return nil
+}
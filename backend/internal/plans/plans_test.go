@@ -0,0 +1,147 @@
+package plans
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+)
+
+func TestPlanPatch(t *testing.T) {
+	sla := 30.0
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	current := func() models.ISPPlan {
+		return models.ISPPlan{
+			Name:          "Fibre 500",
+			DownloadMbps:  500,
+			UploadMbps:    100,
+			LatencySLAMs:  &sla,
+			EffectiveFrom: from,
+			EffectiveTo:   &to,
+			ScheduleIDs:   []string{"s1"},
+		}
+	}
+
+	tests := []struct {
+		name  string
+		body  string
+		check func(t *testing.T, p models.ISPPlan)
+	}{
+		{
+			name: "empty patch keeps everything",
+			body: `{}`,
+			check: func(t *testing.T, p models.ISPPlan) {
+				if p.Name != "Fibre 500" || p.LatencySLAMs == nil || p.EffectiveTo == nil || !slices.Equal(p.ScheduleIDs, []string{"s1"}) {
+					t.Errorf("plan = %+v, want it unchanged", p)
+				}
+			},
+		},
+		{
+			name: "only sent fields change",
+			body: `{"download_mbps": 1000, "schedule_ids": []}`,
+			check: func(t *testing.T, p models.ISPPlan) {
+				if p.DownloadMbps != 1000 || p.UploadMbps != 100 || len(p.ScheduleIDs) != 0 || p.LatencySLAMs == nil {
+					t.Errorf("plan = %+v", p)
+				}
+			},
+		},
+		{
+			name: "null clears optional fields",
+			body: `{"latency_sla_ms": null, "effective_to": null}`,
+			check: func(t *testing.T, p models.ISPPlan) {
+				if p.LatencySLAMs != nil || p.EffectiveTo != nil {
+					t.Errorf("latency_sla_ms = %v, effective_to = %v, want both cleared", p.LatencySLAMs, p.EffectiveTo)
+				}
+			},
+		},
+		{
+			name: "values replace optional fields",
+			body: `{"latency_sla_ms": 15, "effective_to": "2024-07-01T00:00:00Z"}`,
+			check: func(t *testing.T, p models.ISPPlan) {
+				if p.LatencySLAMs == nil || *p.LatencySLAMs != 15 || p.EffectiveTo == nil || !p.EffectiveTo.Equal(time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)) {
+					t.Errorf("latency_sla_ms = %v, effective_to = %v", p.LatencySLAMs, p.EffectiveTo)
+				}
+				if sla != 30 {
+					t.Error("patch changed the SLA through the original pointer")
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var patch planPatch
+			if err := json.Unmarshal([]byte(tt.body), &patch); err != nil {
+				t.Fatalf("invalid patch: %v", err)
+			}
+			p := current()
+			patch.apply(&p)
+			tt.check(t, p)
+		})
+	}
+}
+
+func TestValidatePlan(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := from.Add(-time.Hour)
+	zero := 0.0
+
+	tests := []struct {
+		name    string
+		change  func(p *models.ISPPlan)
+		wantErr string
+	}{
+		{name: "valid", change: func(*models.ISPPlan) {}},
+		{name: "missing name", change: func(p *models.ISPPlan) { p.Name = "" }, wantErr: "name is required"},
+		{name: "zero upload", change: func(p *models.ISPPlan) { p.UploadMbps = 0 }, wantErr: "must be positive"},
+		{name: "zero SLA", change: func(p *models.ISPPlan) { p.LatencySLAMs = &zero }, wantErr: "latency_sla_ms must be positive"},
+		{name: "missing start", change: func(p *models.ISPPlan) { p.EffectiveFrom = time.Time{} }, wantErr: "effective_from is required"},
+		{name: "ends before it starts", change: func(p *models.ISPPlan) { p.EffectiveTo = &before }, wantErr: "effective_to must be after"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := models.ISPPlan{Name: "Fibre", DownloadMbps: 500, UploadMbps: 100, EffectiveFrom: from}
+			tt.change(&p)
+
+			err := validatePlan(&p)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validatePlan() error = %v", err)
+				}
+				// Plans without schedules are stored with an empty list, not NULL
+				if p.ScheduleIDs == nil {
+					t.Error("ScheduleIDs left nil")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validatePlan() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPlanValuesApply(t *testing.T) {
+	id, percent, sla := "plan-1", 95.5, 20.0
+	values := PlanValues{id: &id, downloadPercent: &percent, latencySLA: &sla}
+
+	result := models.SpeedTestResult{Ping: 25}
+	values.Apply(&result)
+	if result.PlanID != "plan-1" || result.DownloadPercent == nil || *result.DownloadPercent != 95.5 ||
+		result.UploadPercent != nil || result.LatencySLAMet == nil || *result.LatencySLAMet {
+		t.Errorf("result = %+v, want plan-1 with the latency SLA missed", result)
+	}
+
+	// Results without a plan are left alone
+	var none PlanValues
+	result = models.SpeedTestResult{Ping: 10}
+	none.Apply(&result)
+	if result.PlanID != "" || result.LatencySLAMet != nil {
+		t.Errorf("result = %+v, want no plan", result)
+	}
+}
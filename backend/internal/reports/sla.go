@@ -0,0 +1,319 @@
+package reports
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/database"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/filters"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/plans"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/retention"
+)
+
+const (
+	defaultThresholdPercent = 80
+	defaultReportDays       = 30
+	worstHoursCount         = 5
+)
+
+//go:embed sla.html
+var slaTemplateSource string
+
+var slaTemplate = template.Must(template.New("sla").Funcs(template.FuncMap{
+	"percent": func(value float64) string { return strconv.FormatFloat(value, 'f', 1, 64) + "%" },
+}).Parse(slaTemplateSource))
+
+// slaSample is a single result judged against the plan in effect when it ran
+type slaSample struct {
+	timestamp       time.Time
+	downloadPercent float64
+	uploadPercent   float64
+	compliant       bool
+}
+
+// SLAReportHandler reports how often results reached threshold% of the advertised plan speeds.
+// Query parameters: from, to (RFC3339 or YYYY-MM-DD, default the last 30 days) or range (24h, 7d,
+// 30d or mtd), plan (judge every result against this plan instead of the plan in effect), threshold
+// (default 80), tz (default the default_timezone setting), and format=html for a printable view.
+func SLAReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+
+	location, err := filters.ParseLocation(query.Get("tz"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	to := time.Now().In(location)
+	if v := query.Get("to"); v != "" {
+		parsed, err := parseReportTime(v, location, true)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid to: %v", err), http.StatusBadRequest)
+			return
+		}
+		to = parsed
+	}
+	from := to.AddDate(0, 0, -defaultReportDays)
+	if v := query.Get("from"); v != "" {
+		parsed, err := parseReportTime(v, location, false)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid from: %v", err), http.StatusBadRequest)
+			return
+		}
+		from = parsed
+	}
+	if v := query.Get("range"); v != "" {
+		if query.Get("from") != "" || query.Get("to") != "" {
+			http.Error(w, "range can't be combined with from or to", http.StatusBadRequest)
+			return
+		}
+		from, err = filters.RangeStart(v, to)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if !from.Before(to) {
+		http.Error(w, "from must be before to", http.StatusBadRequest)
+		return
+	}
+
+	threshold := float64(defaultThresholdPercent)
+	if v := query.Get("threshold"); v != "" {
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil || parsed <= 0 {
+			http.Error(w, fmt.Sprintf("invalid threshold: %q", v), http.StatusBadRequest)
+			return
+		}
+		threshold = parsed
+	}
+
+	report := models.SLAReport{
+		From:             from.In(location),
+		To:               to.In(location),
+		Timezone:         location.String(),
+		ThresholdPercent: threshold,
+	}
+
+	if planID := query.Get("plan"); planID != "" {
+		plan, err := plans.GetPlan(r.Context(), planID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Plan not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		report.PlanID = plan.ID
+		report.PlanName = plan.Name
+	}
+
+	samples, err := fetchSLASamples(r.Context(), from, to, report.PlanID, threshold)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	summarizeSLA(&report, samples, location)
+
+	// Compliance is judged per result, which the rollups don't have
+	report.RawDataTruncatedBefore, err = retention.TruncatedBefore(r.Context(), filters.ResultFilter{
+		StartDate: from.Format(time.RFC3339),
+		EndDate:   to.Format(time.RFC3339),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if report.RawDataTruncatedBefore != nil {
+		truncatedBefore := report.RawDataTruncatedBefore.In(location)
+		report.RawDataTruncatedBefore = &truncatedBefore
+	}
+
+	if query.Get("format") == "html" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := slaTemplate.Execute(w, report); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// parseReportTime accepts RFC3339 or a plain date. A plain "to" date includes the whole day.
+func parseReportTime(value string, location *time.Location, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", value, location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected RFC3339 or YYYY-MM-DD, got %q", value)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// fetchSLASamples loads the results in [from, to) that have a plan to be judged against
+func fetchSLASamples(ctx context.Context, from, to time.Time, planID string, threshold float64) ([]slaSample, error) {
+	args := []interface{}{from, to}
+	join := plans.PlanJoin
+	if planID != "" {
+		args = append(args, planID)
+		join = fmt.Sprintf(plans.FixedPlanJoin, len(args))
+	}
+
+	rows, err := database.DB.Query(ctx, `
+        SELECT speedtest_results.timestamp, speedtest_results.ping`+plans.PlanColumns+`
+        FROM speedtest_results`+join+`
+        WHERE speedtest_results.timestamp >= $1 AND speedtest_results.timestamp < $2
+          AND plan.id IS NOT NULL
+        ORDER BY speedtest_results.timestamp`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch results: %w", err)
+	}
+	defer rows.Close()
+
+	var samples []slaSample
+	for rows.Next() {
+		var timestamp time.Time
+		var result models.SpeedTestResult
+		var plan plans.PlanValues
+		if err := rows.Scan(append([]interface{}{&timestamp, &result.Ping}, plan.Targets()...)...); err != nil {
+			return nil, fmt.Errorf("failed to scan result: %w", err)
+		}
+		plan.Apply(&result)
+
+		sample := slaSample{timestamp: timestamp}
+		if result.DownloadPercent != nil {
+			sample.downloadPercent = *result.DownloadPercent
+		}
+		if result.UploadPercent != nil {
+			sample.uploadPercent = *result.UploadPercent
+		}
+		sample.compliant = sample.downloadPercent >= threshold && sample.uploadPercent >= threshold &&
+			(result.LatencySLAMet == nil || *result.LatencySLAMet)
+		samples = append(samples, sample)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading results: %w", err)
+	}
+	return samples, nil
+}
+
+// summarizeSLA fills in the totals, hourly, daily and degraded run sections of the report.
+// Samples must be ordered by timestamp.
+func summarizeSLA(report *models.SLAReport, samples []slaSample, location *time.Location) {
+	report.WorstHours = []models.SLAHour{}
+	report.Daily = []models.SLADailySummary{}
+	if len(samples) == 0 {
+		return
+	}
+
+	var hours [24]models.SLAHour
+	dayIndex := make(map[string]int)
+	var downloadSum, uploadSum float64
+	var run, longest *models.SLADegradedRun
+
+	for _, sample := range samples {
+		local := sample.timestamp.In(location)
+
+		report.Total++
+		downloadSum += sample.downloadPercent
+		uploadSum += sample.uploadPercent
+
+		hour := &hours[local.Hour()]
+		hour.Total++
+		hour.AvgDownloadPct += sample.downloadPercent
+
+		date := local.Format("2006-01-02")
+		i, ok := dayIndex[date]
+		if !ok {
+			i = len(report.Daily)
+			dayIndex[date] = i
+			report.Daily = append(report.Daily, models.SLADailySummary{Date: date})
+		}
+		day := &report.Daily[i]
+		day.Total++
+		day.AvgDownloadPct += sample.downloadPercent
+		day.AvgUploadPct += sample.uploadPercent
+
+		if sample.compliant {
+			report.Compliant++
+			hour.Compliant++
+			day.Compliant++
+			run = nil
+			continue
+		}
+
+		if run == nil {
+			run = &models.SLADegradedRun{Start: sample.timestamp}
+		}
+		run.End = sample.timestamp
+		run.Count++
+		if longest == nil || run.Count > longest.Count {
+			copied := *run
+			longest = &copied
+		}
+	}
+
+	report.CompliancePct = percentOf(report.Compliant, report.Total)
+	report.AvgDownloadPct = downloadSum / float64(report.Total)
+	report.AvgUploadPct = uploadSum / float64(report.Total)
+	report.LongestDegraded = longest
+
+	for i := range report.Daily {
+		day := &report.Daily[i]
+		day.CompliancePct = percentOf(day.Compliant, day.Total)
+		day.AvgDownloadPct /= float64(day.Total)
+		day.AvgUploadPct /= float64(day.Total)
+	}
+
+	var measured []models.SLAHour
+	for h := range hours {
+		if hours[h].Total == 0 {
+			continue
+		}
+		hour := hours[h]
+		hour.Hour = h
+		hour.CompliancePct = percentOf(hour.Compliant, hour.Total)
+		hour.AvgDownloadPct /= float64(hour.Total)
+		measured = append(measured, hour)
+	}
+	sort.SliceStable(measured, func(i, j int) bool {
+		if measured[i].CompliancePct != measured[j].CompliancePct {
+			return measured[i].CompliancePct < measured[j].CompliancePct
+		}
+		return measured[i].AvgDownloadPct < measured[j].AvgDownloadPct
+	})
+	if len(measured) > worstHoursCount {
+		measured = measured[:worstHoursCount]
+	}
+	report.WorstHours = measured
+}
+
+func percentOf(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total) * 100
+}
@@ -0,0 +1,78 @@
+package reports
+
+import (
+	"testing"
+	"time"
+
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+)
+
+func TestParseReportTime(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+
+	tests := []struct {
+		value    string
+		endOfDay bool
+		want     time.Time
+	}{
+		{value: "2024-05-01", want: time.Date(2024, 5, 1, 0, 0, 0, 0, chicago)},
+		{value: "2024-05-01", endOfDay: true, want: time.Date(2024, 5, 2, 0, 0, 0, 0, chicago)},
+		{value: "2024-05-01T12:00:00Z", endOfDay: true, want: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		got, err := parseReportTime(tt.value, chicago, tt.endOfDay)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseReportTime(%q, %v) = %v, %v, want %v", tt.value, tt.endOfDay, got, err, tt.want)
+		}
+	}
+
+	if _, err := parseReportTime("May 1st", chicago, false); err == nil {
+		t.Error("parseReportTime(\"May 1st\") error = nil")
+	}
+}
+
+func TestSummarizeSLA(t *testing.T) {
+	start := time.Date(2024, 5, 1, 22, 0, 0, 0, time.UTC)
+	sample := func(hours int, download float64, compliant bool) slaSample {
+		return slaSample{timestamp: start.Add(time.Duration(hours) * time.Hour), downloadPercent: download, uploadPercent: 100, compliant: compliant}
+	}
+	samples := []slaSample{
+		sample(0, 90, true),
+		sample(1, 50, false),
+		sample(2, 40, false),
+		sample(3, 95, true),
+		sample(24, 30, false),
+	}
+
+	var report models.SLAReport
+	summarizeSLA(&report, samples, time.UTC)
+
+	if report.Total != 5 || report.Compliant != 2 || report.CompliancePct != 40 || report.AvgDownloadPct != 61 {
+		t.Errorf("totals = %d/%d (%v%%), avg download %v", report.Compliant, report.Total, report.CompliancePct, report.AvgDownloadPct)
+	}
+
+	// Results after midnight in the report time zone start a new day
+	if len(report.Daily) != 2 || report.Daily[0].Date != "2024-05-01" || report.Daily[0].Total != 2 ||
+		report.Daily[1].Date != "2024-05-02" || report.Daily[1].Total != 3 || report.Daily[1].Compliant != 1 {
+		t.Errorf("daily = %+v", report.Daily)
+	}
+
+	if run := report.LongestDegraded; run == nil || run.Count != 2 || !run.Start.Equal(samples[1].timestamp) || !run.End.Equal(samples[2].timestamp) {
+		t.Errorf("longest degraded run = %+v, want the 2 results after the first", run)
+	}
+
+	// Hours 23 and 0 only missed the threshold; the lower download ranks first
+	if len(report.WorstHours) != 4 || report.WorstHours[0].Hour != 0 || report.WorstHours[1].Hour != 23 {
+		t.Errorf("worst hours = %+v", report.WorstHours)
+	}
+
+	var empty models.SLAReport
+	summarizeSLA(&empty, nil, time.UTC)
+	if empty.WorstHours == nil || empty.Daily == nil || empty.LongestDegraded != nil {
+		t.Errorf("empty report = %+v, want empty lists", empty)
+	}
+}
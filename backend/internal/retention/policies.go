@@ -0,0 +1,139 @@
+package retention
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/database"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+)
+
+// Retention policy types
+const (
+	PolicySchedule    = "schedule"
+	PolicyUnscheduled = "unscheduled"
+)
+
+// policy selects the results one retention rule deletes
+type policy struct {
+	name         string
+	scheduleID   string
+	scheduleName string
+	maxAgeDays   int
+	cutoff       time.Time
+	// condition is a WHERE clause over speedtest_results using args
+	condition string
+	args      []interface{}
+}
+
+func (p policy) describe() string {
+	if p.name == PolicySchedule {
+		return fmt.Sprintf("schedule %s", p.scheduleName)
+	}
+	return p.name
+}
+
+// fetchPolicies lists the age limits of the schedules that set max_age_days, followed by the global
+// policy for results without a schedule, which includes the results of deleted schedules
+func fetchPolicies(ctx context.Context, c Config, now time.Time) ([]policy, error) {
+	rows, err := database.DB.Query(ctx, `
+		SELECT id, name, max_age_days FROM schedules WHERE max_age_days > 0 ORDER BY name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch schedule retention: %w", err)
+	}
+	defer rows.Close()
+
+	var policies []policy
+	for rows.Next() {
+		p := policy{name: PolicySchedule}
+		if err := rows.Scan(&p.scheduleID, &p.scheduleName, &p.maxAgeDays); err != nil {
+			return nil, fmt.Errorf("failed to scan schedule retention: %w", err)
+		}
+		p.cutoff = now.AddDate(0, 0, -p.maxAgeDays)
+		p.condition = "schedule_id = $1 AND timestamp < $2"
+		p.args = []interface{}{p.scheduleID, p.cutoff}
+		policies = append(policies, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading schedule retention: %w", err)
+	}
+
+	if c.MaxAgeDays > 0 {
+		cutoff := now.AddDate(0, 0, -c.MaxAgeDays)
+		policies = append(policies, policy{
+			name:       PolicyUnscheduled,
+			maxAgeDays: c.MaxAgeDays,
+			cutoff:     cutoff,
+			condition:  "schedule_id IS NULL AND timestamp < $1",
+			args:       []interface{}{cutoff},
+		})
+	}
+	return policies, nil
+}
+
+// PreviewHandler reports how many results the next run of the retention job would delete or roll
+// up, without changing anything
+func PreviewHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	preview, err := Preview(r.Context(), activeConfig)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to preview retention: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": preview,
+	})
+}
+
+// Preview counts the results every retention policy currently selects
+func Preview(ctx context.Context, c Config) (models.RetentionPreview, error) {
+	preview := models.RetentionPreview{
+		MaxAgeDays: c.MaxAgeDays,
+		Policies:   []models.RetentionPolicyPreview{},
+	}
+
+	policies, err := fetchPolicies(ctx, c, time.Now())
+	if err != nil {
+		return preview, err
+	}
+	for _, p := range policies {
+		entry := models.RetentionPolicyPreview{
+			Policy:       p.name,
+			ScheduleID:   p.scheduleID,
+			ScheduleName: p.scheduleName,
+			MaxAgeDays:   p.maxAgeDays,
+			Cutoff:       p.cutoff,
+		}
+		err := database.DB.QueryRow(ctx, "SELECT COUNT(*), MIN(timestamp) FROM speedtest_results WHERE "+p.condition, p.args...).
+			Scan(&entry.Results, &entry.Oldest)
+		if err != nil {
+			return preview, fmt.Errorf("failed to preview %s retention policy: %w", p.name, err)
+		}
+		preview.Policies = append(preview.Policies, entry)
+		preview.TotalResults += entry.Results
+	}
+
+	if c.RawDays > 0 {
+		rollup := models.RetentionRollupPreview{RawDays: c.RawDays}
+		if err := database.DB.QueryRow(ctx, rollupCutoffQuery, c.RawDays).Scan(&rollup.Cutoff); err != nil {
+			return preview, fmt.Errorf("failed to compute cutoff: %w", err)
+		}
+		err := database.DB.QueryRow(ctx, "SELECT COUNT(*) FROM speedtest_results WHERE timestamp < $1", rollup.Cutoff).
+			Scan(&rollup.Results)
+		if err != nil {
+			return preview, fmt.Errorf("failed to preview rollups: %w", err)
+		}
+		preview.Rollup = &rollup
+	}
+	return preview, nil
+}
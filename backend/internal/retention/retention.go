@@ -0,0 +1,225 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/database"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/filters"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/metrics"
+)
+
+// Rollup tables, keyed by the date_trunc field their buckets are truncated to
+var RollupTables = map[string]string{
+	"hour": "result_rollups_hourly",
+	"day":  "result_rollups_daily",
+}
+
+// TruncatedBefore returns the end of the rolled-up results matching filter. Before it only the
+// rollups of those results remain, so endpoints that need every individual result only see the raw
+// results after it. It returns nil when no rollup matches. Filters on columns the rollups lack are
+// ignored, so the answer errs on the side of reporting truncation.
+func TruncatedBefore(ctx context.Context, filter filters.ResultFilter) (*time.Time, error) {
+	rollupFilter := filters.ResultFilter{
+		StartDate:   filter.StartDate,
+		EndDate:     filter.EndDate,
+		ServerNames: filter.ServerNames,
+		Providers:   filter.Providers,
+		ScheduleIDs: filter.ScheduleIDs,
+	}
+	where, args := rollupFilter.Where(nil)
+
+	var truncatedBefore *time.Time
+	err := database.DB.QueryRow(ctx, `
+        SELECT MAX(timestamp) + interval '1 hour'
+        FROM `+RollupTables["hour"]+where, args...).Scan(&truncatedBefore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find rolled up results: %w", err)
+	}
+	return truncatedBefore, nil
+}
+
+// rollupCutoffQuery aligns the rollup cutoff to the start of a day, so a daily bucket is always
+// rolled up in one go
+const rollupCutoffQuery = "SELECT date_trunc('day', now() - make_interval(days => $1))"
+
+// Config holds the settings of the retention job
+type Config struct {
+	// RawDays is the number of days raw results are kept before they are rolled up; 0 disables rollups
+	RawDays int `yaml:"raw_days" toml:"raw_days"`
+	// MaxAgeDays is the age after which results without a schedule are deleted; 0 keeps them forever
+	MaxAgeDays int           `yaml:"max_age_days" toml:"max_age_days"`
+	Interval   time.Duration `yaml:"interval" toml:"interval"`
+}
+
+// activeConfig is the configuration the job was started with, which the preview endpoint also reads
+var activeConfig Config
+
+// DefaultConfig keeps results forever and runs the job every hour
+func DefaultConfig() Config {
+	return Config{Interval: time.Hour}
+}
+
+// Validate checks the job configuration
+func (c Config) Validate() error {
+	if c.RawDays < 0 {
+		return fmt.Errorf("invalid raw days: %d", c.RawDays)
+	}
+	if c.MaxAgeDays < 0 {
+		return fmt.Errorf("invalid max age days: %d", c.MaxAgeDays)
+	}
+	if c.Interval <= 0 {
+		return fmt.Errorf("invalid interval: %s", c.Interval)
+	}
+	return nil
+}
+
+// Start runs the retention job once and then every c.Interval
+func Start(c Config) {
+	activeConfig = c
+	go func() {
+		for {
+			if err := Run(context.Background(), c); err != nil {
+				log.Printf("Error applying result retention: %v", err)
+			}
+			time.Sleep(c.Interval)
+		}
+	}()
+}
+
+// Run deletes the results that are older than their retention policy allows, then rolls up the
+// remaining raw results older than c.RawDays into the hourly and daily rollup tables and deletes them
+func Run(ctx context.Context, c Config) error {
+	policies, err := fetchPolicies(ctx, c, time.Now())
+	if err != nil {
+		return err
+	}
+	// The latest result of a series may be among the deleted ones
+	defer metrics.ReloadLatestResults()
+
+	for _, p := range policies {
+		result, err := database.DB.Exec(ctx, "DELETE FROM speedtest_results WHERE "+p.condition, p.args...)
+		if err != nil {
+			return fmt.Errorf("failed to apply %s retention policy: %w", p.name, err)
+		}
+		if deleted := result.RowsAffected(); deleted > 0 {
+			log.Printf("Deleted %d %s results older than %d days", deleted, p.describe(), p.maxAgeDays)
+		}
+	}
+
+	if c.RawDays == 0 {
+		return nil
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var cutoff time.Time
+	if err := tx.QueryRow(ctx, rollupCutoffQuery, c.RawDays).Scan(&cutoff); err != nil {
+		return fmt.Errorf("failed to compute cutoff: %w", err)
+	}
+
+	for unit, table := range RollupTables {
+		for _, metric := range filters.Metrics {
+			if err := rollup(ctx, tx, unit, table, metric, cutoff); err != nil {
+				return err
+			}
+		}
+	}
+
+	result, err := tx.Exec(ctx, "DELETE FROM speedtest_results WHERE timestamp < $1", cutoff)
+	if err != nil {
+		return fmt.Errorf("failed to delete rolled up results: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit rollups: %w", err)
+	}
+
+	if deleted := result.RowsAffected(); deleted > 0 {
+		log.Printf("Rolled up %d results older than %s", deleted, cutoff.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// rollup summarizes one metric of the raw results before cutoff into table. A bucket that already
+// has a rollup, e.g. because older results were imported after it was rolled up, is merged into it:
+// counts, minimums, maximums, averages and sums of squares stay exact, while the percentiles become
+// a weighted average of both and are only approximate.
+func rollup(ctx context.Context, tx pgx.Tx, unit, table, metric string, cutoff time.Time) error {
+	// unit, table and metric come from RollupTables and filters.Metrics, so they can be interpolated safely
+	query := fmt.Sprintf(`
+        INSERT INTO %[1]s AS rollup (
+            timestamp, provider_id, server_name, schedule_id, metric,
+            sample_count, min_value, avg_value, max_value, sum_squares, p5, p25, p50, p75, p90, p95, p99
+        )
+        SELECT date_trunc('%[2]s', timestamp), provider_id, COALESCE(server_name, ''), schedule_id, '%[3]s',
+               COUNT(*), MIN(%[3]s), AVG(%[3]s), MAX(%[3]s), SUM(%[3]s * %[3]s),
+               percentile_cont(0.05) WITHIN GROUP (ORDER BY %[3]s),
+               percentile_cont(0.25) WITHIN GROUP (ORDER BY %[3]s),
+               percentile_cont(0.5) WITHIN GROUP (ORDER BY %[3]s),
+               percentile_cont(0.75) WITHIN GROUP (ORDER BY %[3]s),
+               percentile_cont(0.9) WITHIN GROUP (ORDER BY %[3]s),
+               percentile_cont(0.95) WITHIN GROUP (ORDER BY %[3]s),
+               percentile_cont(0.99) WITHIN GROUP (ORDER BY %[3]s)
+        FROM speedtest_results
+        WHERE timestamp < $1 AND %[3]s IS NOT NULL
+        GROUP BY 1, 2, 3, 4`+rollupMerge,
+		table, unit, metric)
+
+	if _, err := tx.Exec(ctx, query, cutoff); err != nil {
+		return fmt.Errorf("failed to roll up %s results into %s: %w", metric, table, err)
+	}
+	return nil
+}
+
+// rollupMerge merges an inserted rollup into the existing rollup of the same bucket, as described
+// for rollup. The rollup table must be aliased as rollup. Columns that one of both rollups lacks,
+// because it was written before they were added, stay NULL.
+const rollupMerge = `
+        ON CONFLICT (timestamp, metric, (COALESCE(provider_id::text, '')), server_name, (COALESCE(schedule_id::text, '')))
+        DO UPDATE SET
+            sample_count = rollup.sample_count + EXCLUDED.sample_count,
+            min_value = LEAST(rollup.min_value, EXCLUDED.min_value),
+            max_value = GREATEST(rollup.max_value, EXCLUDED.max_value),
+            avg_value = (rollup.avg_value * rollup.sample_count + EXCLUDED.avg_value * EXCLUDED.sample_count) / (rollup.sample_count + EXCLUDED.sample_count),
+            sum_squares = rollup.sum_squares + EXCLUDED.sum_squares,
+            p5 = (rollup.p5 * rollup.sample_count + EXCLUDED.p5 * EXCLUDED.sample_count) / (rollup.sample_count + EXCLUDED.sample_count),
+            p25 = (rollup.p25 * rollup.sample_count + EXCLUDED.p25 * EXCLUDED.sample_count) / (rollup.sample_count + EXCLUDED.sample_count),
+            p50 = (rollup.p50 * rollup.sample_count + EXCLUDED.p50 * EXCLUDED.sample_count) / (rollup.sample_count + EXCLUDED.sample_count),
+            p75 = (rollup.p75 * rollup.sample_count + EXCLUDED.p75 * EXCLUDED.sample_count) / (rollup.sample_count + EXCLUDED.sample_count),
+            p90 = (rollup.p90 * rollup.sample_count + EXCLUDED.p90 * EXCLUDED.sample_count) / (rollup.sample_count + EXCLUDED.sample_count),
+            p95 = (rollup.p95 * rollup.sample_count + EXCLUDED.p95 * EXCLUDED.sample_count) / (rollup.sample_count + EXCLUDED.sample_count),
+            p99 = (rollup.p99 * rollup.sample_count + EXCLUDED.p99 * EXCLUDED.sample_count) / (rollup.sample_count + EXCLUDED.sample_count)`
+
+// DetachSchedule moves the rollups of a schedule to the unscheduled rollups, as the foreign key on
+// speedtest_results.schedule_id does for raw results. Rollups of the same bucket are merged.
+func DetachSchedule(ctx context.Context, tx pgx.Tx, scheduleID string) error {
+	for _, table := range RollupTables {
+		// table comes from RollupTables, so it can be interpolated safely
+		query := fmt.Sprintf(`
+        WITH detached AS (
+            DELETE FROM %[1]s WHERE schedule_id = $1
+            RETURNING timestamp, provider_id, server_name, metric,
+                      sample_count, min_value, avg_value, max_value, sum_squares, p5, p25, p50, p75, p90, p95, p99
+        )
+        INSERT INTO %[1]s AS rollup (
+            timestamp, provider_id, server_name, schedule_id, metric,
+            sample_count, min_value, avg_value, max_value, sum_squares, p5, p25, p50, p75, p90, p95, p99
+        )
+        SELECT timestamp, provider_id, server_name, NULL, metric,
+               sample_count, min_value, avg_value, max_value, sum_squares, p5, p25, p50, p75, p90, p95, p99
+        FROM detached`+rollupMerge, table)
+
+		if _, err := tx.Exec(ctx, query, scheduleID); err != nil {
+			return fmt.Errorf("failed to detach rollups in %s: %w", table, err)
+		}
+	}
+	return nil
+}
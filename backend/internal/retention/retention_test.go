@@ -0,0 +1,50 @@
+package retention
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{name: "defaults", config: DefaultConfig()},
+		{name: "rollups and max age", config: Config{RawDays: 30, MaxAgeDays: 365, Interval: 6 * time.Hour}},
+		{name: "negative raw days", config: Config{RawDays: -1, Interval: time.Hour}, wantErr: "invalid raw days"},
+		{name: "negative max age", config: Config{MaxAgeDays: -7, Interval: time.Hour}, wantErr: "invalid max age days"},
+		{name: "no interval", config: Config{}, wantErr: "invalid interval"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPolicyDescribe(t *testing.T) {
+	for _, tt := range []struct {
+		policy policy
+		want   string
+	}{
+		{policy: policy{name: PolicySchedule, scheduleName: "Nightly"}, want: "schedule Nightly"},
+		{policy: policy{name: PolicyUnscheduled}, want: "unscheduled"},
+	} {
+		if got := tt.policy.describe(); got != tt.want {
+			t.Errorf("describe() = %q, want %q", got, tt.want)
+		}
+	}
+}
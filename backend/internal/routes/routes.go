@@ -3,18 +3,54 @@ package routes
 import (
 	"net/http"
 
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/alerts"
 	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/chartcolors"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/compare"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/metrics"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/notifications"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/plans"
 	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/providers"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/reports"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/retention"
 	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/schedules"
 	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/servers"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/settings"
 	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/speedtest"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/statistics"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/tournament"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/webhooks"
 )
 
 func SetupRoutes() {
 	http.HandleFunc("/api/speedtest", speedtest.SpeedTestHandler)
+	http.HandleFunc("/api/speedtest/bulk", speedtest.BulkResultsHandler)
+	http.HandleFunc("/api/speedtest/export", speedtest.ExportHandler)
+	http.HandleFunc("/api/speedtest/aggregate", speedtest.AggregateHandler)
+	http.HandleFunc("/api/speedtest/heatmap", speedtest.HeatmapHandler)
+	http.HandleFunc("/api/import", speedtest.ImportHandler)
+	http.HandleFunc("/api/retention/preview", retention.PreviewHandler)
+	http.HandleFunc("/api/statistics", statistics.StatisticsHandler)
+	http.HandleFunc("/api/compare", compare.CompareHandler)
+	http.HandleFunc("/api/leaderboard", tournament.LeaderboardHandler)
 	http.HandleFunc("/api/server-names", servers.ServerNamesHandler)
 	http.HandleFunc("/api/schedules", schedules.SchedulesHandler)
 	http.HandleFunc("/api/schedules/{id}", schedules.SchedulesHandler)
 	http.HandleFunc("/api/providers", providers.ProvidersHandler)
+	http.HandleFunc("/api/plans", plans.PlansHandler)
+	http.HandleFunc("/api/plans/{id}", plans.PlansHandler)
+	http.HandleFunc("/api/reports/sla", reports.SLAReportHandler)
 	http.HandleFunc("/api/chart-colors", chartcolors.ChartColorsHandler)
+	http.HandleFunc("/api/settings", settings.SettingsHandler)
+	http.HandleFunc("/api/webhooks", webhooks.WebhooksHandler)
+	http.HandleFunc("/api/webhooks/{id}", webhooks.WebhooksHandler)
+	http.HandleFunc("/api/webhooks/{id}/test", webhooks.TestWebhookHandler)
+	http.HandleFunc("/api/webhooks/{id}/deliveries", webhooks.DeliveriesHandler)
+	http.HandleFunc("/api/notifications", notifications.ChannelsHandler)
+	http.HandleFunc("/api/notifications/{id}", notifications.ChannelsHandler)
+	http.HandleFunc("/api/notifications/{id}/test", notifications.TestChannelHandler)
+	http.HandleFunc("/api/alerts", alerts.AlertsHandler)
+	http.HandleFunc("/api/alerts/events", alerts.HistoryHandler)
+	http.HandleFunc("/api/alerts/{id}", alerts.AlertsHandler)
+	http.HandleFunc("/api/alerts/{id}/history", alerts.HistoryHandler)
+	http.Handle("/metrics", metrics.Handler())
 }
@@ -8,16 +8,36 @@ import (
 	"net/http"
 	"sync"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/database"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/metrics"
 	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/retention"
 	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/speedtest"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/tournament"
 	"github.com/robfig/cron/v3"
 )
 
+// Delete modes decide what happens to the results of a deleted schedule
+const (
+	// DeleteModeDetach deletes the schedule and keeps its results as unscheduled results
+	DeleteModeDetach = "detach"
+	// DeleteModeCascade deletes the schedule together with its results and rollups
+	DeleteModeCascade = "cascade"
+	// DeleteModeArchive deactivates and hides the schedule but keeps it, so its results stay linked to it
+	DeleteModeArchive = "archive"
+)
+
 // Global cron scheduler instance with mutex for thread safety
 var (
 	cronScheduler *cron.Cron
+	cronEntries   map[cron.EntryID]models.Schedule
 	cronMutex     sync.Mutex
+	// paused stops the scheduler from running any schedule, see SetPaused
+	paused bool
+
+	changeListeners      []func()
+	changeListenersMutex sync.Mutex
 )
 
 func SchedulesHandler(w http.ResponseWriter, r *http.Request) {
@@ -43,12 +63,15 @@ func SchedulesHandler(w http.ResponseWriter, r *http.Request) {
 
 func listSchedules(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	includeArchived := r.URL.Query().Get("archived") == "true"
 	rows, err := database.DB.Query(ctx, `
 		SELECT s.id, s.name, s.cron_expression, s.provider_id, s.provider_name, 
-					 s.is_active, s.created_at, s.updated_at, s.host_endpoint, s.host_port, s.result_limit
+					 s.is_active, s.created_at, s.updated_at, s.host_endpoint, s.host_port, s.result_limit, s.max_age_days, s.tournament_providers,
+					 s.archived_at
 		FROM schedules s 
+		WHERE $1 OR s.archived_at IS NULL
 		ORDER BY s.created_at DESC
-	`)
+	`, includeArchived)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -64,7 +87,8 @@ func listSchedules(w http.ResponseWriter, r *http.Request) {
 		var hostPort sql.NullString
 		var resultLimit sql.NullInt32
 		err := rows.Scan(&s.ID, &s.Name, &s.CronExpression, &providerID, &providerName,
-			&s.IsActive, &s.CreatedAt, &s.UpdatedAt, &hostEndpoint, &hostPort, &resultLimit)
+			&s.IsActive, &s.CreatedAt, &s.UpdatedAt, &hostEndpoint, &hostPort, &resultLimit, &s.MaxAgeDays, &s.TournamentProviders,
+			&s.ArchivedAt)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -107,11 +131,13 @@ func getSchedule(w http.ResponseWriter, r *http.Request) {
 	var resultLimit sql.NullInt32
 	err := database.DB.QueryRow(ctx, `
 		SELECT s.id, s.name, s.cron_expression, s.provider_id, s.provider_name, 
-		       s.is_active, s.created_at, s.updated_at, s.host_endpoint, s.host_port, s.result_limit
+		       s.is_active, s.created_at, s.updated_at, s.host_endpoint, s.host_port, s.result_limit, s.max_age_days, s.tournament_providers,
+		       s.archived_at
 		FROM schedules s 
 		WHERE s.id = $1
 	`, id).Scan(&s.ID, &s.Name, &s.CronExpression, &providerID, &providerName,
-		&s.IsActive, &s.CreatedAt, &s.UpdatedAt, &hostEndpoint, &hostPort, &resultLimit)
+		&s.IsActive, &s.CreatedAt, &s.UpdatedAt, &hostEndpoint, &hostPort, &resultLimit, &s.MaxAgeDays, &s.TournamentProviders,
+		&s.ArchivedAt)
 
 	if err == sql.ErrNoRows {
 		http.Error(w, "Schedule not found", http.StatusNotFound)
@@ -163,11 +189,22 @@ func createSchedule(w http.ResponseWriter, r *http.Request) {
 		hostPort = nil
 	}
 
-	err := database.DB.QueryRow(ctx, `
-		INSERT INTO schedules (name, cron_expression, provider_id, provider_name, is_active, host_endpoint, host_port, result_limit)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	names, unknown, err := tournamentProviderNames(ctx, s.TournamentProviders)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if unknown != "" {
+		http.Error(w, fmt.Sprintf("unknown tournament provider: %q", unknown), http.StatusBadRequest)
+		return
+	}
+	s.TournamentProviders = names
+
+	err = database.DB.QueryRow(ctx, `
+		INSERT INTO schedules (name, cron_expression, provider_id, provider_name, is_active, host_endpoint, host_port, result_limit, max_age_days, tournament_providers)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 		RETURNING id, created_at, updated_at
-	`, s.Name, s.CronExpression, s.ProviderID, s.ProviderName, s.IsActive, hostEndpoint, hostPort, s.ResultLimit).Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt)
+	`, s.Name, s.CronExpression, s.ProviderID, s.ProviderName, s.IsActive, hostEndpoint, hostPort, s.ResultLimit, s.MaxAgeDays, s.TournamentProviders).Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt)
 
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -181,6 +218,43 @@ func createSchedule(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(s)
 }
 
+// tournamentProviderNames resolves tournament providers given by name or ID to provider names, which
+// is how runs look them up. unknown is the first entry that matches no provider.
+func tournamentProviderNames(ctx context.Context, keys []string) (names []string, unknown string, err error) {
+	names = []string{}
+	if len(keys) == 0 {
+		return names, "", nil
+	}
+
+	rows, err := database.DB.Query(ctx, "SELECT id::text, name FROM providers")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch providers: %w", err)
+	}
+	defer rows.Close()
+
+	known := make(map[string]string)
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, "", fmt.Errorf("failed to scan provider: %w", err)
+		}
+		known[id] = name
+		known[name] = name
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error reading providers: %w", err)
+	}
+
+	for _, key := range keys {
+		name, ok := known[key]
+		if !ok {
+			return nil, key, nil
+		}
+		names = append(names, name)
+	}
+	return names, "", nil
+}
+
 func updateSchedule(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	var s models.Schedule
@@ -210,12 +284,23 @@ func updateSchedule(w http.ResponseWriter, r *http.Request) {
 		hostPort = nil
 	}
 
+	names, unknown, err := tournamentProviderNames(ctx, s.TournamentProviders)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if unknown != "" {
+		http.Error(w, fmt.Sprintf("unknown tournament provider: %q", unknown), http.StatusBadRequest)
+		return
+	}
+	s.TournamentProviders = names
+
 	result, err := database.DB.Exec(ctx, `
 		UPDATE schedules 
 		SET name = $1, cron_expression = $2, provider_id = $3, provider_name = $4, is_active = $5, host_endpoint = $6, host_port = $7,
-		    result_limit = $8, updated_at = CURRENT_TIMESTAMP
-		WHERE id = $9
-	`, s.Name, s.CronExpression, s.ProviderID, s.ProviderName, s.IsActive, hostEndpoint, hostPort, s.ResultLimit, id)
+		    result_limit = $8, max_age_days = $9, tournament_providers = $10, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $11
+	`, s.Name, s.CronExpression, s.ProviderID, s.ProviderName, s.IsActive, hostEndpoint, hostPort, s.ResultLimit, s.MaxAgeDays, s.TournamentProviders, id)
 
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -235,6 +320,8 @@ func updateSchedule(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(s)
 }
 
+// deleteSchedule removes a schedule. The mode query parameter decides what happens to its
+// results: detach (the default), cascade or archive.
 func deleteSchedule(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	id := r.PathValue("id")
@@ -243,17 +330,40 @@ func deleteSchedule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := database.DB.Exec(ctx, "DELETE FROM schedules WHERE id = $1", id)
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = DeleteModeDetach
+	}
+
+	var result pgconn.CommandTag
+	var err error
+	switch mode {
+	case DeleteModeDetach:
+		result, err = deleteScheduleDetached(ctx, id)
+	case DeleteModeCascade:
+		result, err = deleteScheduleWithResults(ctx, id)
+	case DeleteModeArchive:
+		result, err = database.DB.Exec(ctx, `
+			UPDATE schedules SET is_active = false, archived_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND archived_at IS NULL
+		`, id)
+	default:
+		http.Error(w, fmt.Sprintf("Invalid mode: %q (expected detach, cascade or archive)", mode), http.StatusBadRequest)
+		return
+	}
+
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	rowsAffected := result.RowsAffected()
-	if rowsAffected == 0 {
+	if result.RowsAffected() == 0 {
 		http.Error(w, "Schedule not found", http.StatusNotFound)
 		return
 	}
+	// Detached and deleted results no longer belong to the schedule's series
+	if mode != DeleteModeArchive {
+		metrics.ReloadLatestResults()
+	}
 
 	// Restart cron jobs after deletion to remove the schedule from the cron scheduler
 	go RestartCronJobs()
@@ -261,15 +371,85 @@ func deleteSchedule(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// deleteScheduleDetached deletes a schedule and detaches its results and rollups in one transaction
+func deleteScheduleDetached(ctx context.Context, id string) (pgconn.CommandTag, error) {
+	var result pgconn.CommandTag
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := retention.DetachSchedule(ctx, tx, id); err != nil {
+		return result, err
+	}
+
+	// The foreign key on speedtest_results.schedule_id sets the schedule of its results to NULL
+	result, err = tx.Exec(ctx, "DELETE FROM schedules WHERE id = $1", id)
+	if err != nil {
+		return result, fmt.Errorf("failed to delete schedule: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return result, fmt.Errorf("failed to commit: %w", err)
+	}
+	return result, nil
+}
+
+// deleteScheduleWithResults deletes a schedule, its results and their rollups in one transaction
+func deleteScheduleWithResults(ctx context.Context, id string) (pgconn.CommandTag, error) {
+	var result pgconn.CommandTag
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, table := range []string{"speedtest_results", "result_rollups_hourly", "result_rollups_daily"} {
+		if _, err := tx.Exec(ctx, "DELETE FROM "+table+" WHERE schedule_id = $1", id); err != nil {
+			return result, fmt.Errorf("failed to delete results from %s: %w", table, err)
+		}
+	}
+
+	result, err = tx.Exec(ctx, "DELETE FROM schedules WHERE id = $1", id)
+	if err != nil {
+		return result, fmt.Errorf("failed to delete schedule: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return result, fmt.Errorf("failed to commit: %w", err)
+	}
+	return result, nil
+}
+
 func RestartCronJobs() {
 	cronMutex.Lock()
-	defer cronMutex.Unlock()
-
 	if cronScheduler != nil {
 		cronScheduler.Stop()
 	}
 
 	loadCronJobsInternal()
+	cronMutex.Unlock()
+
+	// Listeners run outside the lock so they are free to call back into this package
+	notifyChangeListeners()
+}
+
+// OnChange registers a function that is called whenever schedules are created, updated or deleted
+func OnChange(listener func()) {
+	changeListenersMutex.Lock()
+	defer changeListenersMutex.Unlock()
+	changeListeners = append(changeListeners, listener)
+}
+
+func notifyChangeListeners() {
+	changeListenersMutex.Lock()
+	listeners := append([]func(){}, changeListeners...)
+	changeListenersMutex.Unlock()
+
+	for _, listener := range listeners {
+		listener()
+	}
 }
 
 func LoadCronJobs() {
@@ -283,12 +463,13 @@ func LoadCronJobs() {
 // It assumes the caller has acquired the cronMutex
 func loadCronJobsInternal() {
 	cronScheduler = cron.New()
+	cronEntries = make(map[cron.EntryID]models.Schedule)
 
 	ctx := context.Background()
 	rows, err := database.DB.Query(ctx, `
-		SELECT id, name, cron_expression, provider_id, provider_name, host_endpoint, host_port
+		SELECT id, name, cron_expression, provider_id, provider_name, host_endpoint, host_port, tournament_providers
 		FROM schedules
-		WHERE is_active = true
+		WHERE is_active = true AND archived_at IS NULL
 	`)
 	if err != nil {
 		fmt.Printf("Error loading schedules: %v\n", err)
@@ -303,7 +484,7 @@ func loadCronJobsInternal() {
 		var providerName sql.NullString
 		var hostEndpoint sql.NullString
 		var hostPort sql.NullString
-		err := rows.Scan(&schedule.ID, &schedule.Name, &schedule.CronExpression, &providerID, &providerName, &hostEndpoint, &hostPort)
+		err := rows.Scan(&schedule.ID, &schedule.Name, &schedule.CronExpression, &providerID, &providerName, &hostEndpoint, &hostPort, &schedule.TournamentProviders)
 		if err != nil {
 			fmt.Printf("Error scanning schedule: %v\n", err)
 			continue
@@ -324,7 +505,15 @@ func loadCronJobsInternal() {
 
 		// Create a closure to capture the schedule variables
 		func(s models.Schedule) {
-			_, err := cronScheduler.AddFunc(s.CronExpression, func() {
+			entryID, err := cronScheduler.AddFunc(s.CronExpression, func() {
+				if len(s.TournamentProviders) > 0 {
+					go func() {
+						if err := tournament.RunRound(context.Background(), s); err != nil {
+							fmt.Printf("Error recording tournament round for schedule %s: %v\n", s.Name, err)
+						}
+					}()
+					return
+				}
 
 				var providers []string
 				if s.ProviderName != "" {
@@ -344,7 +533,9 @@ func loadCronJobsInternal() {
 
 			if err != nil {
 				fmt.Printf("Error adding cron job for schedule %s: %v\n", s.Name, err)
+				return
 			}
+			cronEntries[entryID] = s
 		}(schedule)
 	}
 
@@ -352,6 +543,55 @@ func loadCronJobsInternal() {
 		fmt.Printf("Error iterating schedules: %v\n", err)
 	}
 
+	if paused {
+		fmt.Println("Cron scheduler loaded but paused")
+		return
+	}
 	cronScheduler.Start()
 	fmt.Println("Cron scheduler started")
 }
+
+// SetPaused stops or resumes every scheduled run, e.g. when the global pause setting changes
+func SetPaused(p bool) {
+	cronMutex.Lock()
+	defer cronMutex.Unlock()
+
+	if p == paused {
+		return
+	}
+	paused = p
+	if cronScheduler == nil {
+		return
+	}
+	if paused {
+		cronScheduler.Stop()
+		fmt.Println("Cron scheduler paused")
+	} else {
+		cronScheduler.Start()
+		fmt.Println("Cron scheduler resumed")
+	}
+}
+
+// UpcomingRuns reports the next planned run of every schedule loaded into the cron scheduler
+func UpcomingRuns() []metrics.ScheduledRun {
+	cronMutex.Lock()
+	defer cronMutex.Unlock()
+
+	if cronScheduler == nil {
+		return nil
+	}
+
+	var runs []metrics.ScheduledRun
+	for _, entry := range cronScheduler.Entries() {
+		s, ok := cronEntries[entry.ID]
+		if !ok {
+			continue
+		}
+		runs = append(runs, metrics.ScheduledRun{
+			ScheduleID:   s.ID,
+			ScheduleName: s.Name,
+			Next:         entry.Next,
+		})
+	}
+	return runs
+}
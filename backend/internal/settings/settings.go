@@ -0,0 +1,243 @@
+package settings
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/database"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/filters"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+)
+
+// Units speeds can be displayed in
+const (
+	UnitsKbps = "kbps"
+	UnitsMbps = "mbps"
+	UnitsGbps = "gbps"
+)
+
+// ErrInvalidSettings is returned when an update would store settings that fail validation
+var ErrInvalidSettings = errors.New("invalid settings")
+
+// MaxResultLimit is the largest default page size that can be configured
+const MaxResultLimit = 1000
+
+// idPattern matches the UUIDs of providers and schedules. Default filters are applied to every
+// dashboard query, so an ID the database can't parse would break all of them.
+var idPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// defaults are used until Load has read the stored settings
+var defaults = models.UserSettings{
+	DefaultUnits:       UnitsMbps,
+	DefaultResultLimit: 20,
+}
+
+var (
+	current = defaults
+	// updateMutex serializes updates, so every listener sees the changes in the order they were stored
+	updateMutex  sync.Mutex
+	currentMutex sync.RWMutex
+
+	changeListeners      []func(models.UserSettings)
+	changeListenersMutex sync.Mutex
+)
+
+// settingsPatch holds the fields of a PATCH request. Fields left out of the request are nil and keep
+// their current value.
+type settingsPatch struct {
+	DefaultUnits       *string                `json:"default_units"`
+	DefaultFilters     *models.DefaultFilters `json:"default_filters"`
+	DefaultTimezone    *string                `json:"default_timezone"`
+	DefaultResultLimit *int                   `json:"default_result_limit"`
+	Paused             *bool                  `json:"paused"`
+}
+
+func SettingsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getSettings(w, r)
+	case http.MethodPatch:
+		patchSettings(w, r)
+	default:
+		errorDetails := fmt.Sprintf("Method not allowed: %v", r.Method)
+		http.Error(w, errorDetails, http.StatusMethodNotAllowed)
+	}
+}
+
+func getSettings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": Current(),
+	})
+}
+
+func patchSettings(w http.ResponseWriter, r *http.Request) {
+	var patch settingsPatch
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patch); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON payload: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	updated, err := Update(r.Context(), patch.apply)
+	if err != nil {
+		if errors.Is(err, ErrInvalidSettings) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Error updating settings: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to update settings: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": updated,
+	})
+}
+
+func (p settingsPatch) apply(s *models.UserSettings) {
+	if p.DefaultUnits != nil {
+		s.DefaultUnits = *p.DefaultUnits
+	}
+	if p.DefaultFilters != nil {
+		s.DefaultFilters = *p.DefaultFilters
+	}
+	if p.DefaultTimezone != nil {
+		s.DefaultTimezone = *p.DefaultTimezone
+	}
+	if p.DefaultResultLimit != nil {
+		s.DefaultResultLimit = *p.DefaultResultLimit
+	}
+	if p.Paused != nil {
+		s.Paused = *p.Paused
+	}
+}
+
+// Current returns the settings in effect
+func Current() models.UserSettings {
+	currentMutex.RLock()
+	defer currentMutex.RUnlock()
+	return current
+}
+
+// Load reads the stored settings and notifies the change listeners, so they start from the stored
+// values rather than the defaults
+func Load(ctx context.Context) error {
+	updateMutex.Lock()
+	defer updateMutex.Unlock()
+
+	s, err := fetchSettings(ctx)
+	if err != nil {
+		return err
+	}
+	setCurrent(s)
+	return nil
+}
+
+// Update applies change to a copy of the current settings, validates and stores the result, and
+// notifies the change listeners
+func Update(ctx context.Context, change func(*models.UserSettings)) (models.UserSettings, error) {
+	updateMutex.Lock()
+	defer updateMutex.Unlock()
+
+	s := Current()
+	change(&s)
+	if err := Validate(s); err != nil {
+		return s, err
+	}
+
+	filtersJSON, err := json.Marshal(s.DefaultFilters)
+	if err != nil {
+		return s, fmt.Errorf("failed to encode default filters: %w", err)
+	}
+	err = database.DB.QueryRow(ctx, `
+		UPDATE settings
+		SET default_units = $1, default_filters = $2, default_timezone = $3, default_result_limit = $4,
+		    paused = $5, updated_at = CURRENT_TIMESTAMP
+		RETURNING id, created_at, updated_at
+	`, s.DefaultUnits, filtersJSON, s.DefaultTimezone, s.DefaultResultLimit, s.Paused).Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return s, fmt.Errorf("failed to store settings: %w", err)
+	}
+
+	setCurrent(s)
+	return s, nil
+}
+
+// Validate checks every field of s
+func Validate(s models.UserSettings) error {
+	switch s.DefaultUnits {
+	case UnitsKbps, UnitsMbps, UnitsGbps:
+	default:
+		return fmt.Errorf("%w: default_units must be %s, %s or %s", ErrInvalidSettings, UnitsKbps, UnitsMbps, UnitsGbps)
+	}
+	if s.DefaultTimezone != "" {
+		if _, err := filters.ParseLocation(s.DefaultTimezone); err != nil {
+			return fmt.Errorf("%w: default_timezone: %v", ErrInvalidSettings, err)
+		}
+	}
+	if s.DefaultResultLimit < 1 || s.DefaultResultLimit > MaxResultLimit {
+		return fmt.Errorf("%w: default_result_limit must be between 1 and %d", ErrInvalidSettings, MaxResultLimit)
+	}
+	if s.DefaultFilters.Range != "" {
+		if _, err := filters.RangeStart(s.DefaultFilters.Range, time.Now()); err != nil {
+			return fmt.Errorf("%w: default_filters: %v", ErrInvalidSettings, err)
+		}
+	}
+	for name, ids := range map[string][]string{"providers": s.DefaultFilters.Providers, "schedules": s.DefaultFilters.Schedules} {
+		for _, id := range ids {
+			if !idPattern.MatchString(id) {
+				return fmt.Errorf("%w: default_filters.%s: %q is not an ID", ErrInvalidSettings, name, id)
+			}
+		}
+	}
+	return nil
+}
+
+// OnChange registers a function that is called with the new settings after they are loaded or updated
+func OnChange(listener func(models.UserSettings)) {
+	changeListenersMutex.Lock()
+	defer changeListenersMutex.Unlock()
+	changeListeners = append(changeListeners, listener)
+}
+
+// setCurrent replaces the settings in effect and notifies the change listeners. The caller must hold
+// updateMutex.
+func setCurrent(s models.UserSettings) {
+	currentMutex.Lock()
+	current = s
+	currentMutex.Unlock()
+
+	changeListenersMutex.Lock()
+	listeners := append([]func(models.UserSettings){}, changeListeners...)
+	changeListenersMutex.Unlock()
+
+	for _, listener := range listeners {
+		listener(s)
+	}
+}
+
+func fetchSettings(ctx context.Context) (models.UserSettings, error) {
+	var s models.UserSettings
+	var filtersJSON []byte
+	err := database.DB.QueryRow(ctx, `
+		SELECT id, default_units, default_filters, default_timezone, default_result_limit, paused, created_at, updated_at
+		FROM settings
+	`).Scan(&s.ID, &s.DefaultUnits, &filtersJSON, &s.DefaultTimezone, &s.DefaultResultLimit, &s.Paused, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return s, fmt.Errorf("failed to fetch settings: %w", err)
+	}
+	if err := json.Unmarshal(filtersJSON, &s.DefaultFilters); err != nil {
+		return s, fmt.Errorf("failed to decode default filters: %w", err)
+	}
+	return s, nil
+}
@@ -0,0 +1,81 @@
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+)
+
+func TestSettingsPatch(t *testing.T) {
+	s := models.UserSettings{
+		DefaultUnits:       UnitsMbps,
+		DefaultTimezone:    "Europe/Berlin",
+		DefaultResultLimit: 50,
+		DefaultFilters:     models.DefaultFilters{Range: "7d"},
+	}
+
+	var patch settingsPatch
+	if err := json.Unmarshal([]byte(`{"default_units": "gbps", "default_timezone": "", "paused": true}`), &patch); err != nil {
+		t.Fatal(err)
+	}
+	patch.apply(&s)
+
+	if s.DefaultUnits != UnitsGbps || s.DefaultTimezone != "" || !s.Paused {
+		t.Errorf("settings = %+v, want the sent fields changed", s)
+	}
+	if s.DefaultResultLimit != 50 || s.DefaultFilters.Range != "7d" {
+		t.Errorf("settings = %+v, want the other fields kept", s)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	const id = "0b6f8f5e-2f43-4c1a-9a5e-3f0c2d9b7a11"
+
+	tests := []struct {
+		name    string
+		change  func(s *models.UserSettings)
+		wantErr string
+	}{
+		{name: "defaults", change: func(*models.UserSettings) {}},
+		{
+			name: "every field set",
+			change: func(s *models.UserSettings) {
+				s.DefaultUnits = UnitsKbps
+				s.DefaultTimezone = "America/Chicago"
+				s.DefaultResultLimit = MaxResultLimit
+				s.DefaultFilters = models.DefaultFilters{Range: "mtd", Providers: []string{id}, Schedules: []string{id}}
+			},
+		},
+		{name: "unknown units", change: func(s *models.UserSettings) { s.DefaultUnits = "bps" }, wantErr: "default_units must be"},
+		{name: "unknown timezone", change: func(s *models.UserSettings) { s.DefaultTimezone = "Mars/Olympus" }, wantErr: "default_timezone"},
+		{name: "zero limit", change: func(s *models.UserSettings) { s.DefaultResultLimit = 0 }, wantErr: "default_result_limit"},
+		{name: "limit too large", change: func(s *models.UserSettings) { s.DefaultResultLimit = MaxResultLimit + 1 }, wantErr: "default_result_limit"},
+		{name: "unknown range", change: func(s *models.UserSettings) { s.DefaultFilters.Range = "1y" }, wantErr: "default_filters"},
+		{
+			name:    "provider name instead of ID",
+			change:  func(s *models.UserSettings) { s.DefaultFilters.Providers = []string{"librespeed"} },
+			wantErr: `default_filters.providers: "librespeed" is not an ID`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := defaults
+			tt.change(&s)
+
+			err := Validate(s)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidSettings) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
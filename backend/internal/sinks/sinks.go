@@ -0,0 +1,51 @@
+package sinks
+
+import (
+	"sync"
+
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+)
+
+// Sink receives every speed test result after it has been stored in the database.
+// HandleResult is called synchronously and must not block; slow work belongs in the sink's own goroutine.
+type Sink interface {
+	Name() string
+	HandleResult(result models.SpeedTestResult)
+}
+
+// RunSink is implemented by sinks that also want to observe the outcome of every provider run
+type RunSink interface {
+	HandleRun(event models.RunEvent)
+}
+
+var (
+	registered []Sink
+	sinksMutex sync.RWMutex
+)
+
+// Register adds a sink that will be notified of every stored result
+func Register(sink Sink) {
+	sinksMutex.Lock()
+	defer sinksMutex.Unlock()
+	registered = append(registered, sink)
+}
+
+// PublishResult hands a stored result to every registered sink
+func PublishResult(result models.SpeedTestResult) {
+	sinksMutex.RLock()
+	defer sinksMutex.RUnlock()
+	for _, sink := range registered {
+		sink.HandleResult(result)
+	}
+}
+
+// PublishRun hands a run outcome to every registered sink that implements RunSink
+func PublishRun(event models.RunEvent) {
+	sinksMutex.RLock()
+	defer sinksMutex.RUnlock()
+	for _, sink := range registered {
+		if runSink, ok := sink.(RunSink); ok {
+			runSink.HandleRun(event)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package speedtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/database"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/filters"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/retention"
+)
+
+// aggregateBuckets maps the bucket parameter to a date_trunc field
+var aggregateBuckets = map[string]string{
+	"1h": "hour",
+	"1d": "day",
+	"1w": "week",
+}
+
+// aggregateRollups maps the bucket parameter to the rollup table its buckets are built from
+var aggregateRollups = map[string]string{
+	"1h": retention.RollupTables["hour"],
+	"1d": retention.RollupTables["day"],
+	"1w": retention.RollupTables["day"],
+}
+
+// AggregateHandler returns min/avg/max/count of a metric per time bucket, optionally split by
+// provider, server or schedule. It accepts the same filters as GET /api/speedtest.
+func AggregateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errorDetails := fmt.Sprintf("Method not allowed: %v", r.Method)
+		http.Error(w, errorDetails, http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+
+	filter, err := filters.ParseResultFilterWithDefaults(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := models.AggregateResponse{
+		Bucket:   query.Get("bucket"),
+		Metric:   query.Get("metric"),
+		GroupBy:  query.Get("groupBy"),
+		Timezone: filter.Zone().String(),
+	}
+	if response.Bucket == "" {
+		response.Bucket = "1h"
+	}
+	if response.Metric == "" {
+		response.Metric = "download"
+	}
+
+	if _, ok := aggregateBuckets[response.Bucket]; !ok {
+		http.Error(w, fmt.Sprintf("Invalid bucket: %q (expected 1h, 1d or 1w)", response.Bucket), http.StatusBadRequest)
+		return
+	}
+	if !filters.IsMetric(response.Metric) {
+		http.Error(w, fmt.Sprintf("Invalid metric: %q", response.Metric), http.StatusBadRequest)
+		return
+	}
+	if _, ok := filters.GroupColumn(response.GroupBy); response.GroupBy != "" && !ok {
+		http.Error(w, fmt.Sprintf("Invalid groupBy: %q (expected provider, server or schedule)", response.GroupBy), http.StatusBadRequest)
+		return
+	}
+
+	buckets, err := aggregateResults(r.Context(), filter, response.Bucket, response.Metric, response.GroupBy)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to aggregate results: %v", err), http.StatusInternalServerError)
+		return
+	}
+	response.Buckets = buckets
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": response,
+	})
+}
+
+// aggregateResults computes the buckets in SQL from the raw results and, for ranges whose raw results
+// were removed by the retention job, from the rollups. Rollups carry no per-result values, so filters
+// on metric values, anomalies, clients or tags only match raw results. Buckets start at midnight or
+// on the hour in the time zone of the filter; daily rollups were truncated in the database time zone,
+// so in other zones their days are only approximate. bucket, metric and groupBy must already be
+// validated, since they are interpolated into the query.
+func aggregateResults(ctx context.Context, filter filters.ResultFilter, bucket, metric, groupBy string) ([]models.AggregateBucket, error) {
+	groupColumn := "''"
+	if column, ok := filters.GroupColumn(groupBy); ok {
+		groupColumn = "COALESCE(" + column + ", '')"
+	}
+
+	location := filter.Zone()
+	where, args := filter.Where([]interface{}{aggregateBuckets[bucket], location.String()})
+	sources := fmt.Sprintf(`
+            SELECT date_trunc($1, timestamp, $2) AS bucket, %[1]s AS group_key,
+                   MIN(%[2]s) AS min_value, SUM(%[2]s) AS total, MAX(%[2]s) AS max_value, COUNT(*) AS sample_count
+            FROM speedtest_results%[4]s%[3]s AND %[2]s IS NOT NULL
+            GROUP BY 1, 2`, groupColumn, metric, where, filters.ProviderJoin)
+	if !filter.RawOnly() {
+		sources += fmt.Sprintf(`
+            UNION ALL
+            SELECT date_trunc($1, timestamp, $2), %[1]s,
+                   MIN(min_value), SUM(avg_value * sample_count), MAX(max_value), SUM(sample_count)::bigint
+            FROM %[4]s%[5]s%[3]s AND metric = '%[2]s'
+            GROUP BY 1, 2`, groupColumn, metric, where, aggregateRollups[bucket], filters.ProviderJoin)
+	}
+
+	query := fmt.Sprintf(`
+        SELECT bucket, group_key, MIN(min_value), SUM(total) / SUM(sample_count), MAX(max_value), SUM(sample_count)::bigint
+        FROM (%s
+        ) sources
+        GROUP BY 1, 2
+        ORDER BY 1, 2`, sources)
+
+	rows, err := database.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := []models.AggregateBucket{}
+	for rows.Next() {
+		var b models.AggregateBucket
+		if err := rows.Scan(&b.Bucket, &b.Group, &b.Min, &b.Avg, &b.Max, &b.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan bucket: %w", err)
+		}
+		b.Bucket = b.Bucket.In(location)
+		buckets = append(buckets, b)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading buckets: %w", err)
+	}
+	return buckets, nil
+}
@@ -0,0 +1,125 @@
+package speedtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/database"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/filters"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/metrics"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+)
+
+const (
+	bulkActionDelete = "delete"
+	bulkActionTag    = "tag"
+	bulkActionUntag  = "untag"
+)
+
+// BulkResultsHandler deletes or tags every result matching the query filters in one transaction
+func BulkResultsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errorDetails := fmt.Sprintf("Method not allowed: %v", r.Method)
+		http.Error(w, errorDetails, http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, err := filters.ParseResultFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var request models.BulkResultsRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	request.Tag = strings.TrimSpace(request.Tag)
+	switch request.Action {
+	case bulkActionDelete:
+	case bulkActionTag, bulkActionUntag:
+		if request.Tag == "" {
+			http.Error(w, "tag is required for tag and untag actions", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, fmt.Sprintf("Unknown action: %q", request.Action), http.StatusBadRequest)
+		return
+	}
+
+	// Guard against wiping the whole table because a filter was forgotten
+	if filter.IsEmpty() && !request.ConfirmAll && !request.DryRun {
+		http.Error(w, "Refusing to modify all results without filters; set confirmAll to proceed", http.StatusBadRequest)
+		return
+	}
+
+	response, err := applyBulkOperation(r.Context(), filter, request)
+	if err != nil {
+		log.Printf("Error applying bulk %s: %v", request.Action, err)
+		http.Error(w, fmt.Sprintf("Failed to apply bulk operation: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": response,
+	}); err != nil {
+		http.Error(w, "Failed to encode response to JSON", http.StatusInternalServerError)
+	}
+}
+
+func applyBulkOperation(ctx context.Context, filter filters.ResultFilter, request models.BulkResultsRequest) (models.BulkResultsResponse, error) {
+	response := models.BulkResultsResponse{
+		Action: request.Action,
+		Tag:    request.Tag,
+		DryRun: request.DryRun,
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return response, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	where, args := filter.Where(nil)
+	if err := tx.QueryRow(ctx, "SELECT COUNT(*) FROM speedtest_results"+where, args...).Scan(&response.Matched); err != nil {
+		return response, fmt.Errorf("failed to count matching results: %w", err)
+	}
+
+	if request.DryRun {
+		return response, nil
+	}
+
+	var query string
+	switch request.Action {
+	case bulkActionDelete:
+		query = "DELETE FROM speedtest_results" + where
+	case bulkActionTag:
+		where, args = filter.Where([]interface{}{request.Tag})
+		query = "UPDATE speedtest_results SET tags = array_append(tags, $1)" + where + " AND NOT ($1 = ANY(tags))"
+	case bulkActionUntag:
+		where, args = filter.Where([]interface{}{request.Tag})
+		query = "UPDATE speedtest_results SET tags = array_remove(tags, $1)" + where + " AND ($1 = ANY(tags))"
+	}
+
+	tag, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		return response, fmt.Errorf("failed to %s results: %w", request.Action, err)
+	}
+	response.Affected = tag.RowsAffected()
+
+	if err := tx.Commit(ctx); err != nil {
+		return response, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	if request.Action == bulkActionDelete {
+		metrics.ReloadLatestResults()
+	}
+
+	return response, nil
+}
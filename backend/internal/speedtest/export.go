@@ -0,0 +1,295 @@
+package speedtest
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/database"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/filters"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+)
+
+const (
+	exportFormatCSV    = "csv"
+	exportFormatJSON   = "json"
+	exportFormatNDJSON = "ndjson"
+
+	// exportFetchSize is the number of rows pulled from the cursor per round trip
+	exportFetchSize = 500
+)
+
+var exportContentTypes = map[string]string{
+	exportFormatCSV:    "text/csv",
+	exportFormatJSON:   "application/json",
+	exportFormatNDJSON: "application/x-ndjson",
+}
+
+// exportCSVHeader uses the speedtest_results column names so exported files can be imported again
+var exportCSVHeader = []string{
+	"timestamp", "server_name", "server_url", "client_ip", "client_hostname",
+	"client_city", "client_region", "client_country", "client_loc", "client_org",
+	"client_postal", "client_timezone", "client_interface", "bytes_sent", "bytes_received",
+	"ping", "jitter", "upload", "download", "share",
+	"provider_id", "provider_name", "schedule_id", "tags",
+}
+
+// resultWriter encodes results one at a time in an export format
+type resultWriter interface {
+	Write(result models.SpeedTestResult) error
+	Close() error
+}
+
+// ExportHandler streams every result matching the query filters as CSV, JSON or NDJSON, newest first
+// or in the order given by sort like the results listing
+func ExportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errorDetails := fmt.Sprintf("Method not allowed: %v", r.Method)
+		http.Error(w, errorDetails, http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = exportFormatCSV
+	}
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown export format: %q", format), http.StatusBadRequest)
+		return
+	}
+
+	filter, err := filters.ParseResultFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	order, err := parseSort(r.URL.Query().Get("sort"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filename := fmt.Sprintf("botb-results-%s.%s", time.Now().Format("20060102-150405"), format)
+	headersWritten := false
+	writeHeaders := func() {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		headersWritten = true
+	}
+
+	var writer resultWriter
+	err = streamFilteredResults(r.Context(), filter, order, func(result models.SpeedTestResult) error {
+		if writer == nil {
+			writeHeaders()
+			writer = newResultWriter(format, w)
+		}
+		return writer.Write(result)
+	})
+
+	if err != nil {
+		log.Printf("Error exporting results: %v", err)
+		if !headersWritten {
+			http.Error(w, fmt.Sprintf("Failed to export results: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// An empty export still produces a valid, empty document
+	if writer == nil {
+		writeHeaders()
+		writer = newResultWriter(format, w)
+	}
+	if err := writer.Close(); err != nil {
+		log.Printf("Error finishing export: %v", err)
+	}
+}
+
+// streamFilteredResults walks the matching results in the given order through a server-side cursor
+// so that large exports never hold more than one batch in memory
+func streamFilteredResults(ctx context.Context, filter filters.ResultFilter, order resultSort, handle func(models.SpeedTestResult) error) error {
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	where, args := filter.Where(nil)
+	direction := "ASC"
+	if order.Descending {
+		direction = "DESC"
+	}
+	query := "DECLARE export_cursor NO SCROLL CURSOR FOR SELECT" + resultColumns +
+		"\n        FROM speedtest_results" + filters.ProviderJoin + where + orderBy(order.keys(), direction)
+	if _, err := tx.Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to open cursor: %w", err)
+	}
+
+	fetch := "FETCH " + strconv.Itoa(exportFetchSize) + " FROM export_cursor"
+	for {
+		rows, err := tx.Query(ctx, fetch)
+		if err != nil {
+			return fmt.Errorf("failed to fetch results: %w", err)
+		}
+
+		count := 0
+		for rows.Next() {
+			result, err := scanResult(rows, filter.Zone())
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			if err := handle(result); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to write result: %w", err)
+			}
+			count++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error reading rows: %w", err)
+		}
+
+		if count < exportFetchSize {
+			return nil
+		}
+	}
+}
+
+func newResultWriter(format string, w http.ResponseWriter) resultWriter {
+	flusher, _ := w.(http.Flusher)
+	switch format {
+	case exportFormatJSON:
+		return &jsonResultWriter{w: w, flusher: flusher}
+	case exportFormatNDJSON:
+		return &ndjsonResultWriter{encoder: json.NewEncoder(w), flusher: flusher}
+	default:
+		return &csvResultWriter{csv: csv.NewWriter(w), flusher: flusher}
+	}
+}
+
+type csvResultWriter struct {
+	csv           *csv.Writer
+	flusher       http.Flusher
+	headerWritten bool
+	count         int
+}
+
+func (c *csvResultWriter) Write(result models.SpeedTestResult) error {
+	if !c.headerWritten {
+		if err := c.csv.Write(exportCSVHeader); err != nil {
+			return err
+		}
+		c.headerWritten = true
+	}
+
+	formatFloat := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	if err := c.csv.Write([]string{
+		result.Timestamp, result.Server.Name, result.Server.URL, result.Client.IP, result.Client.Hostname,
+		result.Client.City, result.Client.Region, result.Client.Country, result.Client.Loc, result.Client.Org,
+		result.Client.Postal, result.Client.Timezone, result.Client.Interface,
+		strconv.FormatInt(result.BytesSent, 10), strconv.FormatInt(result.BytesReceived, 10),
+		formatFloat(result.Ping), formatFloat(result.Jitter), formatFloat(result.Upload), formatFloat(result.Download), result.Share,
+		result.ProviderID, result.ProviderName, result.ScheduleID, strings.Join(result.Tags, ";"),
+	}); err != nil {
+		return err
+	}
+
+	c.count++
+	if c.count%exportFetchSize == 0 {
+		c.flush()
+	}
+	return c.csv.Error()
+}
+
+func (c *csvResultWriter) Close() error {
+	if !c.headerWritten {
+		if err := c.csv.Write(exportCSVHeader); err != nil {
+			return err
+		}
+	}
+	c.flush()
+	return c.csv.Error()
+}
+
+func (c *csvResultWriter) flush() {
+	c.csv.Flush()
+	if c.flusher != nil {
+		c.flusher.Flush()
+	}
+}
+
+type jsonResultWriter struct {
+	w       io.Writer
+	flusher http.Flusher
+	count   int
+}
+
+func (j *jsonResultWriter) Write(result models.SpeedTestResult) error {
+	separator := ","
+	if j.count == 0 {
+		separator = "["
+	}
+	if _, err := io.WriteString(j.w, separator); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	if _, err := j.w.Write(data); err != nil {
+		return err
+	}
+
+	j.count++
+	if j.count%exportFetchSize == 0 && j.flusher != nil {
+		j.flusher.Flush()
+	}
+	return nil
+}
+
+func (j *jsonResultWriter) Close() error {
+	closing := "]\n"
+	if j.count == 0 {
+		closing = "[]\n"
+	}
+	_, err := io.WriteString(j.w, closing)
+	return err
+}
+
+type ndjsonResultWriter struct {
+	encoder *json.Encoder
+	flusher http.Flusher
+	count   int
+}
+
+func (n *ndjsonResultWriter) Write(result models.SpeedTestResult) error {
+	if err := n.encoder.Encode(result); err != nil {
+		return err
+	}
+
+	n.count++
+	if n.count%exportFetchSize == 0 && n.flusher != nil {
+		n.flusher.Flush()
+	}
+	return nil
+}
+
+func (n *ndjsonResultWriter) Close() error {
+	if n.flusher != nil {
+		n.flusher.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package speedtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/database"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/filters"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/retention"
+)
+
+// HeatmapHandler returns the median and count of a metric for every weekday and hour of day in
+// the tz time zone (default the server time zone). It accepts the same filters as GET /api/speedtest.
+func HeatmapHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errorDetails := fmt.Sprintf("Method not allowed: %v", r.Method)
+		http.Error(w, errorDetails, http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+
+	filter, err := filters.ParseResultFilterWithDefaults(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := models.HeatmapResponse{
+		Metric:   query.Get("metric"),
+		Timezone: filter.Zone().String(),
+	}
+	if response.Metric == "" {
+		response.Metric = "download"
+	}
+
+	if !filters.IsMetric(response.Metric) {
+		http.Error(w, fmt.Sprintf("Invalid metric: %q", response.Metric), http.StatusBadRequest)
+		return
+	}
+
+	if err := fillHeatmap(r.Context(), filter, &response); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to compute heatmap: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": response,
+	})
+}
+
+// fillHeatmap computes the cells in SQL from the raw results and, for ranges whose raw results were
+// removed by the retention job, from the hourly rollups, like the aggregate endpoint. The median of a
+// cell that includes rollups is the average of the median of its raw results and of every rollup,
+// weighted by their sample counts, so it is only approximate. Hourly rollups were truncated in the
+// database time zone, so in zones with a fractional offset their hours are approximate too. The
+// metric must already be validated, since it is interpolated into the query.
+func fillHeatmap(ctx context.Context, filter filters.ResultFilter, response *models.HeatmapResponse) error {
+	where, args := filter.Where([]interface{}{response.Timezone})
+	sources := fmt.Sprintf(`
+            SELECT EXTRACT(DOW FROM timestamp AT TIME ZONE $1)::int AS weekday,
+                   EXTRACT(HOUR FROM timestamp AT TIME ZONE $1)::int AS hour,
+                   percentile_cont(0.5) WITHIN GROUP (ORDER BY %[1]s) AS median, COUNT(*) AS sample_count
+            FROM speedtest_results%[2]s AND %[1]s IS NOT NULL
+            GROUP BY 1, 2`, response.Metric, where)
+	if !filter.RawOnly() {
+		sources += fmt.Sprintf(`
+            UNION ALL
+            SELECT EXTRACT(DOW FROM timestamp AT TIME ZONE $1)::int, EXTRACT(HOUR FROM timestamp AT TIME ZONE $1)::int,
+                   p50, sample_count
+            FROM %[3]s%[2]s AND metric = '%[1]s'`, response.Metric, where, retention.RollupTables["hour"])
+	}
+
+	query := fmt.Sprintf(`
+        SELECT weekday, hour, SUM(median * sample_count) / SUM(sample_count), SUM(sample_count)::bigint
+        FROM (%s
+        ) sources
+        GROUP BY 1, 2`, sources)
+
+	rows, err := database.DB.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var weekday, hour int
+		var cell models.HeatmapCell
+		if err := rows.Scan(&weekday, &hour, &cell.Median, &cell.Count); err != nil {
+			return fmt.Errorf("failed to scan heatmap cell: %w", err)
+		}
+		response.Cells[weekday][hour] = cell
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error reading heatmap cells: %w", err)
+	}
+	return nil
+}
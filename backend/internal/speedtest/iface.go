@@ -0,0 +1,50 @@
+package speedtest
+
+import (
+	"context"
+	"net"
+	"net/url"
+)
+
+// routeInterface returns the name of the local network interface that traffic to host leaves
+// through, or "" when it can't be determined. Connecting a UDP socket only selects the route, so
+// no packets are sent.
+func routeInterface(ctx context.Context, host string) string {
+	if host == "" {
+		return ""
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", net.JoinHostPort(host, "9"))
+	if err != nil {
+		return ""
+	}
+	localIP := conn.LocalAddr().(*net.UDPAddr).IP
+	conn.Close()
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+	for _, iface := range interfaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if network, ok := addr.(*net.IPNet); ok && network.IP.Equal(localIP) {
+				return iface.Name
+			}
+		}
+	}
+	return ""
+}
+
+// urlHost returns the host name of a server URL, or "" when it doesn't parse
+func urlHost(serverURL string) string {
+	parsed, err := url.Parse(serverURL)
+	if err != nil {
+		return ""
+	}
+	return parsed.Hostname()
+}
@@ -0,0 +1,41 @@
+package speedtest
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+func TestURLHost(t *testing.T) {
+	for serverURL, want := range map[string]string{
+		"https://speed.example.com/backend/empty.php": "speed.example.com",
+		"http://192.0.2.10:8080/":                     "192.0.2.10",
+		"http://[2001:db8::1]:8080/":                  "2001:db8::1",
+		"speed.example.com":                           "",
+		"http://%zz":                                  "",
+	} {
+		if got := urlHost(serverURL); got != want {
+			t.Errorf("urlHost(%q) = %q, want %q", serverURL, got, want)
+		}
+	}
+}
+
+func TestRouteInterface(t *testing.T) {
+	if got := routeInterface(context.Background(), ""); got != "" {
+		t.Errorf("routeInterface(\"\") = %q, want \"\"", got)
+	}
+
+	loopback := ""
+	interfaces, _ := net.Interfaces()
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			loopback = iface.Name
+		}
+	}
+	if loopback == "" {
+		t.Skip("no loopback interface")
+	}
+	if got := routeInterface(context.Background(), "127.0.0.1"); got != loopback {
+		t.Errorf("routeInterface(127.0.0.1) = %q, want %q", got, loopback)
+	}
+}
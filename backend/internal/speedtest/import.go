@@ -0,0 +1,586 @@
+package speedtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/database"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/metrics"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+)
+
+const (
+	importFormatCSV            = "csv"
+	importFormatLibrespeedCSV  = "librespeed-csv"
+	importFormatLibrespeedJSON = "librespeed-json"
+	importFormatOoklaJSON      = "ookla-json"
+
+	importMaxMemory    = 32 << 20
+	importMaxRowErrors = 100
+)
+
+// importDefaultProviders maps each import format to the provider its results are attributed to
+// when neither the request nor the row names one. Ookla has no provider that can run tests, so
+// Ookla results must be attached to an existing provider named by the request.
+var importDefaultProviders = map[string]string{
+	importFormatCSV:            "",
+	importFormatLibrespeedCSV:  "librespeed",
+	importFormatLibrespeedJSON: "librespeed",
+	importFormatOoklaJSON:      "",
+}
+
+// librespeedCSVHeader is the column order written by librespeed-cli --csv when --csv-header is not used
+var librespeedCSVHeader = []string{"Timestamp", "Server Name", "Address", "Ping", "Jitter", "Download", "Upload", "Share", "IP"}
+
+// csvColumnAliases maps the column names used by other tools onto speedtest_results columns
+var csvColumnAliases = map[string]string{
+	"address": "server_url",
+	"ip":      "client_ip",
+}
+
+var importTimestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	"2006-01-02 15:04:05.999999999 -0700",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+}
+
+type importedFile struct {
+	name string
+	rows []importedRow
+}
+
+type importedRow struct {
+	row    int
+	result models.SpeedTestResult
+	raw    string
+	err    error
+}
+
+// ImportHandler accepts multipart uploads of results exported by other tools and stores them
+func ImportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errorDetails := fmt.Sprintf("Method not allowed: %v", r.Method)
+		http.Error(w, errorDetails, http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseMultipartForm(importMaxMemory); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid multipart form: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	format := r.FormValue("format")
+	defaultProvider, ok := importDefaultProviders[format]
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown import format: %q", format), http.StatusBadRequest)
+		return
+	}
+	if provider := r.FormValue("provider"); provider != "" {
+		defaultProvider = provider
+	}
+	if format == importFormatOoklaJSON && defaultProvider == "" {
+		http.Error(w, "provider is required for ookla-json imports", http.StatusBadRequest)
+		return
+	}
+	dryRun, _ := strconv.ParseBool(r.FormValue("dryRun"))
+
+	files := r.MultipartForm.File["file"]
+	if len(files) == 0 {
+		http.Error(w, "At least one file is required", http.StatusBadRequest)
+		return
+	}
+
+	report := models.ImportReport{
+		Format:   format,
+		Provider: defaultProvider,
+		DryRun:   dryRun,
+		Errors:   []models.ImportRowError{},
+	}
+
+	parsedFiles := make([]importedFile, 0, len(files))
+	for _, header := range files {
+		file, err := header.Open()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to open %s: %v", header.Filename, err), http.StatusBadRequest)
+			return
+		}
+		rows, err := parseImportFile(format, file)
+		file.Close()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to parse %s: %v", header.Filename, err), http.StatusBadRequest)
+			return
+		}
+		parsedFiles = append(parsedFiles, importedFile{name: header.Filename, rows: rows})
+	}
+
+	if err := importResults(r.Context(), parsedFiles, defaultProvider, &report); err != nil {
+		log.Printf("Error importing results: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to import results: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": report,
+	}); err != nil {
+		http.Error(w, "Failed to encode response to JSON", http.StatusInternalServerError)
+	}
+}
+
+// importResults validates, de-duplicates and stores the parsed rows in a single transaction
+func importResults(ctx context.Context, files []importedFile, defaultProvider string, report *models.ImportReport) error {
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	providerIDs, err := loadProviderIDs(ctx, tx)
+	if err != nil {
+		return err
+	}
+	scheduleIDs, err := loadScheduleIDs(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	addError := func(file string, row int, err error) {
+		report.Invalid++
+		if len(report.Errors) < importMaxRowErrors {
+			report.Errors = append(report.Errors, models.ImportRowError{File: file, Row: row, Error: err.Error()})
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, file := range files {
+		for _, row := range file.rows {
+			report.Total++
+			if row.err != nil {
+				addError(file.name, row.row, row.err)
+				continue
+			}
+
+			result := row.result
+
+			// An exported provider_id is only kept when it exists here, so files exported by another
+			// installation fall back to the provider name
+			providerID, ok := providerIDs[result.ProviderID]
+			if !ok {
+				if result.ProviderName == "" {
+					result.ProviderName = defaultProvider
+				}
+				if result.ProviderName == "" {
+					addError(file.name, row.row, errors.New("no provider given for row"))
+					continue
+				}
+				if providerID, ok = providerIDs[result.ProviderName]; !ok {
+					addError(file.name, row.row, fmt.Errorf("unknown provider %q", result.ProviderName))
+					continue
+				}
+			}
+			result.ProviderID = providerID
+
+			// Results of schedules that don't exist here are kept without one, as migration 15 did
+			if !scheduleIDs[result.ScheduleID] {
+				result.ScheduleID = ""
+			}
+
+			key := result.Timestamp + "|" + result.Server.Name
+			if seen[key] {
+				report.Duplicates++
+				continue
+			}
+			seen[key] = true
+
+			var exists bool
+			if err := tx.QueryRow(ctx, `
+				SELECT EXISTS (
+					SELECT 1 FROM speedtest_results
+					WHERE timestamp = $1 AND server_name = $2
+				)`, result.Timestamp, result.Server.Name).Scan(&exists); err != nil {
+				return fmt.Errorf("failed to check for duplicates: %w", err)
+			}
+			if exists {
+				report.Duplicates++
+				continue
+			}
+
+			if !report.DryRun {
+				if _, err := tx.Exec(ctx, insertResultQuery, insertResultArgs(result, row.raw)...); err != nil {
+					return fmt.Errorf("failed to insert row %d of %s: %w", row.row, file.name, err)
+				}
+			}
+			report.Imported++
+		}
+	}
+
+	if report.DryRun {
+		return nil
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	metrics.ReloadLatestResults()
+
+	log.Printf("Imported %d results (%d duplicates, %d invalid)", report.Imported, report.Duplicates, report.Invalid)
+	return nil
+}
+
+func loadProviderIDs(ctx context.Context, tx pgx.Tx) (map[string]string, error) {
+	rows, err := tx.Query(ctx, "SELECT id, name FROM providers")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch providers: %w", err)
+	}
+	defer rows.Close()
+
+	// Providers can be named by ID or name
+	providerIDs := make(map[string]string)
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, fmt.Errorf("failed to scan provider: %w", err)
+		}
+		providerIDs[name] = id
+		providerIDs[id] = id
+	}
+
+	return providerIDs, rows.Err()
+}
+
+func loadScheduleIDs(ctx context.Context, tx pgx.Tx) (map[string]bool, error) {
+	rows, err := tx.Query(ctx, "SELECT id FROM schedules")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch schedules: %w", err)
+	}
+	defer rows.Close()
+
+	scheduleIDs := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan schedule: %w", err)
+		}
+		scheduleIDs[id] = true
+	}
+
+	return scheduleIDs, rows.Err()
+}
+
+func parseImportFile(format string, file io.Reader) ([]importedRow, error) {
+	switch format {
+	case importFormatCSV:
+		return parseResultsCSV(file, nil)
+	case importFormatLibrespeedCSV:
+		return parseResultsCSV(file, librespeedCSVHeader)
+	case importFormatLibrespeedJSON:
+		return parseJSONRecords(file, parseLibrespeedRecord)
+	case importFormatOoklaJSON:
+		return parseJSONRecords(file, parseOoklaRecord)
+	}
+	return nil, fmt.Errorf("unknown import format: %q", format)
+}
+
+// parseResultsCSV reads a CSV file whose columns are named after speedtest_results columns.
+// When defaultHeader is given, files without a header row are assumed to use that column order.
+func parseResultsCSV(file io.Reader, defaultHeader []string) ([]importedRow, error) {
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	header := records[0]
+	firstRow := 2
+	if defaultHeader != nil && !strings.EqualFold(strings.TrimSpace(header[0]), defaultHeader[0]) {
+		header = defaultHeader
+		firstRow = 1
+	}
+
+	columns := make([]string, len(header))
+	for i, name := range header {
+		column := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "_")
+		if alias, ok := csvColumnAliases[column]; ok {
+			column = alias
+		}
+		columns[i] = column
+	}
+
+	var rows []importedRow
+	for i, record := range records[firstRow-1:] {
+		row := importedRow{row: firstRow + i, raw: encodeCSVRecord(record)}
+		if len(record) != len(columns) {
+			row.err = fmt.Errorf("expected %d columns, got %d", len(columns), len(record))
+			rows = append(rows, row)
+			continue
+		}
+
+		for j, value := range record {
+			if err := setResultColumn(&row.result, columns[j], strings.TrimSpace(value)); err != nil {
+				row.err = fmt.Errorf("column %s: %w", header[j], err)
+				break
+			}
+		}
+		if row.err == nil {
+			row.err = validateImportedResult(&row.result)
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func encodeCSVRecord(record []string) string {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write(record)
+	writer.Flush()
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+// setResultColumn assigns a CSV value to the result field backing the named column.
+// Unknown columns are ignored so that exports with extra fields can still be imported.
+func setResultColumn(result *models.SpeedTestResult, column, value string) error {
+	parseFloat := func(target *float64) error {
+		if value == "" {
+			return nil
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		*target = f
+		return nil
+	}
+	parseInt := func(target *int64) error {
+		if value == "" {
+			return nil
+		}
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		*target = i
+		return nil
+	}
+
+	switch column {
+	case "timestamp":
+		result.Timestamp = value
+	case "server_name":
+		result.Server.Name = value
+	case "server_url":
+		result.Server.URL = value
+	case "client_ip":
+		result.Client.IP = value
+	case "client_hostname":
+		result.Client.Hostname = value
+	case "client_city":
+		result.Client.City = value
+	case "client_region":
+		result.Client.Region = value
+	case "client_country":
+		result.Client.Country = value
+	case "client_loc":
+		result.Client.Loc = value
+	case "client_org":
+		result.Client.Org = value
+	case "client_postal":
+		result.Client.Postal = value
+	case "client_timezone":
+		result.Client.Timezone = value
+	case "client_interface":
+		result.Client.Interface = value
+	case "bytes_sent":
+		return parseInt(&result.BytesSent)
+	case "bytes_received":
+		return parseInt(&result.BytesReceived)
+	case "ping":
+		return parseFloat(&result.Ping)
+	case "jitter":
+		return parseFloat(&result.Jitter)
+	case "upload":
+		return parseFloat(&result.Upload)
+	case "download":
+		return parseFloat(&result.Download)
+	case "share":
+		result.Share = value
+	case "provider_id":
+		result.ProviderID = value
+	case "provider_name":
+		result.ProviderName = value
+	case "schedule_id":
+		result.ScheduleID = value
+	case "tags":
+		// Exports join tags with semicolons
+		for _, tag := range strings.Split(value, ";") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				result.Tags = append(result.Tags, tag)
+			}
+		}
+	}
+	return nil
+}
+
+// parseJSONRecords decodes a stream of JSON values, each either a single record or an array of
+// records, so that both single reports and appended log files can be imported
+func parseJSONRecords(file io.Reader, parse func(json.RawMessage) (models.SpeedTestResult, error)) ([]importedRow, error) {
+	decoder := json.NewDecoder(file)
+
+	var rows []importedRow
+	for {
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		records := []json.RawMessage{value}
+		if trimmed := bytes.TrimSpace(value); len(trimmed) > 0 && trimmed[0] == '[' {
+			records = nil
+			if err := json.Unmarshal(trimmed, &records); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, record := range records {
+			row := importedRow{row: len(rows) + 1, raw: string(record)}
+			row.result, row.err = parse(record)
+			if row.err == nil {
+				row.err = validateImportedResult(&row.result)
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	return rows, nil
+}
+
+func parseLibrespeedRecord(record json.RawMessage) (models.SpeedTestResult, error) {
+	var result models.SpeedTestResult
+	if err := json.Unmarshal(record, &result); err != nil {
+		return result, fmt.Errorf("invalid librespeed record: %w", err)
+	}
+
+	// Provider and schedule fields are never part of librespeed-cli output
+	result.ProviderID = ""
+	result.ProviderName = ""
+	result.ScheduleID = ""
+	result.Tags = nil
+	return result, nil
+}
+
+// ooklaResult is the subset of `speedtest --format=json` output that maps onto a result
+type ooklaResult struct {
+	Type      string `json:"type"`
+	Timestamp string `json:"timestamp"`
+	Ping      struct {
+		Jitter  float64 `json:"jitter"`
+		Latency float64 `json:"latency"`
+	} `json:"ping"`
+	Download struct {
+		Bandwidth float64 `json:"bandwidth"`
+		Bytes     int64   `json:"bytes"`
+	} `json:"download"`
+	Upload struct {
+		Bandwidth float64 `json:"bandwidth"`
+		Bytes     int64   `json:"bytes"`
+	} `json:"upload"`
+	ISP       string `json:"isp"`
+	Interface struct {
+		Name       string `json:"name"`
+		ExternalIP string `json:"externalIp"`
+	} `json:"interface"`
+	Server struct {
+		Host     string `json:"host"`
+		Port     int    `json:"port"`
+		Name     string `json:"name"`
+		Location string `json:"location"`
+		Country  string `json:"country"`
+	} `json:"server"`
+	Result struct {
+		URL string `json:"url"`
+	} `json:"result"`
+}
+
+func parseOoklaRecord(record json.RawMessage) (models.SpeedTestResult, error) {
+	var ookla ooklaResult
+	var result models.SpeedTestResult
+	if err := json.Unmarshal(record, &ookla); err != nil {
+		return result, fmt.Errorf("invalid Ookla record: %w", err)
+	}
+	if ookla.Type != "" && ookla.Type != "result" {
+		return result, fmt.Errorf("skipping Ookla record of type %q", ookla.Type)
+	}
+
+	serverName := ookla.Server.Name
+	if ookla.Server.Location != "" {
+		serverName = fmt.Sprintf("%s (%s)", ookla.Server.Name, ookla.Server.Location)
+	}
+
+	result.Timestamp = ookla.Timestamp
+	result.Server.Name = serverName
+	if ookla.Server.Host != "" {
+		result.Server.URL = fmt.Sprintf("%s:%d", ookla.Server.Host, ookla.Server.Port)
+	}
+	result.Client.IP = ookla.Interface.ExternalIP
+	result.Client.Interface = ookla.Interface.Name
+	result.Client.Org = ookla.ISP
+	result.BytesSent = ookla.Upload.Bytes
+	result.BytesReceived = ookla.Download.Bytes
+	result.Ping = ookla.Ping.Latency
+	result.Jitter = ookla.Ping.Jitter
+	// Ookla reports bandwidth in bytes per second
+	result.Upload = ookla.Upload.Bandwidth * 8 / 1000000
+	result.Download = ookla.Download.Bandwidth * 8 / 1000000
+	result.Share = ookla.Result.URL
+	return result, nil
+}
+
+// validateImportedResult checks the fields required to store and de-duplicate a result
+// and normalizes its timestamp to RFC3339
+func validateImportedResult(result *models.SpeedTestResult) error {
+	if result.Timestamp == "" {
+		return errors.New("timestamp is required")
+	}
+
+	var parsed time.Time
+	var err error
+	for _, layout := range importTimestampLayouts {
+		if parsed, err = time.Parse(layout, result.Timestamp); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("unrecognized timestamp %q", result.Timestamp)
+	}
+	result.Timestamp = parsed.UTC().Format(time.RFC3339Nano)
+
+	if result.Server.Name == "" {
+		return errors.New("server name is required")
+	}
+	if result.Download < 0 || result.Upload < 0 || result.Ping < 0 || result.Jitter < 0 {
+		return errors.New("metrics must not be negative")
+	}
+	return nil
+}
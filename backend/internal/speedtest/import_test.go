@@ -0,0 +1,174 @@
+package speedtest
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+)
+
+func TestParseResultsCSV(t *testing.T) {
+	file := strings.Join([]string{
+		"timestamp,server_name,download,upload,ping,jitter,provider_id,schedule_id,tags,extra",
+		"2024-05-01T12:00:00Z,Frankfurt,512.5,48,12.5,0.75,p1,s1,office; wifi ;,ignored",
+		"2024-05-01T13:00:00Z,Frankfurt,fast,48,12.5,0.75,,,,",
+		"2024-05-01T14:00:00Z,Frankfurt",
+	}, "\n")
+
+	rows, err := parseImportFile(importFormatCSV, strings.NewReader(file))
+	if err != nil {
+		t.Fatalf("parseImportFile() error = %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(rows))
+	}
+
+	first := rows[0]
+	if first.err != nil {
+		t.Fatalf("row %d error = %v", first.row, first.err)
+	}
+	if first.row != 2 || first.result.Server.Name != "Frankfurt" || first.result.Download != 512.5 ||
+		first.result.ProviderID != "p1" || first.result.ScheduleID != "s1" ||
+		!slices.Equal(first.result.Tags, []string{"office", "wifi"}) {
+		t.Errorf("row 2 = %+v", first.result)
+	}
+
+	if rows[1].err == nil || !strings.Contains(rows[1].err.Error(), `invalid number "fast"`) {
+		t.Errorf("row 3 error = %v, want an invalid number", rows[1].err)
+	}
+	if rows[2].err == nil || !strings.Contains(rows[2].err.Error(), "expected 10 columns, got 2") {
+		t.Errorf("row 4 error = %v, want a column count error", rows[2].err)
+	}
+}
+
+func TestParseLibrespeedCSV(t *testing.T) {
+	record := "2024-05-01 12:00:00.5 +0200 CEST,Frankfurt,https://example.com,12.5,0.75,512.5,48,,192.0.2.1"
+
+	tests := []struct {
+		name    string
+		file    string
+		wantRow int
+	}{
+		{name: "without header", file: record, wantRow: 1},
+		{name: "with header", file: strings.Join(librespeedCSVHeader, ",") + "\n" + record, wantRow: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := parseImportFile(importFormatLibrespeedCSV, strings.NewReader(tt.file))
+			if err != nil {
+				t.Fatalf("parseImportFile() error = %v", err)
+			}
+			if len(rows) != 1 || rows[0].err != nil {
+				t.Fatalf("rows = %+v, want one valid row", rows)
+			}
+
+			row := rows[0]
+			if row.row != tt.wantRow {
+				t.Errorf("row = %d, want %d", row.row, tt.wantRow)
+			}
+			if row.result.Timestamp != "2024-05-01T10:00:00.5Z" || row.result.Server.URL != "https://example.com" ||
+				row.result.Client.IP != "192.0.2.1" || row.result.Download != 512.5 || row.result.Ping != 12.5 {
+				t.Errorf("result = %+v", row.result)
+			}
+		})
+	}
+}
+
+func TestParseOoklaJSON(t *testing.T) {
+	file := `[
+		{"type": "log", "message": "starting"},
+		{"type": "result", "timestamp": "2024-05-01T12:00:00Z",
+		 "ping": {"jitter": 0.75, "latency": 12.5},
+		 "download": {"bandwidth": 12500000, "bytes": 2000},
+		 "upload": {"bandwidth": 6250000, "bytes": 1000},
+		 "isp": "Example ISP",
+		 "interface": {"name": "eth0", "externalIp": "192.0.2.1"},
+		 "server": {"host": "speed.example.com", "port": 8080, "name": "Example", "location": "Frankfurt"},
+		 "result": {"url": "https://example.com/result"}}
+	]
+	{"type": "result", "timestamp": "yesterday", "server": {"name": "Example"}}`
+
+	rows, err := parseImportFile(importFormatOoklaJSON, strings.NewReader(file))
+	if err != nil {
+		t.Fatalf("parseImportFile() error = %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(rows))
+	}
+
+	if rows[0].err == nil || !strings.Contains(rows[0].err.Error(), `type "log"`) {
+		t.Errorf("row 1 error = %v, want the log record skipped", rows[0].err)
+	}
+
+	if rows[1].err != nil {
+		t.Fatalf("row 2 error = %v", rows[1].err)
+	}
+	want := models.SpeedTestResult{
+		Timestamp:     "2024-05-01T12:00:00Z",
+		BytesSent:     1000,
+		BytesReceived: 2000,
+		Ping:          12.5,
+		Jitter:        0.75,
+		Upload:        50,
+		Download:      100,
+		Share:         "https://example.com/result",
+	}
+	want.Server.Name = "Example (Frankfurt)"
+	want.Server.URL = "speed.example.com:8080"
+	want.Client.IP = "192.0.2.1"
+	want.Client.Interface = "eth0"
+	want.Client.Org = "Example ISP"
+	got := rows[1].result
+	if got.Timestamp != want.Timestamp || got.Server != want.Server || got.Client != want.Client ||
+		got.BytesSent != want.BytesSent || got.BytesReceived != want.BytesReceived ||
+		got.Ping != want.Ping || got.Jitter != want.Jitter || got.Upload != want.Upload ||
+		got.Download != want.Download || got.Share != want.Share {
+		t.Errorf("row 2 = %+v, want %+v", got, want)
+	}
+
+	if rows[2].err == nil || !strings.Contains(rows[2].err.Error(), "unrecognized timestamp") {
+		t.Errorf("row 3 error = %v, want an unrecognized timestamp", rows[2].err)
+	}
+}
+
+func TestValidateImportedResult(t *testing.T) {
+	tests := []struct {
+		name          string
+		timestamp     string
+		server        string
+		download      float64
+		wantTimestamp string
+		wantErr       string
+	}{
+		{name: "RFC 3339", timestamp: "2024-05-01T14:00:00+02:00", server: "a", wantTimestamp: "2024-05-01T12:00:00Z"},
+		{name: "Go time string", timestamp: "2024-05-01 12:00:00.25 +0000 UTC", server: "a", wantTimestamp: "2024-05-01T12:00:00.25Z"},
+		{name: "without zone", timestamp: "2024-05-01 12:00:00", server: "a", wantTimestamp: "2024-05-01T12:00:00Z"},
+		{name: "missing timestamp", server: "a", wantErr: "timestamp is required"},
+		{name: "bad timestamp", timestamp: "01/05/2024", server: "a", wantErr: "unrecognized timestamp"},
+		{name: "missing server", timestamp: "2024-05-01T12:00:00Z", wantErr: "server name is required"},
+		{name: "negative metric", timestamp: "2024-05-01T12:00:00Z", server: "a", download: -1, wantErr: "must not be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := models.SpeedTestResult{Timestamp: tt.timestamp, Download: tt.download}
+			result.Server.Name = tt.server
+
+			err := validateImportedResult(&result)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("validateImportedResult() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateImportedResult() error = %v", err)
+			}
+			if result.Timestamp != tt.wantTimestamp {
+				t.Errorf("timestamp = %q, want %q", result.Timestamp, tt.wantTimestamp)
+			}
+		})
+	}
+}
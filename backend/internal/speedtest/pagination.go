@@ -0,0 +1,221 @@
+package speedtest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/database"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/filters"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/plans"
+)
+
+// Cursor directions. Next pages continue in the sort order, prev pages go back against it.
+const (
+	cursorNext = "next"
+	cursorPrev = "prev"
+)
+
+var (
+	errInvalidCursor = errors.New("invalid cursor")
+	errCursorSort    = errors.New("cursor was created for a different sort")
+)
+
+// resultSort orders the results listing by timestamp or a metric. Ties are broken by timestamp and
+// ID, so every result has a unique position a cursor can point at.
+type resultSort struct {
+	Column     string
+	Descending bool
+}
+
+// defaultSort lists the newest results first
+var defaultSort = resultSort{Column: "timestamp", Descending: true}
+
+// parseSort reads a sort parameter such as "download" (ascending) or "-ping" (descending)
+func parseSort(value string) (resultSort, error) {
+	if value == "" {
+		return defaultSort, nil
+	}
+	s := resultSort{Column: strings.TrimPrefix(value, "-"), Descending: strings.HasPrefix(value, "-")}
+	if s.Column != "timestamp" && !filters.IsMetric(s.Column) {
+		return s, fmt.Errorf("invalid sort: %q", value)
+	}
+	return s, nil
+}
+
+func (s resultSort) String() string {
+	if s.Descending {
+		return "-" + s.Column
+	}
+	return s.Column
+}
+
+// key returns the SQL expression results are sorted on before timestamp and ID. Missing metric values
+// sort last in either direction. Only validated columns are interpolated.
+func (s resultSort) key(value string) string {
+	if s.Column == "timestamp" {
+		return ""
+	}
+	last := "'Infinity'"
+	if s.Descending {
+		last = "'-Infinity'"
+	}
+	return fmt.Sprintf("COALESCE(%s::float8, %s::float8)", value, last)
+}
+
+// keys returns the speedtest_results columns the sort orders on, most significant first
+func (s resultSort) keys() []string {
+	keys := []string{"speedtest_results.timestamp", "speedtest_results.id"}
+	if key := s.key("speedtest_results." + s.Column); key != "" {
+		keys = append([]string{key}, keys...)
+	}
+	return keys
+}
+
+// orderBy returns an ORDER BY clause that sorts every key in direction, ASC or DESC
+func orderBy(keys []string, direction string) string {
+	return " ORDER BY " + strings.Join(keys, " "+direction+", ") + " " + direction
+}
+
+// cursor is the position of a result in the sort order, and which side of it to list
+type cursor struct {
+	Sort string `json:"s"`
+	// Value is the sort metric of the result, which is nil when sorting by timestamp or the value is missing
+	Value     *float64  `json:"v,omitempty"`
+	Timestamp time.Time `json:"t"`
+	ID        string    `json:"id"`
+	Direction string    `json:"d"`
+}
+
+func (c cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return c, errInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" || (c.Direction != cursorNext && c.Direction != cursorPrev) {
+		return c, errInvalidCursor
+	}
+	return c, nil
+}
+
+// fetchResultsPage lists up to limit results in the given order, starting after the position given
+// by after. A nil cursor starts at the first result.
+func fetchResultsPage(ctx context.Context, filter filters.ResultFilter, order resultSort, limit int, after *cursor) ([]models.SpeedTestResult, models.Paging, error) {
+	paging := models.Paging{Limit: limit}
+	if after != nil && after.Sort != order.String() {
+		return nil, paging, errCursorSort
+	}
+
+	// The position is selected again at full precision, since SpeedTestResult.Timestamp is rounded to seconds
+	positionColumns := ", speedtest_results.timestamp, NULL::float8"
+	if order.Column != "timestamp" {
+		positionColumns = fmt.Sprintf(", speedtest_results.timestamp, speedtest_results.%s::float8", order.Column)
+	}
+	query := "SELECT" + resultColumns + plans.PlanColumns + positionColumns +
+		"\n        FROM speedtest_results" + plans.PlanJoin + filters.ProviderJoin
+
+	where, args := filter.Where(nil)
+	query += where
+
+	// Prev pages are read against the sort order and reversed afterwards
+	descending := order.Descending
+	if after != nil && after.Direction == cursorPrev {
+		descending = !descending
+	}
+	direction, operator := "ASC", ">"
+	if descending {
+		direction, operator = "DESC", "<"
+	}
+
+	keys := order.keys()
+
+	if after != nil {
+		position := fmt.Sprintf("$%d, $%d", len(args)+1, len(args)+2)
+		args = append(args, after.Timestamp, after.ID)
+		if key := order.key(fmt.Sprintf("$%d", len(args)+1)); key != "" {
+			position = key + ", " + position
+			args = append(args, after.Value)
+		}
+		query += fmt.Sprintf(" AND (%s) %s (%s)", strings.Join(keys, ", "), operator, position)
+	}
+
+	// One extra row tells whether there is another page in the same direction
+	query += orderBy(keys, direction)
+	query += fmt.Sprintf(" LIMIT $%d", len(args)+1)
+	args = append(args, limit+1)
+
+	rows, err := database.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, paging, fmt.Errorf("failed to fetch results: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.SpeedTestResult{}
+	var positions []cursor
+	for rows.Next() {
+		var plan plans.PlanValues
+		position := cursor{Sort: order.String()}
+		result, err := scanResult(rows, filter.Zone(), append(plan.Targets(), &position.Timestamp, &position.Value)...)
+		if err != nil {
+			return nil, paging, err
+		}
+		plan.Apply(&result)
+		position.ID = result.ID
+		results = append(results, result)
+		positions = append(positions, position)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, paging, fmt.Errorf("error reading rows: %w", err)
+	}
+
+	more := len(results) > limit
+	if more {
+		results, positions = results[:limit], positions[:limit]
+	}
+	if after != nil && after.Direction == cursorPrev {
+		for i, j := 0, len(results)-1; i < j; i, j = i+1, j-1 {
+			results[i], results[j] = results[j], results[i]
+			positions[i], positions[j] = positions[j], positions[i]
+		}
+	}
+
+	// Coming from a page on one side means there are results on that side
+	hasPrev := after != nil && (after.Direction == cursorNext || more)
+	hasNext := more || (after != nil && after.Direction == cursorPrev)
+
+	if len(results) > 0 {
+		if hasNext {
+			next := positions[len(positions)-1]
+			next.Direction = cursorNext
+			paging.Next = next.encode()
+		}
+		if hasPrev {
+			prev := positions[0]
+			prev.Direction = cursorPrev
+			paging.Prev = prev.encode()
+		}
+	}
+	return results, paging, nil
+}
+
+// countResults counts every result matching the filter, regardless of paging
+func countResults(ctx context.Context, filter filters.ResultFilter) (int64, error) {
+	where, args := filter.Where(nil)
+	var total int64
+	if err := database.DB.QueryRow(ctx, "SELECT COUNT(*) FROM speedtest_results"+where, args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to count results: %w", err)
+	}
+	return total, nil
+}
@@ -0,0 +1,80 @@
+package speedtest
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    resultSort
+		wantKey string
+		wantErr bool
+	}{
+		{value: "", want: defaultSort},
+		{value: "timestamp", want: resultSort{Column: "timestamp"}},
+		{value: "download", want: resultSort{Column: "download"}, wantKey: "COALESCE(download::float8, 'Infinity'::float8)"},
+		{value: "-ping", want: resultSort{Column: "ping", Descending: true}, wantKey: "COALESCE(ping::float8, '-Infinity'::float8)"},
+		{value: "server_name", wantErr: true},
+		{value: "-download; DROP TABLE speedtest_results", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseSort(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseSort(%q) = %+v, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("parseSort(%q) = %+v, %v, want %+v", tt.value, got, err, tt.want)
+			}
+			if key := got.key(got.Column); key != tt.wantKey {
+				t.Errorf("key = %q, want %q", key, tt.wantKey)
+			}
+			// String gives back the parameter, so cursors can be matched to their sort
+			if again, _ := parseSort(got.String()); again != got {
+				t.Errorf("parseSort(%q) = %+v, want %+v", got.String(), again, got)
+			}
+		})
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	value := 512.5
+	c := cursor{
+		Sort:      "-download",
+		Value:     &value,
+		Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 123456000, time.UTC),
+		ID:        "r1",
+		Direction: cursorNext,
+	}
+
+	got, err := decodeCursor(c.encode())
+	if err != nil {
+		t.Fatalf("decodeCursor() error = %v", err)
+	}
+	if got.Sort != c.Sort || got.Value == nil || *got.Value != value || !got.Timestamp.Equal(c.Timestamp) ||
+		got.ID != c.ID || got.Direction != c.Direction {
+		t.Errorf("decodeCursor() = %+v, want %+v", got, c)
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	for name, value := range map[string]string{
+		"not base64":        "%%%",
+		"not JSON":          "bm90IGpzb24",
+		"missing ID":        cursor{Sort: "timestamp", Direction: cursorNext}.encode(),
+		"unknown direction": cursor{Sort: "timestamp", ID: "r1", Direction: "sideways"}.encode(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := decodeCursor(value); !errors.Is(err, errInvalidCursor) {
+				t.Errorf("decodeCursor() error = %v, want %v", err, errInvalidCursor)
+			}
+		})
+	}
+}
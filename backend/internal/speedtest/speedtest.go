@@ -4,20 +4,72 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"os"
 	"os/exec"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/database"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/filters"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/metrics"
 	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/settings"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/sinks"
 )
 
+// errTestAlreadyRunning is returned when a provider refuses to start because a test is in progress
+var errTestAlreadyRunning = errors.New("speed test is already running")
+
+// ProviderConfig holds the locations of the provider CLIs and services, and how long a run may take
+type ProviderConfig struct {
+	LibrespeedPath string `yaml:"librespeed_path" toml:"librespeed_path"`
+	Iperf3Path     string `yaml:"iperf3_path" toml:"iperf3_path"`
+	PingPath       string `yaml:"ping_path" toml:"ping_path"`
+	// NodeBackendURL is the address of the Node.js service that runs Cloudflare tests
+	NodeBackendURL string `yaml:"node_backend_url" toml:"node_backend_url"`
+	// RunTimeout cancels a provider run that takes longer; 0 lets runs take as long as they need
+	RunTimeout time.Duration `yaml:"run_timeout" toml:"run_timeout"`
+}
+
+// DefaultProviderConfig looks the CLIs up on PATH and expects the Node.js service on localhost.
+// Runs have no timeout unless one is configured.
+func DefaultProviderConfig() ProviderConfig {
+	return ProviderConfig{
+		LibrespeedPath: "librespeed-cli",
+		Iperf3Path:     "iperf3",
+		PingPath:       "ping",
+		NodeBackendURL: "http://localhost:3000",
+	}
+}
+
+// Validate checks the provider configuration
+func (c ProviderConfig) Validate() error {
+	if c.LibrespeedPath == "" || c.Iperf3Path == "" || c.PingPath == "" {
+		return fmt.Errorf("provider paths can't be empty")
+	}
+	if c.NodeBackendURL == "" {
+		return fmt.Errorf("node backend URL is required")
+	}
+	if c.RunTimeout < 0 {
+		return fmt.Errorf("invalid run timeout: %s", c.RunTimeout)
+	}
+	return nil
+}
+
+// providerConfig is set once at startup through Configure
+var providerConfig = DefaultProviderConfig()
+
+// Configure sets the provider configuration used by every following run
+func Configure(c ProviderConfig) {
+	providerConfig = c
+}
+
 func SpeedTestHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
@@ -30,38 +82,61 @@ func SpeedTestHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// getSpeedTests lists results newest first, or in the order given by sort, one page at a time. Pass
+// the next or prev cursor of a response as cursor to get the adjacent page, and total=true to count
+// every matching result.
 func getSpeedTests(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	query := r.URL.Query()
 
-	startDate := r.URL.Query().Get("startDate")
-	endDate := r.URL.Query().Get("endDate")
-	serverNames := r.URL.Query()["server"]
-	limitStr := r.URL.Query().Get("limit")
-	offsetStr := r.URL.Query().Get("offset")
-	providers := r.URL.Query()["providers"]
+	filter, err := filters.ParseResultFilterWithDefaults(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	limit := 20
-	offset := 0
+	order, err := parseSort(query.Get("sort"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	if limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-			limit = l
-		}
+	limit := settings.Current().DefaultResultLimit
+	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 {
+		limit = l
 	}
-	if offsetStr != "" {
-		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
-			offset = o
+
+	var after *cursor
+	if v := query.Get("cursor"); v != "" {
+		c, err := decodeCursor(v)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
+		after = &c
 	}
 
-	results, err := fetchFilteredResults(ctx, startDate, endDate, serverNames, providers, limit, offset)
+	results, paging, err := fetchResultsPage(ctx, filter, order, limit, after)
+	if errors.Is(err, errCursorSort) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to retrieve results: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	if query.Get("total") == "true" {
+		total, err := countResults(ctx, filter)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to retrieve results: %v", err), http.StatusInternalServerError)
+			return
+		}
+		paging.Total = &total
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(results); err != nil {
+	if err := json.NewEncoder(w).Encode(models.ResultsPage{Data: results, Paging: paging}); err != nil {
 		http.Error(w, "Failed to encode results to JSON", http.StatusInternalServerError)
 	}
 }
@@ -98,27 +173,49 @@ func pruneOldResults(ctx context.Context, scheduleID string) error {
 	return nil
 }
 
+const insertResultQuery = `
+        INSERT INTO speedtest_results (
+            raw_result, timestamp, server_name, server_url, 
+            client_ip, client_hostname, client_city, client_region, client_country, client_loc, client_org, client_postal, client_timezone, client_interface,
+            bytes_sent, bytes_received, ping, jitter, upload, download, share,
+            provider_id, schedule_id, round_id, tags
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25)`
+
+func insertResultArgs(result models.SpeedTestResult, rawResult string) []interface{} {
+	// Ad-hoc runs have no schedule and must be stored as NULL rather than an empty UUID
+	var scheduleID interface{}
+	if result.ScheduleID != "" {
+		scheduleID = result.ScheduleID
+	}
+	var roundID interface{}
+	if result.RoundID != "" {
+		roundID = result.RoundID
+	}
+	// tags is NOT NULL, and a nil slice would be sent as NULL
+	tags := result.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	return []interface{}{
+		rawResult, result.Timestamp, result.Server.Name, result.Server.URL,
+		result.Client.IP, result.Client.Hostname, result.Client.City, result.Client.Region, result.Client.Country, result.Client.Loc, result.Client.Org, result.Client.Postal, result.Client.Timezone, result.Client.Interface,
+		result.BytesSent, result.BytesReceived, result.Ping, result.Jitter, result.Upload, result.Download, result.Share,
+		result.ProviderID, scheduleID, roundID, tags,
+	}
+}
+
 func storeResult(ctx context.Context, result models.SpeedTestResult, rawResult string) error {
 	errCount := 0
 	errCountMax := 10
 	var err error
 
 	for errCount < errCountMax {
-		_, err = database.DB.Exec(ctx, `
-        INSERT INTO speedtest_results (
-            raw_result, timestamp, server_name, server_url, 
-            client_ip, client_hostname, client_city, client_region, client_country, client_loc, client_org, client_postal, client_timezone,
-            bytes_sent, bytes_received, ping, jitter, upload, download, share,
-            provider_id, provider_name, schedule_id
-        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)`,
-			rawResult, result.Timestamp, result.Server.Name, result.Server.URL,
-			result.Client.IP, result.Client.Hostname, result.Client.City, result.Client.Region, result.Client.Country, result.Client.Loc, result.Client.Org, result.Client.Postal, result.Client.Timezone,
-			result.BytesSent, result.BytesReceived, result.Ping, result.Jitter, result.Upload, result.Download, result.Share,
-			result.ProviderID, result.ProviderName, result.ScheduleID,
-		)
+		err = database.DB.QueryRow(ctx, insertResultQuery+" RETURNING id", insertResultArgs(result, rawResult)...).Scan(&result.ID)
 
 		if err == nil {
 			log.Printf("Successfully stored result for %s using provider %s", result.Timestamp, result.ProviderName)
+			sinks.PublishResult(result)
 
 			// If this result is associated with a schedule, check and enforce the result limit
 			if result.ScheduleID != "" {
@@ -155,66 +252,102 @@ func RunSpeedTests(ctx context.Context, requestData models.SpeedTestRequest) {
 			continue
 		}
 
+		runCtx, cancel := ctx, context.CancelFunc(func() {})
+		if providerConfig.RunTimeout > 0 {
+			runCtx, cancel = context.WithTimeout(ctx, providerConfig.RunTimeout)
+		}
+
+		start := time.Now()
 		if providerName == "librespeed" {
-			runLibrespeedTest(ctx, providerID, providerName, requestData.ScheduleID)
+			err = runLibrespeedTest(runCtx, providerID, providerName, requestData.ScheduleID, requestData.RoundID)
 		} else if providerName == "cloudflare" {
-			runCloudflareTest(ctx, providerID, providerName, requestData.ScheduleID)
+			err = runCloudflareTest(runCtx, providerID, providerName, requestData.ScheduleID, requestData.RoundID)
 		} else if providerName == "iperf3" {
-			runIperf3Test(ctx, providerID, providerName, requestData.HostEndpoint, requestData.HostPort, requestData.ScheduleID)
+			err = runIperf3Test(runCtx, providerID, providerName, requestData.HostEndpoint, requestData.HostPort, requestData.ScheduleID, requestData.RoundID)
 		} else {
 			log.Printf("Provider '%s' is not currently supported for testing", providerName)
+			cancel()
+			continue
 		}
+		cancel()
+
+		duration := time.Since(start)
+		status := runStatus(err)
+		event := models.RunEvent{
+			ScheduleID:   requestData.ScheduleID,
+			ProviderName: providerName,
+			Status:       status,
+			StartedAt:    start,
+			DurationMs:   duration.Milliseconds(),
+		}
+		if err != nil {
+			log.Printf("Speed test using provider %s %s: %v", providerName, status, err)
+			event.Error = err.Error()
+		}
+		metrics.ObserveRun(providerName, status, duration)
+		sinks.PublishRun(event)
+	}
+}
+
+// runStatus classifies the outcome of a single provider run
+func runStatus(err error) string {
+	switch {
+	case err == nil:
+		return metrics.RunStatusSuccess
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return metrics.RunStatusCanceled
+	case errors.Is(err, errTestAlreadyRunning):
+		return metrics.RunStatusSkipped
+	default:
+		return metrics.RunStatusFailure
 	}
 }
 
-func runLibrespeedTest(ctx context.Context, providerID, providerName, scheduleID string) {
-	cmd := exec.CommandContext(ctx, "librespeed-cli", "--json")
+func runLibrespeedTest(ctx context.Context, providerID, providerName, scheduleID, roundID string) error {
+	cmd := exec.CommandContext(ctx, providerConfig.LibrespeedPath, "--json")
 	output, err := cmd.Output()
 	if err != nil {
-		if ctx.Err() == context.Canceled {
-			log.Printf("Speed test was canceled: context deadline exceeded or request canceled")
-			return
-		}
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			log.Printf("Speed test failed with stderr: %s", string(exitErr.Stderr))
-		} else {
-			log.Printf("Error running speedtest: %v", err)
-		}
-		return
+		return commandError(ctx, err)
 	}
 
 	var results []models.SpeedTestResult
 	if err := json.Unmarshal(output, &results); err != nil {
-		log.Printf("Error parsing JSON: %v\nOutput: %s", err, string(output))
-		return
+		return fmt.Errorf("error parsing JSON: %w\nOutput: %s", err, string(output))
 	}
 
 	for _, result := range results {
 		if ctx.Err() != nil {
-			log.Printf("Context canceled while storing results")
-			return
+			return fmt.Errorf("context canceled while storing results: %w", ctx.Err())
 		}
 
 		result.ProviderID = providerID
 		result.ProviderName = providerName
 		result.ScheduleID = scheduleID
+		result.RoundID = roundID
+		result.Client.Interface = routeInterface(ctx, urlHost(result.Server.URL))
 
 		if err := storeResult(ctx, result, string(output)); err != nil {
-			log.Printf("Error storing result: %v", err)
+			return fmt.Errorf("error storing result: %w", err)
 		}
 	}
+	return nil
 }
 
-func runCloudflareTest(ctx context.Context, providerID, providerName, scheduleID string) {
-	nodeBackendURL := os.Getenv("NODE_BACKEND_URL")
-	if nodeBackendURL == "" {
-		nodeBackendURL = "http://localhost:3000"
+// commandError describes why a provider CLI failed, preferring its stderr output
+func commandError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("speed test was canceled: %w", ctx.Err())
 	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return fmt.Errorf("speed test failed with stderr: %s", string(exitErr.Stderr))
+	}
+	return fmt.Errorf("error running speedtest: %w", err)
+}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", nodeBackendURL+"/cloudflare/speed-test", nil)
+func runCloudflareTest(ctx context.Context, providerID, providerName, scheduleID, roundID string) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", providerConfig.NodeBackendURL+"/cloudflare/speed-test", nil)
 	if err != nil {
-		log.Printf("Error creating request to Node.js backend: %v", err)
-		return
+		return fmt.Errorf("error creating request to Node.js backend: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -222,20 +355,17 @@ func runCloudflareTest(ctx context.Context, providerID, providerName, scheduleID
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		log.Printf("Error making request to Node.js backend: %v", err)
-		return
+		return fmt.Errorf("error making request to Node.js backend: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("Error reading response from Node.js backend: %v", err)
-		return
+		return fmt.Errorf("error reading response from Node.js backend: %w", err)
 	}
 
 	if !(resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated) {
-		log.Printf("Node.js backend returned non-OK status: %d, body: %s", resp.StatusCode, string(body))
-		return
+		return fmt.Errorf("node.js backend returned non-OK status: %d, body: %s", resp.StatusCode, string(body))
 	}
 
 	var cloudflareResult struct {
@@ -245,13 +375,11 @@ func runCloudflareTest(ctx context.Context, providerID, providerName, scheduleID
 		Latency  float64 `json:"latency"`
 	}
 	if err := json.Unmarshal(body, &cloudflareResult); err != nil {
-		log.Printf("Error parsing Cloudflare result: %v", err)
-		return
+		return fmt.Errorf("error parsing Cloudflare result: %w", err)
 	}
 
 	if cloudflareResult.Status == "running" {
-		log.Printf("Cloudflare speed test is already running")
-		return
+		return errTestAlreadyRunning
 	}
 
 	timestamp := time.Now().Format(time.RFC3339)
@@ -265,18 +393,19 @@ func runCloudflareTest(ctx context.Context, providerID, providerName, scheduleID
 			URL:  "https://speed.cloudflare.com",
 		},
 		Client: struct {
-			IP       string `json:"ip"`
-			Hostname string `json:"hostname"`
-			City     string `json:"city"`
-			Region   string `json:"region"`
-			Country  string `json:"country"`
-			Loc      string `json:"loc"`
-			Org      string `json:"org"`
-			Postal   string `json:"postal"`
-			Timezone string `json:"timezone"`
+			IP        string `json:"ip"`
+			Hostname  string `json:"hostname"`
+			City      string `json:"city"`
+			Region    string `json:"region"`
+			Country   string `json:"country"`
+			Loc       string `json:"loc"`
+			Org       string `json:"org"`
+			Postal    string `json:"postal"`
+			Timezone  string `json:"timezone"`
+			Interface string `json:"interface"`
 		}{
-			// Client info is not provided by Cloudflare speed test
-			// These fields will be empty
+			// Client info is not provided by Cloudflare speed test, and the test runs in the
+			// Node.js service, whose interfaces may differ from ours. These fields will be empty
 		},
 		BytesSent:     0,
 		BytesReceived: 0,
@@ -288,46 +417,39 @@ func runCloudflareTest(ctx context.Context, providerID, providerName, scheduleID
 		ProviderID:    providerID,
 		ProviderName:  providerName,
 		ScheduleID:    scheduleID,
+		RoundID:       roundID,
 	}
 
 	rawResult, _ := json.Marshal(cloudflareResult)
 	if err := storeResult(ctx, result, string(rawResult)); err != nil {
-		log.Printf("Error storing Cloudflare result: %v", err)
+		return fmt.Errorf("error storing Cloudflare result: %w", err)
 	}
+	return nil
 }
 
-func runIperf3Test(ctx context.Context, providerID, providerName, hostEndpoint, hostPort, scheduleID string) {
+func runIperf3Test(ctx context.Context, providerID, providerName, hostEndpoint, hostPort, scheduleID, roundID string) error {
 	timestamp := time.Now().Format(time.RFC3339)
-	cmd := exec.CommandContext(ctx, "iperf3", "-c", hostEndpoint, "-p", hostPort, "--json")
+	cmd := exec.CommandContext(ctx, providerConfig.Iperf3Path, "-c", hostEndpoint, "-p", hostPort, "--json")
 	output, err := cmd.Output()
 	if err != nil {
-		if ctx.Err() == context.Canceled {
-			log.Printf("Speed test was canceled: context deadline exceeded or request canceled")
-			return
-		}
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			log.Printf("Speed test failed with stderr: %s", string(exitErr.Stderr))
-		} else {
-			log.Printf("Error running speedtest: %v", err)
-		}
-		return
+		return commandError(ctx, err)
 	}
 
 	var iperf3Result models.Iperf3Result
 	if err := json.Unmarshal(output, &iperf3Result); err != nil {
-		log.Printf("Error parsing iperf3 JSON: %v\nOutput: %s", err, string(output))
-		return
+		return fmt.Errorf("error parsing iperf3 JSON: %w\nOutput: %s", err, string(output))
 	}
 
 	result := iperf3Result.ToSpeedTestResult(providerID, providerName)
 	result.Timestamp = timestamp
 	result.ScheduleID = scheduleID
+	result.RoundID = roundID
+	result.Client.Interface = routeInterface(ctx, hostEndpoint)
 
-	cmd = exec.CommandContext(ctx, "ping", "-c", "10", hostEndpoint)
+	cmd = exec.CommandContext(ctx, providerConfig.PingPath, "-c", "10", hostEndpoint)
 	output, err = cmd.Output()
 	if err != nil {
-		log.Printf("Error pinging iperf.test.kdl.io: %v", err)
-		return
+		return fmt.Errorf("error pinging %s: %w", hostEndpoint, err)
 	}
 
 	pingOutput := string(output)
@@ -355,93 +477,48 @@ func runIperf3Test(ctx context.Context, providerID, providerName, hostEndpoint,
 	}
 
 	if err := storeResult(ctx, result, string(output)); err != nil {
-		log.Printf("Error storing result: %v", err)
+		return fmt.Errorf("error storing result: %w", err)
 	}
+	return nil
 }
 
-func fetchFilteredResults(ctx context.Context, startDate, endDate string, serverNames []string, providers []string, limit, offset int) ([]models.SpeedTestResult, error) {
-	if startDate == "" {
-		startDate = "1900-01-01T00:00:00.000-00"
-	}
-	if endDate == "" {
-		endDate = "2500-01-01T00:00:00.000-00"
-	}
-
-	// Base query
-	query := `
-        SELECT 
-            timestamp, server_name, server_url, client_ip, client_hostname,
+// resultColumns is the column list read by scanResult. It needs filters.ProviderJoin.
+const resultColumns = `
+            speedtest_results.id, timestamp, server_name, server_url, client_ip, client_hostname,
             client_city, client_region, client_country, client_loc, client_org,
-            client_postal, client_timezone, bytes_sent, bytes_received, 
+            client_postal, client_timezone, client_interface, bytes_sent, bytes_received, 
             ping, jitter, upload, download, share,
-            provider_id, provider_name, schedule_id
-        FROM speedtest_results
-        WHERE ($1::timestamptz IS NULL OR timestamp >= $1)
-        AND ($2::timestamptz IS NULL OR timestamp <= $2)
-    `
-
-	args := []interface{}{startDate, endDate}
-	paramIndex := 3
-
-	// Add server names filter if specified
-	if len(serverNames) > 0 {
-		placeholders := make([]string, len(serverNames))
-		for i := range serverNames {
-			placeholders[i] = fmt.Sprintf("$%d", paramIndex)
-			args = append(args, serverNames[i])
-			paramIndex++
-		}
-		query += fmt.Sprintf(" AND (server_name IN (%s))", strings.Join(placeholders, ", "))
-	}
-
-	// Add provider filter if providers are specified
-	if len(providers) > 0 {
-		placeholders := make([]string, len(providers))
-		for i := range providers {
-			placeholders[i] = fmt.Sprintf("$%d", paramIndex)
-			args = append(args, providers[i])
-			paramIndex++
-		}
-		query += fmt.Sprintf(" AND (provider_id IN (%s))", strings.Join(placeholders, ", "))
-	}
+            provider_id, COALESCE(provider.name, ''), schedule_id, round_id, tags,
+            is_anomaly, anomaly_score, anomaly_metrics`
 
-	query += " ORDER BY timestamp DESC LIMIT $" + strconv.Itoa(paramIndex) + " OFFSET $" + strconv.Itoa(paramIndex+1)
-	args = append(args, limit, offset)
+// scanResult reads resultColumns followed by any extra selected columns into extra, formatting the
+// timestamp in location
+func scanResult(rows pgx.Rows, location *time.Location, extra ...interface{}) (models.SpeedTestResult, error) {
+	var result models.SpeedTestResult
+	var timestamp time.Time
+	var scheduleID sql.NullString
+	var roundID sql.NullString
 
-	rows, err := database.DB.Query(ctx, query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch results: %w", err)
+	targets := []interface{}{
+		&result.ID, &timestamp, &result.Server.Name, &result.Server.URL, &result.Client.IP, &result.Client.Hostname,
+		&result.Client.City, &result.Client.Region, &result.Client.Country, &result.Client.Loc, &result.Client.Org,
+		&result.Client.Postal, &result.Client.Timezone, &result.Client.Interface, &result.BytesSent, &result.BytesReceived,
+		&result.Ping, &result.Jitter, &result.Upload, &result.Download, &result.Share,
+		&result.ProviderID, &result.ProviderName, &scheduleID, &roundID, &result.Tags,
+		&result.IsAnomaly, &result.AnomalyScore, &result.AnomalyMetrics,
 	}
-	defer rows.Close()
-
-	var results []models.SpeedTestResult
-	for rows.Next() {
-		var result models.SpeedTestResult
-		var timestamp time.Time
-		var scheduleID sql.NullString
-
-		if err := rows.Scan(
-			&timestamp, &result.Server.Name, &result.Server.URL, &result.Client.IP, &result.Client.Hostname,
-			&result.Client.City, &result.Client.Region, &result.Client.Country, &result.Client.Loc, &result.Client.Org,
-			&result.Client.Postal, &result.Client.Timezone, &result.BytesSent, &result.BytesReceived,
-			&result.Ping, &result.Jitter, &result.Upload, &result.Download, &result.Share,
-			&result.ProviderID, &result.ProviderName, &scheduleID,
-		); err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
-		}
-
-		result.Timestamp = timestamp.Format(time.RFC3339)
-		if scheduleID.Valid {
-			result.ScheduleID = scheduleID.String
-		}
-		results = append(results, result)
+	if err := rows.Scan(append(targets, extra...)...); err != nil {
+		return result, fmt.Errorf("failed to scan row: %w", err)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error reading rows: %w", err)
+	result.Timestamp = timestamp.In(location).Format(time.RFC3339)
+	if scheduleID.Valid {
+		result.ScheduleID = scheduleID.String
 	}
-
-	return results, nil
+	if roundID.Valid {
+		result.RoundID = roundID.String
+	}
+	return result, nil
 }
 
 func startSpeedTest(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,172 @@
+package statistics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/database"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/filters"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/retention"
+)
+
+// percentiles are computed in one percentile_cont call per metric, in this order, and match the
+// rollup columns in rollupPercentiles
+const percentiles = "ARRAY[0.05, 0.25, 0.5, 0.75, 0.95, 0.99]"
+
+var rollupPercentiles = []string{"p5", "p25", "p50", "p75", "p95", "p99"}
+
+// StatisticsHandler returns percentiles, standard deviation and sample counts of every metric,
+// optionally grouped by provider, server or schedule. It accepts the same filters as GET /api/speedtest.
+func StatisticsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+
+	filter, err := filters.ParseResultFilterWithDefaults(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	groupBy := query.Get("groupBy")
+	if _, ok := filters.GroupColumn(groupBy); groupBy != "" && !ok {
+		http.Error(w, fmt.Sprintf("Invalid groupBy: %q (expected provider, server or schedule)", groupBy), http.StatusBadRequest)
+		return
+	}
+
+	response := models.StatisticsResponse{GroupBy: groupBy}
+	response.Groups, err = computeStatistics(r.Context(), filter, groupBy)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to compute statistics: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": response,
+	})
+}
+
+// computeStatistics summarizes the raw results and, for ranges whose raw results were removed by the
+// retention job, the hourly rollups, like the aggregate endpoint. Counts, means, minimums, maximums
+// and standard deviations stay exact. Percentiles that include rollups are the average of the
+// percentiles of the raw results and of every rollup, weighted by their sample counts, so they are
+// only approximate. Rollups written before they stored p5, p25, p75 and sums of squares are left out
+// of those.
+func computeStatistics(ctx context.Context, filter filters.ResultFilter, groupBy string) ([]models.StatisticsGroup, error) {
+	groupColumn := "''"
+	if column, ok := filters.GroupColumn(groupBy); ok {
+		groupColumn = "COALESCE(" + column + ", '')"
+	}
+
+	// Metric names come from filters.Metrics, so they can be interpolated safely
+	var metricValues, percentileColumns, weightedColumns, resultColumns []string
+	for _, metric := range filters.Metrics {
+		metricValues = append(metricValues, fmt.Sprintf("('%[1]s', %[1]s)", metric))
+	}
+	for i, p := range rollupPercentiles {
+		percentileColumns = append(percentileColumns, fmt.Sprintf("percentiles[%d] AS %s", i+1, p))
+		weightedColumns = append(weightedColumns, fmt.Sprintf(
+			"SUM(%[1]s * sample_count) / SUM(sample_count) FILTER (WHERE %[1]s IS NOT NULL) AS %[1]s", p))
+		resultColumns = append(resultColumns, fmt.Sprintf("COALESCE(%s, 0)", p))
+	}
+
+	where, args := filter.Where(nil)
+	sources := fmt.Sprintf(`
+            SELECT group_key, metric, sample_count, total, sum_squares, min_value, max_value, %[5]s
+            FROM (
+                SELECT %[1]s AS group_key, metrics.metric, COUNT(*) AS sample_count, SUM(metrics.value) AS total,
+                       SUM(metrics.value * metrics.value) AS sum_squares, MIN(metrics.value) AS min_value, MAX(metrics.value) AS max_value,
+                       percentile_cont(%[6]s) WITHIN GROUP (ORDER BY metrics.value) AS percentiles
+                FROM speedtest_results%[2]s
+                CROSS JOIN LATERAL (VALUES %[4]s) AS metrics(metric, value)%[3]s AND metrics.value IS NOT NULL
+                GROUP BY 1, 2
+            ) raw`, groupColumn, filters.ProviderJoin, where, strings.Join(metricValues, ", "),
+		strings.Join(percentileColumns, ", "), percentiles)
+	results := fmt.Sprintf(`
+            SELECT %[1]s AS group_key, COUNT(*) AS result_count
+            FROM speedtest_results%[2]s%[3]s
+            GROUP BY 1`, groupColumn, filters.ProviderJoin, where)
+	if !filter.RawOnly() {
+		sources += fmt.Sprintf(`
+            UNION ALL
+            SELECT %[1]s, metric, sample_count, avg_value * sample_count, sum_squares, min_value, max_value, %[5]s
+            FROM %[4]s%[2]s%[3]s`, groupColumn, filters.ProviderJoin, where, retention.RollupTables["hour"],
+			strings.Join(rollupPercentiles, ", "))
+		// Every rolled up result counts towards at least one metric of its bucket
+		results += fmt.Sprintf(`
+            UNION ALL
+            SELECT group_key, SUM(result_count)
+            FROM (
+                SELECT %[1]s AS group_key, MAX(sample_count) AS result_count
+                FROM %[4]s%[2]s%[3]s
+                GROUP BY timestamp, provider_id, server_name, schedule_id, 1
+            ) buckets
+            GROUP BY 1`, groupColumn, filters.ProviderJoin, where, retention.RollupTables["hour"])
+	}
+
+	query := fmt.Sprintf(`
+        WITH sources AS (%[1]s
+        ),
+        results AS (%[2]s
+        ),
+        merged AS (
+            SELECT group_key, metric, SUM(sample_count) AS sample_count, SUM(total) AS total,
+                   MIN(min_value) AS min_value, MAX(max_value) AS max_value,
+                   SUM(sample_count) FILTER (WHERE sum_squares IS NOT NULL) AS squares_count,
+                   SUM(total) FILTER (WHERE sum_squares IS NOT NULL) AS squares_total,
+                   SUM(sum_squares) AS sum_squares,
+                   %[3]s
+            FROM sources
+            GROUP BY 1, 2
+        )
+        SELECT totals.group_key, totals.result_count, COALESCE(metric, ''), COALESCE(sample_count, 0)::bigint,
+               COALESCE(total / sample_count, 0),
+               COALESCE(sqrt(GREATEST((sum_squares - squares_total * squares_total / squares_count) / NULLIF(squares_count - 1, 0), 0)), 0),
+               COALESCE(min_value, 0), COALESCE(max_value, 0), %[4]s
+        FROM (SELECT group_key, SUM(result_count)::bigint AS result_count FROM results GROUP BY 1) totals
+        LEFT JOIN merged ON merged.group_key = totals.group_key
+        ORDER BY 1, 3`, sources, results, strings.Join(weightedColumns, ",\n                   "), strings.Join(resultColumns, ", "))
+
+	rows, err := database.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []models.StatisticsGroup{}
+	for rows.Next() {
+		var group models.StatisticsGroup
+		var metric string
+		var stats models.MetricStatistics
+		err := rows.Scan(&group.Group, &group.Count, &metric, &stats.Count, &stats.Mean, &stats.StdDev, &stats.Min, &stats.Max,
+			&stats.P5, &stats.P25, &stats.P50, &stats.P75, &stats.P95, &stats.P99)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan statistics: %w", err)
+		}
+
+		// Rows are ordered by group, with one row per metric that has values in the group
+		if len(groups) == 0 || groups[len(groups)-1].Group != group.Group {
+			group.Metrics = make(map[string]models.MetricStatistics)
+			for _, m := range filters.Metrics {
+				group.Metrics[m] = models.MetricStatistics{}
+			}
+			groups = append(groups, group)
+		}
+		if metric != "" {
+			groups[len(groups)-1].Metrics[metric] = stats
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading statistics: %w", err)
+	}
+	return groups, nil
+}
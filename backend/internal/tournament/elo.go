@@ -0,0 +1,55 @@
+package tournament
+
+import "math"
+
+const (
+	// initialRating is the rating of a provider or server before its first round
+	initialRating = 1500.0
+	// kFactor is the largest change a single round can make to a rating
+	kFactor = 32.0
+)
+
+// expectedScore is the chance that a player rated a beats a player rated b
+func expectedScore(a, b float64) float64 {
+	return 1 / (1 + math.Pow(10, (b-a)/400))
+}
+
+// updateRatings plays every pair of subjects against each other on their scores and returns the
+// new ratings. Each subject's change is scaled by the number of opponents, so a round moves a rating
+// by at most kFactor however many subjects took part. Subjects without a rating start at initialRating.
+func updateRatings(ratings, scores map[string]float64, lowerIsBetter bool) map[string]float64 {
+	updated := make(map[string]float64, len(scores))
+	if len(scores) < 2 {
+		return updated
+	}
+
+	current := func(subject string) float64 {
+		if rating, ok := ratings[subject]; ok {
+			return rating
+		}
+		return initialRating
+	}
+
+	opponents := float64(len(scores) - 1)
+	for subject, score := range scores {
+		rating := current(subject)
+
+		var delta float64
+		for opponent, opponentScore := range scores {
+			if opponent == subject {
+				continue
+			}
+
+			actual := 0.5
+			if score != opponentScore {
+				actual = 0
+				if (score > opponentScore) != lowerIsBetter {
+					actual = 1
+				}
+			}
+			delta += actual - expectedScore(rating, current(opponent))
+		}
+		updated[subject] = rating + kFactor*delta/opponents
+	}
+	return updated
+}
@@ -0,0 +1,103 @@
+package tournament
+
+import (
+	"math"
+	"testing"
+)
+
+const tolerance = 1e-9
+
+func TestExpectedScore(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b float64
+		want float64
+	}{
+		{name: "equal ratings", a: 1500, b: 1500, want: 0.5},
+		{name: "400 points ahead", a: 1900, b: 1500, want: 10.0 / 11},
+		{name: "400 points behind", a: 1500, b: 1900, want: 1.0 / 11},
+		{name: "100 points ahead", a: 1600, b: 1500, want: 0.6400649998028851},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := expectedScore(tt.a, tt.b)
+			if math.Abs(got-tt.want) > tolerance {
+				t.Errorf("expectedScore(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+			if sum := got + expectedScore(tt.b, tt.a); math.Abs(sum-1) > tolerance {
+				t.Errorf("expected scores of both sides sum to %v, want 1", sum)
+			}
+		})
+	}
+}
+
+func TestUpdateRatings(t *testing.T) {
+	tests := []struct {
+		name          string
+		ratings       map[string]float64
+		scores        map[string]float64
+		lowerIsBetter bool
+		want          map[string]float64
+	}{
+		{
+			name:   "higher score wins",
+			scores: map[string]float64{"a": 120, "b": 80},
+			want:   map[string]float64{"a": 1516, "b": 1484},
+		},
+		{
+			name:          "lower score wins when lower is better",
+			scores:        map[string]float64{"a": 120, "b": 80},
+			lowerIsBetter: true,
+			want:          map[string]float64{"a": 1484, "b": 1516},
+		},
+		{
+			name:   "tie between equal ratings",
+			scores: map[string]float64{"a": 50, "b": 50},
+			want:   map[string]float64{"a": 1500, "b": 1500},
+		},
+		{
+			name:    "favourite gains less",
+			ratings: map[string]float64{"a": 1600},
+			scores:  map[string]float64{"a": 120, "b": 80},
+			want:    map[string]float64{"a": 1611.5179200063076, "b": 1488.4820799936924},
+		},
+		{
+			name:   "three new subjects",
+			scores: map[string]float64{"a": 300, "b": 200, "c": 100},
+			want:   map[string]float64{"a": 1516, "b": 1500, "c": 1484},
+		},
+		{
+			name:          "three rated subjects, lower is better",
+			ratings:       map[string]float64{"a": 1600, "b": 1500, "c": 1400},
+			scores:        map[string]float64{"a": 30, "b": 10, "c": 20},
+			lowerIsBetter: true,
+			want:          map[string]float64{"a": 1577.6030091767866, "b": 1516, "c": 1406.3969908232134},
+		},
+		{
+			name:    "ratings of absent subjects are left out",
+			ratings: map[string]float64{"a": 1500, "b": 1500, "z": 1700},
+			scores:  map[string]float64{"a": 1, "b": 2},
+			want:    map[string]float64{"a": 1484, "b": 1516},
+		},
+		{
+			name:   "single subject",
+			scores: map[string]float64{"a": 100},
+			want:   map[string]float64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := updateRatings(tt.ratings, tt.scores, tt.lowerIsBetter)
+			if len(got) != len(tt.want) {
+				t.Fatalf("updateRatings() = %v, want %v", got, tt.want)
+			}
+			for subject, want := range tt.want {
+				if math.Abs(got[subject]-want) > tolerance {
+					t.Errorf("rating of %s = %v, want %v", subject, got[subject], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package tournament
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/database"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/filters"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+)
+
+// LeaderboardHandler ranks providers or servers by their tournament rating for one metric.
+// Query parameters: type (provider or server, default provider), metric (default download)
+// and history=true to include the rating of every subject after each round.
+func LeaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+
+	leaderboard := models.Leaderboard{
+		SubjectType: query.Get("type"),
+		Metric:      query.Get("metric"),
+	}
+	if leaderboard.SubjectType == "" {
+		leaderboard.SubjectType = SubjectProvider
+	}
+	if leaderboard.Metric == "" {
+		leaderboard.Metric = "download"
+	}
+
+	if leaderboard.SubjectType != SubjectProvider && leaderboard.SubjectType != SubjectServer {
+		http.Error(w, fmt.Sprintf("Invalid type: %q (expected provider or server)", leaderboard.SubjectType), http.StatusBadRequest)
+		return
+	}
+	if !filters.IsMetric(leaderboard.Metric) {
+		http.Error(w, fmt.Sprintf("Invalid metric: %q", leaderboard.Metric), http.StatusBadRequest)
+		return
+	}
+
+	var includeHistory bool
+	if v := query.Get("history"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid history: %q", v), http.StatusBadRequest)
+			return
+		}
+		includeHistory = parsed
+	}
+
+	entries, err := fetchLeaderboard(r.Context(), leaderboard.SubjectType, leaderboard.Metric, includeHistory)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load leaderboard: %v", err), http.StatusInternalServerError)
+		return
+	}
+	leaderboard.Entries = entries
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": leaderboard,
+	})
+}
+
+func fetchLeaderboard(ctx context.Context, subjectType, metric string, includeHistory bool) ([]models.LeaderboardEntry, error) {
+	rows, err := database.DB.Query(ctx, `
+		SELECT subject, rating, games, updated_at
+		FROM ratings
+		WHERE subject_type = $1 AND metric = $2
+		ORDER BY rating DESC, subject
+	`, subjectType, metric)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch ratings: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.LeaderboardEntry{}
+	for rows.Next() {
+		entry := models.LeaderboardEntry{Rank: len(entries) + 1}
+		if err := rows.Scan(&entry.Subject, &entry.Rating, &entry.Games, &entry.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan rating: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading ratings: %w", err)
+	}
+
+	if !includeHistory || len(entries) == 0 {
+		return entries, nil
+	}
+
+	history, err := fetchHistory(ctx, subjectType, metric)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		entries[i].History = history[entries[i].Subject]
+	}
+	return entries, nil
+}
+
+// fetchHistory returns the rating points of every subject, oldest first
+func fetchHistory(ctx context.Context, subjectType, metric string) (map[string][]models.RatingPoint, error) {
+	rows, err := database.DB.Query(ctx, `
+		SELECT subject, round_id, rating, created_at
+		FROM rating_history
+		WHERE subject_type = $1 AND metric = $2
+		ORDER BY created_at
+	`, subjectType, metric)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch rating history: %w", err)
+	}
+	defer rows.Close()
+
+	history := make(map[string][]models.RatingPoint)
+	for rows.Next() {
+		var subject string
+		var point models.RatingPoint
+		if err := rows.Scan(&subject, &point.RoundID, &point.Rating, &point.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan rating history: %w", err)
+		}
+		history[subject] = append(history[subject], point)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading rating history: %w", err)
+	}
+	return history, nil
+}
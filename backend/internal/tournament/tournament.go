@@ -0,0 +1,180 @@
+package tournament
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/database"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/filters"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/speedtest"
+)
+
+// Subject types ratings are kept for
+const (
+	SubjectProvider = "provider"
+	SubjectServer   = "server"
+)
+
+// RunRound runs the tournament providers of a schedule back-to-back in randomized order under a
+// shared round ID, then updates the ratings of every provider and server that produced a result
+func RunRound(ctx context.Context, schedule models.Schedule) error {
+	providers := append([]string(nil), schedule.TournamentProviders...)
+	rand.Shuffle(len(providers), func(i, j int) {
+		providers[i], providers[j] = providers[j], providers[i]
+	})
+
+	var roundID string
+	if err := database.DB.QueryRow(ctx, "SELECT uuid_generate_v4()::text").Scan(&roundID); err != nil {
+		return fmt.Errorf("failed to create round ID: %w", err)
+	}
+
+	log.Printf("Starting tournament round %s for schedule %s: %v", roundID, schedule.Name, providers)
+	speedtest.RunSpeedTests(ctx, models.SpeedTestRequest{
+		Providers:    providers,
+		HostEndpoint: schedule.HostEndpoint,
+		HostPort:     schedule.HostPort,
+		ScheduleID:   schedule.ID,
+		RoundID:      roundID,
+	})
+
+	return recordRound(ctx, roundID)
+}
+
+// recordRound updates the ratings with the results of one round
+func recordRound(ctx context.Context, roundID string) error {
+	scores, err := fetchRoundScores(ctx, roundID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, subjectType := range []string{SubjectProvider, SubjectServer} {
+		for _, metric := range filters.Metrics {
+			metricScores := scores[subjectType][metric]
+			if len(metricScores) < 2 {
+				continue
+			}
+			if err := updateMetricRatings(ctx, tx, roundID, subjectType, metric, metricScores); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit ratings: %w", err)
+	}
+	return nil
+}
+
+// fetchRoundScores averages the results of a round per subject type, metric and subject
+func fetchRoundScores(ctx context.Context, roundID string) (map[string]map[string]map[string]float64, error) {
+	rows, err := database.DB.Query(ctx, `
+		SELECT providers.name, COALESCE(server_name, ''), download, upload, ping, jitter
+		FROM speedtest_results
+		JOIN providers ON providers.id = speedtest_results.provider_id
+		WHERE round_id = $1
+		  AND download IS NOT NULL AND upload IS NOT NULL AND ping IS NOT NULL AND jitter IS NOT NULL
+	`, roundID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch round results: %w", err)
+	}
+	defer rows.Close()
+
+	sums := map[string]map[string]map[string]float64{SubjectProvider: {}, SubjectServer: {}}
+	counts := map[string]map[string]int{SubjectProvider: {}, SubjectServer: {}}
+	for rows.Next() {
+		var providerName, serverName string
+		var download, upload, ping, jitter float64
+		if err := rows.Scan(&providerName, &serverName, &download, &upload, &ping, &jitter); err != nil {
+			return nil, fmt.Errorf("failed to scan round result: %w", err)
+		}
+
+		values := map[string]float64{"download": download, "upload": upload, "ping": ping, "jitter": jitter}
+		subjects := map[string]string{SubjectProvider: providerName, SubjectServer: serverName}
+		for subjectType, subject := range subjects {
+			if subject == "" {
+				continue
+			}
+			for _, metric := range filters.Metrics {
+				if sums[subjectType][metric] == nil {
+					sums[subjectType][metric] = make(map[string]float64)
+				}
+				sums[subjectType][metric][subject] += values[metric]
+			}
+			counts[subjectType][subject]++
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading round results: %w", err)
+	}
+
+	for subjectType, metrics := range sums {
+		for _, subjects := range metrics {
+			for subject := range subjects {
+				subjects[subject] /= float64(counts[subjectType][subject])
+			}
+		}
+	}
+	return sums, nil
+}
+
+func updateMetricRatings(ctx context.Context, tx pgx.Tx, roundID, subjectType, metric string, scores map[string]float64) error {
+	subjects := make([]string, 0, len(scores))
+	for subject := range scores {
+		subjects = append(subjects, subject)
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT subject, rating FROM ratings
+		WHERE subject_type = $1 AND metric = $2 AND subject = ANY($3)
+		FOR UPDATE
+	`, subjectType, metric, subjects)
+	if err != nil {
+		return fmt.Errorf("failed to fetch ratings: %w", err)
+	}
+	ratings := make(map[string]float64)
+	for rows.Next() {
+		var subject string
+		var rating float64
+		if err := rows.Scan(&subject, &rating); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan rating: %w", err)
+		}
+		ratings[subject] = rating
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error reading ratings: %w", err)
+	}
+
+	for subject, rating := range updateRatings(ratings, scores, filters.LowerIsBetter(metric)) {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO ratings (subject_type, subject, metric, rating, games)
+			VALUES ($1, $2, $3, $4, 1)
+			ON CONFLICT (subject_type, subject, metric) DO UPDATE
+			SET rating = EXCLUDED.rating, games = ratings.games + 1, updated_at = CURRENT_TIMESTAMP
+		`, subjectType, subject, metric, rating)
+		if err != nil {
+			return fmt.Errorf("failed to store rating: %w", err)
+		}
+
+		_, err = tx.Exec(ctx, `
+			INSERT INTO rating_history (round_id, subject_type, subject, metric, rating)
+			VALUES ($1, $2, $3, $4, $5)
+		`, roundID, subjectType, subject, metric, rating)
+		if err != nil {
+			return fmt.Errorf("failed to store rating history: %w", err)
+		}
+	}
+	return nil
+}
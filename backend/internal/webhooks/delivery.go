@@ -0,0 +1,252 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/database"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/metrics"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+)
+
+const (
+	deliveryStatusPending   = "pending"
+	deliveryStatusSucceeded = "succeeded"
+	deliveryStatusFailed    = "failed"
+
+	maxDeliveryAttempts = 5
+	deliveryTimeout     = 10 * time.Second
+)
+
+var deliveryClient = &http.Client{Timeout: deliveryTimeout}
+
+// Event is the JSON body posted to webhook endpoints
+type Event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// Dispatcher forwards stored results and failed runs to subscribed webhooks
+type Dispatcher struct{}
+
+func (d *Dispatcher) Name() string {
+	return "webhooks"
+}
+
+func (d *Dispatcher) HandleResult(result models.SpeedTestResult) {
+	go Dispatch(EventResultStored, result)
+}
+
+func (d *Dispatcher) HandleRun(event models.RunEvent) {
+	if event.Status != metrics.RunStatusFailure {
+		return
+	}
+	go Dispatch(EventRunFailed, event)
+}
+
+// HandleAlert forwards alert rules that start firing to webhooks subscribed to alert.triggered
+func HandleAlert(event models.AlertEvent) {
+	if event.State != "firing" {
+		return
+	}
+	go Dispatch(EventAlertTriggered, event)
+}
+
+// Dispatch delivers an event to every active webhook subscribed to its type, retrying failed deliveries
+func Dispatch(eventType string, data interface{}) {
+	ctx := context.Background()
+
+	rows, err := database.DB.Query(ctx, `
+		SELECT id, name, url, secret, event_types, is_active, created_at, updated_at
+		FROM webhooks
+		WHERE is_active = true AND $1 = ANY(event_types)
+	`, eventType)
+	if err != nil {
+		log.Printf("Error loading webhooks for %s: %v", eventType, err)
+		return
+	}
+
+	var webhooks []models.Webhook
+	for rows.Next() {
+		var h models.Webhook
+		if err := rows.Scan(&h.ID, &h.Name, &h.URL, &h.Secret, &h.EventTypes, &h.IsActive, &h.CreatedAt, &h.UpdatedAt); err != nil {
+			log.Printf("Error scanning webhook: %v", err)
+			rows.Close()
+			return
+		}
+		webhooks = append(webhooks, h)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		log.Printf("Error reading webhooks: %v", err)
+		return
+	}
+
+	for _, webhook := range webhooks {
+		deliveryID, payload, err := createDelivery(ctx, webhook, eventType, data)
+		if err != nil {
+			log.Printf("Error creating delivery for webhook %s: %v", webhook.Name, err)
+			continue
+		}
+		go deliverWithRetries(ctx, webhook, deliveryID, eventType, payload)
+	}
+}
+
+// deliverNow makes a single synchronous delivery attempt, used for test events
+func deliverNow(ctx context.Context, webhook models.Webhook, eventType string, data interface{}) (models.WebhookDelivery, error) {
+	deliveryID, payload, err := createDelivery(ctx, webhook, eventType, data)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+
+	responseStatus, sendErr := send(ctx, webhook, deliveryID, eventType, payload)
+	status := deliveryStatusSucceeded
+	if sendErr != nil {
+		status = deliveryStatusFailed
+	}
+	if err := recordAttempt(ctx, deliveryID, status, responseStatus, sendErr); err != nil {
+		return models.WebhookDelivery{}, err
+	}
+
+	delivery := models.WebhookDelivery{
+		ID:             deliveryID,
+		WebhookID:      webhook.ID,
+		EventType:      eventType,
+		Payload:        string(payload),
+		Status:         status,
+		Attempts:       1,
+		ResponseStatus: responseStatus,
+	}
+	if sendErr != nil {
+		delivery.Error = sendErr.Error()
+	}
+	return delivery, nil
+}
+
+// createDelivery logs a pending delivery and renders the event body that will be signed and sent
+func createDelivery(ctx context.Context, webhook models.Webhook, eventType string, data interface{}) (string, []byte, error) {
+	var deliveryID string
+	var createdAt time.Time
+	err := database.DB.QueryRow(ctx, `
+		INSERT INTO webhook_deliveries (webhook_id, event_type, payload, status)
+		VALUES ($1, $2, '', $3)
+		RETURNING id, created_at
+	`, webhook.ID, eventType, deliveryStatusPending).Scan(&deliveryID, &createdAt)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to log delivery: %w", err)
+	}
+
+	payload, err := json.Marshal(Event{
+		ID:        deliveryID,
+		Type:      eventType,
+		CreatedAt: createdAt,
+		Data:      data,
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	if _, err := database.DB.Exec(ctx, "UPDATE webhook_deliveries SET payload = $1 WHERE id = $2", string(payload), deliveryID); err != nil {
+		return "", nil, fmt.Errorf("failed to store delivery payload: %w", err)
+	}
+	return deliveryID, payload, nil
+}
+
+func deliverWithRetries(ctx context.Context, webhook models.Webhook, deliveryID, eventType string, payload []byte) {
+	backoff := 2 * time.Second
+	for attempt := 1; attempt <= maxDeliveryAttempts; attempt++ {
+		responseStatus, err := send(ctx, webhook, deliveryID, eventType, payload)
+
+		status := deliveryStatusSucceeded
+		if err != nil {
+			status = deliveryStatusPending
+			if attempt == maxDeliveryAttempts {
+				status = deliveryStatusFailed
+			}
+		}
+		if recordErr := recordAttempt(ctx, deliveryID, status, responseStatus, err); recordErr != nil {
+			log.Printf("Error recording webhook delivery %s: %v", deliveryID, recordErr)
+		}
+
+		if err == nil {
+			return
+		}
+		log.Printf("Webhook %s delivery attempt %d failed: %v", webhook.Name, attempt, err)
+
+		if attempt < maxDeliveryAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+}
+
+// send posts a signed event and returns the response status code
+func send(ctx context.Context, webhook models.Webhook, deliveryID, eventType string, payload []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "battle-of-the-bandwidth-webhooks")
+	req.Header.Set("X-BotB-Event", eventType)
+	req.Header.Set("X-BotB-Delivery", deliveryID)
+	req.Header.Set("X-BotB-Timestamp", timestamp)
+	req.Header.Set("X-BotB-Signature", "sha256="+Sign(webhook.Secret, timestamp, payload))
+
+	resp, err := deliveryClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign computes the hex encoded HMAC-SHA256 of "<timestamp>.<body>" with the webhook secret.
+// Receivers recompute it from the X-BotB-Timestamp header and the raw request body.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func recordAttempt(ctx context.Context, deliveryID, status string, responseStatus int, sendErr error) error {
+	var responseStatusValue, errorValue interface{}
+	if responseStatus != 0 {
+		responseStatusValue = responseStatus
+	}
+	if sendErr != nil {
+		errorValue = sendErr.Error()
+	}
+
+	_, err := database.DB.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = attempts + 1, response_status = $2, error = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4
+	`, status, responseStatusValue, errorValue, deliveryID)
+	return err
+}
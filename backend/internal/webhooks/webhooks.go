@@ -0,0 +1,358 @@
+package webhooks
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/database"
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+)
+
+// Event types that webhooks can subscribe to
+const (
+	EventResultStored   = "result.stored"
+	EventRunFailed      = "run.failed"
+	EventAlertTriggered = "alert.triggered"
+	EventTest           = "webhook.test"
+)
+
+var subscribableEvents = map[string]bool{
+	EventResultStored:   true,
+	EventRunFailed:      true,
+	EventAlertTriggered: true,
+}
+
+// webhookPatch holds the fields of a PATCH request. Fields left out of the request are nil and keep
+// their current value.
+type webhookPatch struct {
+	Name       *string   `json:"name"`
+	URL        *string   `json:"url"`
+	Secret     *string   `json:"secret"`
+	EventTypes *[]string `json:"event_types"`
+	IsActive   *bool     `json:"is_active"`
+}
+
+func WebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		if r.PathValue("id") != "" {
+			getWebhook(w, r)
+		} else {
+			listWebhooks(w, r)
+		}
+	case http.MethodPost:
+		createWebhook(w, r)
+	case http.MethodPatch:
+		updateWebhook(w, r)
+	case http.MethodDelete:
+		deleteWebhook(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// TestWebhookHandler sends a test event to a webhook and reports the delivery
+func TestWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	webhook, err := fetchWebhook(r, r.PathValue("id"))
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	delivery, err := deliverNow(r.Context(), webhook, EventTest, map[string]string{
+		"message": "This is a test event from Battle of the Bandwidth",
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(delivery)
+}
+
+// DeliveriesHandler lists the most recent deliveries of a webhook
+func DeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := 50
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+
+	rows, err := database.DB.Query(r.Context(), `
+		SELECT id, webhook_id, event_type, payload, status, attempts, response_status, error, created_at, updated_at
+		FROM webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`, r.PathValue("id"), limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var responseStatus sql.NullInt32
+		var deliveryError sql.NullString
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+			&responseStatus, &deliveryError, &d.CreatedAt, &d.UpdatedAt); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if responseStatus.Valid {
+			d.ResponseStatus = int(responseStatus.Int32)
+		}
+		if deliveryError.Valid {
+			d.Error = deliveryError.String
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+func listWebhooks(w http.ResponseWriter, r *http.Request) {
+	rows, err := database.DB.Query(r.Context(), `
+		SELECT id, name, url, event_types, is_active, created_at, updated_at
+		FROM webhooks
+		ORDER BY created_at DESC
+	`)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	webhooks := []models.Webhook{}
+	for rows.Next() {
+		var h models.Webhook
+		if err := rows.Scan(&h.ID, &h.Name, &h.URL, &h.EventTypes, &h.IsActive, &h.CreatedAt, &h.UpdatedAt); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		webhooks = append(webhooks, h)
+	}
+
+	json.NewEncoder(w).Encode(webhooks)
+}
+
+func getWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, err := fetchWebhook(r, r.PathValue("id"))
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The secret is only returned when the webhook is created
+	webhook.Secret = ""
+	json.NewEncoder(w).Encode(webhook)
+}
+
+func fetchWebhook(r *http.Request, id string) (models.Webhook, error) {
+	var h models.Webhook
+	err := database.DB.QueryRow(r.Context(), `
+		SELECT id, name, url, secret, event_types, is_active, created_at, updated_at
+		FROM webhooks
+		WHERE id = $1
+	`, id).Scan(&h.ID, &h.Name, &h.URL, &h.Secret, &h.EventTypes, &h.IsActive, &h.CreatedAt, &h.UpdatedAt)
+	return h, err
+}
+
+func createWebhook(w http.ResponseWriter, r *http.Request) {
+	// Webhooks are active unless the request says otherwise, like the column default
+	h := models.Webhook{IsActive: true}
+	if err := json.NewDecoder(r.Body).Decode(&h); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateWebhook(h); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if h.Secret == "" {
+		secret, err := generateSecret()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		h.Secret = secret
+	}
+
+	err := database.DB.QueryRow(r.Context(), `
+		INSERT INTO webhooks (name, url, secret, event_types, is_active)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at
+	`, h.Name, h.URL, h.Secret, h.EventTypes, h.IsActive).Scan(&h.ID, &h.CreatedAt, &h.UpdatedAt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(h)
+}
+
+func updateWebhook(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		http.Error(w, "ID is required", http.StatusBadRequest)
+		return
+	}
+
+	var patch webhookPatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var h models.Webhook
+	err = tx.QueryRow(ctx, `
+		SELECT id, name, url, secret, event_types, is_active, created_at
+		FROM webhooks
+		WHERE id = $1
+		FOR UPDATE
+	`, id).Scan(&h.ID, &h.Name, &h.URL, &h.Secret, &h.EventTypes, &h.IsActive, &h.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	patch.apply(&h)
+	if err := validateWebhook(h); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = tx.QueryRow(ctx, `
+		UPDATE webhooks
+		SET name = $1, url = $2, secret = $3, event_types = $4, is_active = $5, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $6
+		RETURNING updated_at
+	`, h.Name, h.URL, h.Secret, h.EventTypes, h.IsActive, id).Scan(&h.UpdatedAt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.Secret = ""
+	json.NewEncoder(w).Encode(h)
+}
+
+func (p webhookPatch) apply(h *models.Webhook) {
+	if p.Name != nil {
+		h.Name = *p.Name
+	}
+	if p.URL != nil {
+		h.URL = *p.URL
+	}
+	// An empty secret keeps the current one
+	if p.Secret != nil && *p.Secret != "" {
+		h.Secret = *p.Secret
+	}
+	if p.EventTypes != nil {
+		h.EventTypes = *p.EventTypes
+	}
+	if p.IsActive != nil {
+		h.IsActive = *p.IsActive
+	}
+}
+
+func deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		http.Error(w, "ID is required", http.StatusBadRequest)
+		return
+	}
+
+	result, err := database.DB.Exec(r.Context(), "DELETE FROM webhooks WHERE id = $1", id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if result.RowsAffected() == 0 {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func validateWebhook(h models.Webhook) error {
+	if h.Name == "" {
+		return errors.New("name is required")
+	}
+
+	parsed, err := url.Parse(h.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("url must be an absolute http or https URL")
+	}
+
+	if len(h.EventTypes) == 0 {
+		return errors.New("at least one event type is required")
+	}
+	for _, eventType := range h.EventTypes {
+		if !subscribableEvents[eventType] {
+			return fmt.Errorf("unknown event type: %q", eventType)
+		}
+	}
+	return nil
+}
+
+func generateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,110 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/phillipshreves/battle-of-the-bandwidth/backend/internal/models"
+)
+
+func TestSign(t *testing.T) {
+	// HMAC-SHA256 of `1714564800.{"event":"test"}` with the key "secret"
+	want := "d655920d78318d3d38da3d838f57010b942eb190108fd6edd79c1620aeaf4786"
+	if got := Sign("secret", "1714564800", []byte(`{"event":"test"}`)); got != want {
+		t.Errorf("Sign() = %s, want %s", got, want)
+	}
+}
+
+func TestSendSignsRequest(t *testing.T) {
+	type request struct {
+		header http.Header
+		body   []byte
+	}
+	requests := make(chan request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{header: r.Header, body: body}
+		if r.Header.Get("X-BotB-Event") == EventRunFailed {
+			http.Error(w, "not today", http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	webhook := models.Webhook{URL: server.URL, Secret: "secret"}
+	payload := []byte(`{"event":"webhook.test"}`)
+	status, err := send(context.Background(), webhook, "d1", EventTest, payload)
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("send() = %d, %v", status, err)
+	}
+
+	req := <-requests
+	if string(req.body) != string(payload) {
+		t.Errorf("body = %s, want %s", req.body, payload)
+	}
+	if req.header.Get("X-BotB-Event") != EventTest || req.header.Get("X-BotB-Delivery") != "d1" {
+		t.Errorf("headers = %v", req.header)
+	}
+	// Receivers verify the signature from the timestamp header and the raw body
+	want := "sha256=" + Sign("secret", req.header.Get("X-BotB-Timestamp"), req.body)
+	if got := req.header.Get("X-BotB-Signature"); got != want {
+		t.Errorf("X-BotB-Signature = %q, want %q", got, want)
+	}
+
+	status, err = send(context.Background(), webhook, "d2", EventRunFailed, payload)
+	<-requests
+	if status != http.StatusServiceUnavailable || err == nil || !strings.Contains(err.Error(), "not today") {
+		t.Errorf("send() = %d, %v, want the status and response body", status, err)
+	}
+}
+
+func TestValidateWebhook(t *testing.T) {
+	tests := []struct {
+		name    string
+		webhook models.Webhook
+		wantErr string
+	}{
+		{name: "valid", webhook: models.Webhook{Name: "n8n", URL: "https://example.com/hook", EventTypes: []string{EventResultStored}}},
+		{name: "missing name", webhook: models.Webhook{URL: "https://example.com", EventTypes: []string{EventRunFailed}}, wantErr: "name is required"},
+		{name: "relative URL", webhook: models.Webhook{Name: "x", URL: "/hook", EventTypes: []string{EventRunFailed}}, wantErr: "absolute http or https URL"},
+		{name: "other scheme", webhook: models.Webhook{Name: "x", URL: "ftp://example.com", EventTypes: []string{EventRunFailed}}, wantErr: "absolute http or https URL"},
+		{name: "no events", webhook: models.Webhook{Name: "x", URL: "http://example.com"}, wantErr: "at least one event type"},
+		{name: "test event", webhook: models.Webhook{Name: "x", URL: "http://example.com", EventTypes: []string{EventTest}}, wantErr: "unknown event type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateWebhook(tt.webhook)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateWebhook() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateWebhook() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebhookPatch(t *testing.T) {
+	webhook := models.Webhook{Name: "n8n", URL: "https://example.com", Secret: "old", EventTypes: []string{EventRunFailed}, IsActive: true}
+
+	var patch webhookPatch
+	if err := json.Unmarshal([]byte(`{"url": "https://example.org", "secret": "", "is_active": false}`), &patch); err != nil {
+		t.Fatal(err)
+	}
+	patch.apply(&webhook)
+
+	if webhook.URL != "https://example.org" || webhook.IsActive {
+		t.Errorf("webhook = %+v, want the sent fields changed", webhook)
+	}
+	if webhook.Name != "n8n" || webhook.Secret != "old" || len(webhook.EventTypes) != 1 {
+		t.Errorf("webhook = %+v, want the other fields and the secret kept", webhook)
+	}
+}
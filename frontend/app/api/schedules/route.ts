@@ -95,7 +95,9 @@ export async function DELETE(request: Request) {
             );
         }
 
-        const response = await fetch(`${BACKEND_URL}/api/schedules/${id}`, {
+        const mode = searchParams.get('mode') || 'detach';
+
+        const response = await fetch(`${BACKEND_URL}/api/schedules/${id}?mode=${encodeURIComponent(mode)}`, {
             method: 'DELETE',
             headers: {
                 'Content-Type': 'application/json',
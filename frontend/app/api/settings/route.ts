@@ -9,7 +9,7 @@ export async function GET() {
             return NextResponse.json({ error: response.body, data: {}});
         }
         const data = await response.json();
-        return NextResponse.json({error: "", data: data});
+        return NextResponse.json({error: "", data: data.data});
     } catch (error) {
         return NextResponse.json({ error: (error as Error).message, data: {} });
     }
@@ -27,10 +27,12 @@ export async function PATCH(request: Request) {
         });
         
         if (!response.ok){
-            return NextResponse.json({ success: false, error: response.body, data: {}});
+            const error = await response.text();
+            return NextResponse.json({ success: false, error: error, data: {}}, { status: response.status });
         };
 
-        return NextResponse.json({ success: true });
+        const data = await response.json();
+        return NextResponse.json({ success: true, error: "", data: data.data });
     } catch (error) {
         return NextResponse.json({ success: false, error: (error as Error).message }, { status: 500 });
     }
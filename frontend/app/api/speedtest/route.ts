@@ -16,6 +16,7 @@ interface ServerResponse {
         org: string;
         postal: string;
         timezone: string;
+        interface?: string;
     };
     bytes_sent: number;
     bytes_received: number;
@@ -26,6 +27,24 @@ interface ServerResponse {
     share: string;
 }
 
+interface Paging {
+    limit: number;
+    next?: string;
+    prev?: string;
+    total?: number;
+}
+
+interface ServerResultsPage {
+    data: ServerResponse[];
+    paging: Paging;
+}
+
+// Filters and sorting forwarded to the backend as they are
+const forwardedParams = [
+    "defaults", "range", "tz", "schedule", "client_ip", "client_org", "tag", "family", "interface", "sort",
+    "download_lt", "download_gt", "upload_lt", "upload_gt", "ping_lt", "ping_gt", "jitter_lt", "jitter_gt",
+];
+
 interface SpeedTestRequestBody {
     providers?: string[];
     hostEndpoint?: string;
@@ -41,14 +60,19 @@ export async function GET(request: Request) {
     const endDate = url.searchParams.get("endDate");
     const servers = url.searchParams.getAll("servers");
     const limit = url.searchParams.get("limit");
-    const offset = url.searchParams.get("offset");
+    const cursor = url.searchParams.get("cursor");
+    const total = url.searchParams.get("total");
     const providers = url.searchParams.getAll("providers");
     
     const params = new URLSearchParams();
     if (startDate) params.append("startDate", startDate);
     if (endDate) params.append("endDate", endDate);
     if (limit) params.append("limit", limit);
-    if (offset) params.append("offset", offset);
+    if (cursor) params.append("cursor", cursor);
+    if (total) params.append("total", total);
+    forwardedParams.forEach(name => {
+        url.searchParams.getAll(name).forEach(value => params.append(name, value));
+    });
 
     if (servers && servers.length > 0) {
         servers.forEach(server => {
@@ -69,8 +93,8 @@ export async function GET(request: Request) {
             return NextResponse.json({ error: JSON.stringify(response), data: [] });
         }
 
-        const data: ServerResponse[] = await response.json();
-        return NextResponse.json({ error: "", data: data });
+        const page: ServerResultsPage = await response.json();
+        return NextResponse.json({ error: "", data: page.data, paging: page.paging });
     } catch (error) {
         console.log(`GET error: ${JSON.stringify(error)}`)
         return NextResponse.json({ error: (error as Error).message, data: [] });
@@ -2,9 +2,10 @@ import { LineSeries } from '@nivo/line';
 
 interface DataStatisticsProps {
     chartData: LineSeries[];
+    unitLabel: string;
 }
 
-export default function DataStatistics({ chartData }: DataStatisticsProps) {
+export default function DataStatistics({ chartData, unitLabel }: DataStatisticsProps) {
     const calculateAverage = (data: LineSeries[], id: string) => {
         const series = data.find(series => series.id === id);
         if (!series || !series.data.length) return 0;
@@ -24,30 +25,30 @@ export default function DataStatistics({ chartData }: DataStatisticsProps) {
         };
     };
 
-    const uploadAvg = calculateAverage(chartData, 'Upload Speed (Mbps)');
-    const downloadAvg = calculateAverage(chartData, 'Download Speed (Mbps)');
+    const uploadAvg = calculateAverage(chartData, `Upload Speed (${unitLabel})`);
+    const downloadAvg = calculateAverage(chartData, `Download Speed (${unitLabel})`);
     const pingAvg = calculateAverage(chartData, 'Ping (ms)');
 
-    const uploadStats = calculateMinMax(chartData, 'Upload Speed (Mbps)');
-    const downloadStats = calculateMinMax(chartData, 'Download Speed (Mbps)');
+    const uploadStats = calculateMinMax(chartData, `Upload Speed (${unitLabel})`);
+    const downloadStats = calculateMinMax(chartData, `Download Speed (${unitLabel})`);
     const pingStats = calculateMinMax(chartData, 'Ping (ms)');
 
     return (
         <div className="p-6 grid grid-cols-3 gap-4">
             <div className="text-center">
                 <h3 className="text-lg font-semibold mb-2 text-foreground">Download</h3>
-                <p className="text-2xl text-foreground">{downloadAvg} <span className="text-sm text-secondary">Mbps</span></p>
+                <p className="text-2xl text-foreground">{downloadAvg} <span className="text-sm text-secondary">{unitLabel}</span></p>
                 <div className="text-sm mt-2 text-secondary">
-                    <p>Max: {downloadStats.max} Mbps</p>
-                    <p>Min: {downloadStats.min} Mbps</p>
+                    <p>Max: {downloadStats.max} {unitLabel}</p>
+                    <p>Min: {downloadStats.min} {unitLabel}</p>
                 </div>
             </div>
             <div className="text-center">
                 <h3 className="text-lg font-semibold mb-2 text-foreground">Upload</h3>
-                <p className="text-2xl text-foreground">{uploadAvg} <span className="text-sm text-secondary">Mbps</span></p>
+                <p className="text-2xl text-foreground">{uploadAvg} <span className="text-sm text-secondary">{unitLabel}</span></p>
                 <div className="text-sm mt-2 text-secondary">
-                    <p>Max: {uploadStats.max} Mbps</p>
-                    <p>Min: {uploadStats.min} Mbps</p>
+                    <p>Max: {uploadStats.max} {unitLabel}</p>
+                    <p>Min: {uploadStats.min} {unitLabel}</p>
                 </div>
             </div>
             <div className="text-center">
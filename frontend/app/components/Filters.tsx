@@ -1,33 +1,43 @@
 interface FiltersProps {
     dateRange: [string | null, string | null];
+    selectedRange: string;
     selectedServers: string[];
     limitInput: string;
     availableServers: string[];
     availableProviders: { id: string, name: string }[];
     selectedProviders: string[];
+    availableSchedules: { id: string, name: string }[];
+    selectedSchedules: string[];
     isOpen: boolean;
     useLocalTime: boolean;
     onDateChange: (index: 0 | 1, value: string) => void;
+    onRangeChange: (value: string) => void;
     onServerChange: (values: string[]) => void;
     onLimitChange: (value: string) => void;
     onProvidersChange: (values: string[]) => void;
+    onSchedulesChange: (values: string[]) => void;
     onTimeDisplayToggle: () => void;
     onToggle: () => void;
 }
 
 export default function Filters({
     dateRange,
+    selectedRange,
     selectedServers,
     limitInput,
     availableServers,
     availableProviders,
     selectedProviders,
+    availableSchedules,
+    selectedSchedules,
     isOpen,
     useLocalTime,
     onDateChange,
+    onRangeChange,
     onServerChange,
     onLimitChange,
     onProvidersChange,
+    onSchedulesChange,
     onTimeDisplayToggle,
     onToggle
 }: FiltersProps) {
@@ -37,6 +47,9 @@ export default function Filters({
     if(!availableProviders) {
         availableProviders = []
     }
+    if(!availableSchedules) {
+        availableSchedules = []
+    }
     
     const handleServerChange = (serverName: string) => {
         if (selectedServers.includes(serverName)) {
@@ -57,6 +70,14 @@ export default function Filters({
             onProvidersChange([...selectedProviders, providerId]);
         }
     };
+
+    const handleScheduleChange = (scheduleId: string) => {
+        if (selectedSchedules.includes(scheduleId)) {
+            onSchedulesChange(selectedSchedules.filter(id => id !== scheduleId));
+        } else {
+            onSchedulesChange([...selectedSchedules, scheduleId]);
+        }
+    };
     
     return (
         <div>
@@ -108,6 +129,23 @@ export default function Filters({
                         </div>
                     </div>
 
+                    <div className="flex items-center gap-4">
+                        <label className="text-sm font-medium text-secondary w-32">Relative Range</label>
+                        <select
+                            className="flex-1 px-4 py-2 rounded-lg bg-background/80 border border-secondary/30
+                            focus:border-primary focus:ring-2 focus:ring-primary/20 focus:outline-none
+                            transition-colors duration-200 text-foreground"
+                            value={selectedRange}
+                            onChange={(e) => onRangeChange(e.target.value)}
+                        >
+                            <option value="">None</option>
+                            <option value="24h">Last 24 hours</option>
+                            <option value="7d">Last 7 days</option>
+                            <option value="30d">Last 30 days</option>
+                            <option value="mtd">Month to date</option>
+                        </select>
+                    </div>
+
                     <div className="flex items-start gap-4">
                         <label className="text-sm font-medium text-secondary w-32 pt-2">Server Selection</label>
                         <div className="flex-1 flex flex-wrap gap-2">
@@ -153,6 +191,29 @@ export default function Filters({
                         </div>
                     </div>
 
+                    {/* Schedule Selection Filter */}
+                    <div className="flex items-start gap-4">
+                        <label className="text-sm font-medium text-secondary w-32 pt-2">Schedules</label>
+                        <div className="flex-1 flex flex-wrap gap-2">
+                            {availableSchedules.map((schedule) => (
+                                <div key={schedule.id} className="flex items-center">
+                                    <label className="flex items-center space-x-2 cursor-pointer px-3 py-2 rounded-lg bg-background/80 border border-secondary/30 hover:border-primary transition-colors">
+                                        <input
+                                            type="checkbox"
+                                            className="form-checkbox h-4 w-4 text-primary rounded focus:ring-primary"
+                                            checked={selectedSchedules.includes(schedule.id)}
+                                            onChange={() => handleScheduleChange(schedule.id)}
+                                        />
+                                        <span className="text-sm text-foreground">{schedule.name}</span>
+                                    </label>
+                                </div>
+                            ))}
+                            {availableSchedules.length === 0 && (
+                                <div className="text-sm text-secondary italic">No schedules available</div>
+                            )}
+                        </div>
+                    </div>
+
                     <div className="flex items-center gap-4">
                         <label className="text-sm font-medium text-secondary w-32">Max Data Points</label>
                         <input
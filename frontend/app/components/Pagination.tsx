@@ -1,15 +1,19 @@
 interface PaginationProps {
     offset: number;
-    limit: number;
     dataLength: number;
+    total?: number;
+    hasPrevious: boolean;
+    hasNext: boolean;
     onPreviousPage: () => void;
     onNextPage: () => void;
 }
 
 export default function Pagination({
     offset,
-    limit,
     dataLength,
+    total,
+    hasPrevious,
+    hasNext,
     onPreviousPage,
     onNextPage
 }: PaginationProps) {
@@ -19,18 +23,20 @@ export default function Pagination({
                 className="px-4 py-2 rounded-lg bg-secondary/10 hover:bg-secondary/20
                    transition-colors duration-200 text-foreground disabled:opacity-50 disabled:cursor-not-allowed"
                 onClick={onPreviousPage}
-                disabled={offset === 0}
+                disabled={!hasPrevious}
             >
                 ← Newer
             </button>
             <span className="text-foreground">
-                {dataLength > 0 ? `Showing ${offset + 1} - ${offset + Math.min(limit, dataLength)} results` : 'No results'}
+                {dataLength > 0
+                    ? `Showing ${offset + 1} - ${offset + dataLength}${total !== undefined ? ` of ${total}` : ''} results`
+                    : 'No results'}
             </span>
             <button
                 className="px-4 py-2 rounded-lg bg-secondary/10 hover:bg-secondary/20
                    transition-colors duration-200 text-foreground disabled:opacity-50 disabled:cursor-not-allowed"
                 onClick={onNextPage}
-                disabled={dataLength < limit}
+                disabled={!hasNext}
             >
                 Older →
             </button>
@@ -26,8 +26,8 @@ const DEFAULT_COLORS: Record<string, { lineColor: string; pointColor: string }>
 
 // Map simplified IDs to user-friendly display names
 const SERIES_DISPLAY_NAMES: Record<string, string> = {
-    "download": "Download Speed (Mbps)",
-    "upload": "Upload Speed (Mbps)",
+    "download": "Download Speed",
+    "upload": "Upload Speed",
     "ping": "Ping (ms)"
 };
 
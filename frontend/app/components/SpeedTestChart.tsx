@@ -7,22 +7,24 @@ import SeriesColorCustomizer from './SeriesColorCustomizer';
 
 interface SpeedTestChartProps {
     chartData: LineSeries[];
+    unitLabel?: string;
     useLocalTime?: boolean;
 }
 
-// Map full series names to simplified IDs used in color system
-const SERIES_NAME_TO_ID_MAP: Record<string, string> = {
-    "Download Speed (Mbps)": "download",
-    "Upload Speed (Mbps)": "upload",
-    "Ping (ms)": "ping"
-};
+// Map full series names to simplified IDs used in color system. Speed series names end in
+// the display unit, so they are matched by prefix.
+const SERIES_NAME_PREFIX_TO_ID: [string, string][] = [
+    ["Download Speed", "download"],
+    ["Upload Speed", "upload"],
+    ["Ping", "ping"]
+];
 
 const seriesNameToId = (seriesName: unknown): string => {
     const name = String(seriesName || '');
-    return SERIES_NAME_TO_ID_MAP[name] || 'unknown';
+    return SERIES_NAME_PREFIX_TO_ID.find(([prefix]) => name.startsWith(prefix))?.[1] || 'unknown';
 };
 
-export default function SpeedTestChart({ chartData, useLocalTime = false }: SpeedTestChartProps) {
+export default function SpeedTestChart({ chartData, unitLabel = 'Mbps', useLocalTime = false }: SpeedTestChartProps) {
     const [isCustomizerOpen, setIsCustomizerOpen] = useState(false);
     const {
         seriesColors: seriesColorConfigs,
@@ -115,7 +117,7 @@ export default function SpeedTestChart({ chartData, useLocalTime = false }: Spee
                             tickSize: 5,
                             tickPadding: 5,
                             tickRotation: 0,
-                            legend: 'Speed (Mbps) / Ping (ms)',
+                            legend: `Speed (${unitLabel}) / Ping (ms)`,
                             legendOffset: -40,
                             legendPosition: 'middle'
                         }}
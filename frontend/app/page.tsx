@@ -3,7 +3,7 @@
 import { useState, useEffect } from 'react';
 import { LineSeries } from '@nivo/line';
 import { format, parseISO } from 'date-fns';
-import { SpeedTestData } from '@/types/types';
+import { DefaultFilters, Paging, Schedule, SpeedTestData, SpeedUnits, UserSettings } from '@/types/types';
 import SpeedTestChart from './components/SpeedTestChart';
 import Filters from './components/Filters';
 import Pagination from './components/Pagination';
@@ -15,10 +15,17 @@ interface FetchFilters {
     endDate?: string;
     servers?: string[];
     providers?: string[];
+    schedules?: string[];
+    range?: string;
     limit?: number;
-    offset?: number;
+    cursor?: string;
+    total?: string;
 }
 
+// Speeds are stored in Mbps; these convert them to the default_units setting
+const unitFactors: Record<SpeedUnits, number> = { kbps: 1000, mbps: 1, gbps: 0.001 };
+const unitLabels: Record<SpeedUnits, string> = { kbps: 'Kbps', mbps: 'Mbps', gbps: 'Gbps' };
+
 const defaultSpeedTestData: SpeedTestData = {
     timestamp: new Date().toISOString(),
     server: { name: 'example', url: '' },
@@ -26,8 +33,13 @@ const defaultSpeedTestData: SpeedTestData = {
     bytes_sent: 1, bytes_received: 1, ping: 1, jitter: 1, upload: 1, download: 1, share: ''
 };
 
-async function fetchSpeedTestData(filters: FetchFilters): Promise<{ error: string, data: SpeedTestData[] }> {
+async function fetchSpeedTestData(filters: FetchFilters): Promise<{ error: string, data: SpeedTestData[], paging?: Paging }> {
     const queryFilters = { ...filters };
+    if (!queryFilters.cursor) delete queryFilters.cursor;
+    if (!queryFilters.range) delete queryFilters.range;
+
+    const schedules = queryFilters.schedules;
+    delete queryFilters.schedules;
 
     const providers = queryFilters.providers;
     delete queryFilters.providers;
@@ -35,7 +47,8 @@ async function fetchSpeedTestData(filters: FetchFilters): Promise<{ error: strin
     const servers = queryFilters.servers;
     delete queryFilters.servers;
 
-    const query = new URLSearchParams(queryFilters as Record<string, string>).toString();
+    // The filters below already start from the default_filters setting, so the backend must not apply it again
+    const query = new URLSearchParams({ ...queryFilters, defaults: 'false' } as Record<string, string>).toString();
 
     let fullQuery = query;
 
@@ -49,6 +62,11 @@ async function fetchSpeedTestData(filters: FetchFilters): Promise<{ error: strin
         fullQuery = fullQuery ? `${fullQuery}&${serversQuery}` : serversQuery;
     }
 
+    if (schedules && schedules.length > 0) {
+        const schedulesQuery = schedules.map(s => `schedule=${encodeURIComponent(s)}`).join('&');
+        fullQuery = fullQuery ? `${fullQuery}&${schedulesQuery}` : schedulesQuery;
+    }
+
     try {
         const response = await fetch(`/api/speedtest?${fullQuery}`);
         if (!response.ok) {
@@ -67,34 +85,76 @@ async function fetchSpeedTestData(filters: FetchFilters): Promise<{ error: strin
 export default function Home() {
     const [speedTestData, setSpeedTestData] = useState<SpeedTestData[]>([]);
     const [dateRange, setDateRange] = useState<[string | null, string | null]>([null, null]);
+    const [selectedRange, setSelectedRange] = useState<string>('');
     const [selectedServers, setSelectedServers] = useState<string[]>([]);
     const [limit, setLimit] = useState<number>(20);
     const [limitInput, setLimitInput] = useState<string>('20');
+    const [cursor, setCursor] = useState<string>('');
     const [offset, setOffset] = useState<number>(0);
+    const [paging, setPaging] = useState<Paging | undefined>(undefined);
     const [isAdvancedFiltersOpen, setIsAdvancedFiltersOpen] = useState(false);
     const [availableServers, setAvailableServers] = useState<string[]>([]);
     const [availableProviders, setAvailableProviders] = useState<{ id: string, name: string }[]>([]);
     const [selectedProviders, setSelectedProviders] = useState<string[]>([]);
+    const [availableSchedules, setAvailableSchedules] = useState<{ id: string, name: string }[]>([]);
+    const [selectedSchedules, setSelectedSchedules] = useState<string[]>([]);
     const [useLocalTime, setUseLocalTime] = useState<boolean>(false);
+    const [units, setUnits] = useState<SpeedUnits>('mbps');
+    const [settingsLoaded, setSettingsLoaded] = useState<boolean>(false);
 
     useEffect(() => {
+        // Wait for the default filters, so the first page isn't fetched unfiltered
+        if (!settingsLoaded) return;
+
         const fetchData = async () => {
             try {
                 const result = await fetchSpeedTestData({
                     startDate: dateRange[0] ?? '',
                     endDate: dateRange[1] ?? '',
+                    range: selectedRange,
                     servers: selectedServers,
                     providers: selectedProviders.length > 0 ? selectedProviders : undefined,
+                    schedules: selectedSchedules,
                     limit,
-                    offset,
+                    cursor,
+                    total: 'true',
                 });
                 setSpeedTestData(result.data);
+                setPaging(result.paging);
             } catch (error) {
                 console.error("Error fetching data:", error);
             }
         };
         fetchData();
-    }, [dateRange, selectedServers, selectedProviders, useLocalTime, limit, offset]);
+    }, [settingsLoaded, dateRange, selectedRange, selectedServers, selectedProviders, selectedSchedules, useLocalTime, limit, cursor]);
+
+    useEffect(() => {
+        const fetchSettings = async () => {
+            try {
+                const response = await fetch('/api/settings');
+                if (!response.ok) throw new Error("Failed to fetch settings");
+                const result = await response.json();
+                const settings: UserSettings | undefined = result.data;
+                if (settings?.default_result_limit) {
+                    setLimit(settings.default_result_limit);
+                    setLimitInput(String(settings.default_result_limit));
+                }
+                if (settings?.default_units && unitFactors[settings.default_units]) {
+                    setUnits(settings.default_units);
+                }
+                const defaults: DefaultFilters = settings?.default_filters ?? {};
+                if (defaults.range) setSelectedRange(defaults.range);
+                if (defaults.providers) setSelectedProviders(defaults.providers);
+                if (defaults.servers) setSelectedServers(defaults.servers);
+                if (defaults.schedules) setSelectedSchedules(defaults.schedules);
+            } catch (error) {
+                console.error("Error fetching settings:", error);
+            } finally {
+                setSettingsLoaded(true);
+            }
+        };
+        fetchSettings();
+    }, []);
 
     useEffect(() => {
         const fetchServers = async () => {
@@ -124,13 +184,43 @@ export default function Home() {
         fetchProviders();
     }, []);
 
+    useEffect(() => {
+        const fetchSchedules = async () => {
+            try {
+                const response = await fetch('/api/schedules');
+                if (!response.ok) throw new Error("Failed to fetch schedules");
+                const result = await response.json();
+                const schedules: Schedule[] = result.data || [];
+                setAvailableSchedules(schedules.map(s => ({ id: s.id, name: s.name })));
+            } catch (error) {
+                console.error("Error fetching schedules:", error);
+            }
+        };
+        fetchSchedules();
+    }, []);
+
+    const resetPaging = () => {
+        setCursor('');
+        setOffset(0);
+    };
+
     const handleDateChange = (index: 0 | 1, value: string) => {
+        resetPaging();
         const newDateRange = [...dateRange];
         newDateRange[index] = value || null;
         setDateRange(newDateRange as [string | null, string | null]);
+        // A range can't be combined with explicit dates
+        if (value) setSelectedRange('');
+    };
+
+    const handleRangeChange = (value: string) => {
+        resetPaging();
+        setSelectedRange(value);
+        if (value) setDateRange([null, null]);
     };
 
     const handleServerChange = (values: string[]) => {
+        resetPaging();
         setSelectedServers(values);
     };
 
@@ -139,24 +229,34 @@ export default function Home() {
         const newLimit = parseInt(value, 10);
         if (!isNaN(newLimit) && newLimit > 0) {
             setLimit(newLimit);
-            setOffset(0);
+            resetPaging();
         }
     };
 
     const handlePreviousPage = () => {
-        if (offset - limit >= 0) {
-            setOffset(offset - limit);
+        if (paging?.prev) {
+            setCursor(paging.prev);
+            setOffset(Math.max(offset - limit, 0));
         }
     };
 
     const handleNextPage = () => {
-        setOffset(offset + limit);
+        if (paging?.next) {
+            setCursor(paging.next);
+            setOffset(offset + (speedTestData?.length || 0));
+        }
     };
 
     const handleProviderChange = (values: string[]) => {
+        resetPaging();
         setSelectedProviders(values);
     };
 
+    const handleScheduleChange = (values: string[]) => {
+        resetPaging();
+        setSelectedSchedules(values);
+    };
+
     const handleTimeDisplayToggle = () => {
         setUseLocalTime(!useLocalTime);
     };
@@ -171,21 +271,24 @@ export default function Home() {
         }
     };
 
+    const unitLabel = unitLabels[units];
+    const convertSpeed = (value: number) => Math.round(Number(value) * unitFactors[units] * 1000) / 1000;
+
     const chartData: LineSeries[] = [
         {
-            id: "Download Speed (Mbps)",
+            id: `Download Speed (${unitLabel})`,
             data: (speedTestData || [])
                 .map((item) => ({
                     x: formatTimestamp(item.timestamp),
-                    y: Math.round(Number(item.download) * 10) / 10,
+                    y: convertSpeed(item.download),
                 })),
         },
         {
-            id: "Upload Speed (Mbps)",
+            id: `Upload Speed (${unitLabel})`,
             data: (speedTestData || [])
                 .map((item) => ({
                     x: formatTimestamp(item.timestamp),
-                    y: Math.round(Number(item.upload) * 10) / 10,
+                    y: convertSpeed(item.upload),
                 })),
         },
         {
@@ -213,33 +316,40 @@ export default function Home() {
                 <div className="glass-card p-6 space-y-6">
                     <Filters
                         dateRange={dateRange}
+                        selectedRange={selectedRange}
                         selectedServers={selectedServers}
                         limitInput={limitInput}
                         availableServers={availableServers}
                         availableProviders={availableProviders}
                         selectedProviders={selectedProviders}
+                        availableSchedules={availableSchedules}
+                        selectedSchedules={selectedSchedules}
                         isOpen={isAdvancedFiltersOpen}
                         useLocalTime={useLocalTime}
                         onDateChange={handleDateChange}
+                        onRangeChange={handleRangeChange}
                         onServerChange={handleServerChange}
                         onLimitChange={handleLimitChange}
                         onProvidersChange={handleProviderChange}
+                        onSchedulesChange={handleScheduleChange}
                         onTimeDisplayToggle={handleTimeDisplayToggle}
                         onToggle={() => setIsAdvancedFiltersOpen(!isAdvancedFiltersOpen)}
                     />
 
                     <Pagination
                         offset={offset}
-                        limit={limit}
                         dataLength={speedTestData?.length || 0}
+                        total={paging?.total}
+                        hasPrevious={!!paging?.prev}
+                        hasNext={!!paging?.next}
                         onPreviousPage={handlePreviousPage}
                         onNextPage={handleNextPage}
                     />
 
-                    <DataStatistics chartData={chartData} />
+                    <DataStatistics chartData={chartData} unitLabel={unitLabel} />
 
                     <div className="hidden md:block">
-                        <SpeedTestChart chartData={chartData} useLocalTime={useLocalTime} />
+                        <SpeedTestChart chartData={chartData} unitLabel={unitLabel} useLocalTime={useLocalTime} />
                     </div>
                 </div>
                 <SchedulesTable />
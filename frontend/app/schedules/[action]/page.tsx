@@ -4,7 +4,7 @@ import { useState, useEffect, useCallback } from 'react';
 import { useRouter, useSearchParams } from 'next/navigation';
 import CronHelper from '@/app/components/CronHelper';
 import cronstrue from 'cronstrue';
-import { Schedule } from '@/types/types';
+import { Schedule, ScheduleDeleteMode } from '@/types/types';
 
 interface Provider {
     id: string;
@@ -28,12 +28,14 @@ export default function ScheduleForm() {
         host_port: '',
         created_at: new Date().toISOString(),
         updated_at: new Date().toISOString(),
-        result_limit: 0
+        result_limit: 0,
+        max_age_days: 0
     });
     const [error, setError] = useState<string | null>(null);
     const [loading, setLoading] = useState(false);
     const [loadingSchedule, setLoadingSchedule] = useState(false);
     const [deleting, setDeleting] = useState(false);
+    const [deleteMode, setDeleteMode] = useState<ScheduleDeleteMode>('detach');
     const [providers, setProviders] = useState<Provider[]>([]);
     const [loadingProviders, setLoadingProviders] = useState(true);
     const [showCronHelper, setShowCronHelper] = useState(false);
@@ -121,7 +123,14 @@ export default function ScheduleForm() {
             return;
         }
 
+        if (formData.max_age_days < 0) {
+            setError('Max age must be 0 or more days');
+            setLoading(false);
+            return;
+        }
+
         formData.result_limit = Number(formData.result_limit);
+        formData.max_age_days = Number(formData.max_age_days);
 
         try {
             const response = await fetch('/api/schedules', {
@@ -151,7 +160,7 @@ export default function ScheduleForm() {
 
         setDeleting(true);
         try {
-            const response = await fetch(`/api/schedules?id=${scheduleId}`, {
+            const response = await fetch(`/api/schedules?id=${scheduleId}&mode=${deleteMode}`, {
                 method: 'DELETE',
             });
 
@@ -202,6 +211,19 @@ export default function ScheduleForm() {
             <div className="flex justify-between items-center mb-6">
                 <h1 className="text-2xl font-bold text-foreground">{isEdit ? 'Edit Schedule' : 'Create New Schedule'}</h1>
                 <div className="flex space-x-2">
+                    {isEdit && (
+                        <select
+                            value={deleteMode}
+                            onChange={(e) => setDeleteMode(e.target.value as ScheduleDeleteMode)}
+                            disabled={deleting}
+                            className="p-2 bg-background/80 rounded border border-secondary/30 text-foreground"
+                            title="What happens to the results of this schedule"
+                        >
+                            <option value="detach">Keep results</option>
+                            <option value="archive">Archive schedule</option>
+                            <option value="cascade">Delete results</option>
+                        </select>
+                    )}
                     {isEdit && (
                         <button
                             onClick={handleDelete}
@@ -368,6 +390,24 @@ export default function ScheduleForm() {
                     </div>
                 </div>
 
+                <div>
+                    <label className="block text-sm font-medium mb-2 text-foreground">
+                        Max Age (days)
+                    </label>
+                    <input
+                        type="number"
+                        name="max_age_days"
+                        value={formData.max_age_days}
+                        onChange={handleChange}
+                        min="0"
+                        className="w-full p-2 bg-background/80 rounded border border-secondary/30 focus:border-primary focus:ring-1 focus:ring-primary text-foreground placeholder-muted"
+                        placeholder="0"
+                    />
+                    <div className="mt-1 text-sm text-secondary">
+                        Results older than this many days are deleted. Set to 0 to keep them forever.
+                    </div>
+                </div>
+
                 <div className="flex justify-end space-x-4">
                     <button
                         type="submit"
@@ -13,6 +13,14 @@ export interface ClientData {
     org: string;
     postal: string;
     timezone: string;
+    interface?: string;
+}
+
+export interface Paging {
+    limit: number;
+    next?: string;
+    prev?: string;
+    total?: number;
 }
 
 export interface SpeedTestData {
@@ -28,9 +36,22 @@ export interface SpeedTestData {
     share: string;
 }
 
+export type SpeedUnits = 'kbps' | 'mbps' | 'gbps';
+
+export interface DefaultFilters {
+    range?: '24h' | '7d' | '30d' | 'mtd';
+    providers?: string[];
+    servers?: string[];
+    schedules?: string[];
+}
+
 export interface UserSettings {
-    id: number;
-    speedtest_frequency: number;
+    id: string;
+    default_units: SpeedUnits;
+    default_filters: DefaultFilters;
+    default_timezone: string;
+    default_result_limit: number;
+    paused: boolean;
     created_at: string;
     updated_at: string;
 }
@@ -47,4 +68,9 @@ export interface Schedule {
     host_endpoint?: string;
     host_port?: string;
     result_limit: number;
+    max_age_days: number;
+    tournament_providers?: string[];
+    archived_at?: string | null;
 }
+
+export type ScheduleDeleteMode = 'detach' | 'cascade' | 'archive';